@@ -0,0 +1,38 @@
+package ntgo
+
+import "time"
+
+// Backoff is the policy a client follows between connection attempts. Each
+// failed attempt multiplies the delay by Multiplier, up to Max. Zero fields
+// take their value from DefaultBackoff.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoff is used when NetworkTables.Backoff is left empty.
+var DefaultBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+}
+
+// Delay returns how long to wait before the given attempt, counting from
+// zero.
+func (backoff Backoff) Delay(attempt int) time.Duration {
+	if backoff.Initial <= 0 {
+		backoff.Initial = DefaultBackoff.Initial
+	}
+	if backoff.Max <= 0 {
+		backoff.Max = DefaultBackoff.Max
+	}
+	if backoff.Multiplier < 1 {
+		backoff.Multiplier = DefaultBackoff.Multiplier
+	}
+	delay := float64(backoff.Initial)
+	for i := 0; i < attempt && delay < float64(backoff.Max); i++ {
+		delay *= backoff.Multiplier
+	}
+	return min(time.Duration(delay), backoff.Max)
+}
@@ -0,0 +1,335 @@
+package ntgo
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	// BindTag is the struct tag Bind reads entry names from. A tag of
+	// `nt:"kP,persistent"` binds the field to <prefix>/kP and marks the entry
+	// persistent. Nested structs tagged with a name are bound under
+	// <prefix>/<name>.
+	BindTag = "nt"
+
+	bindOptionPersistent = "persistent"
+)
+
+var (
+	ErrBindTargetInvalid    = errors.New("bind: target must be a non-nil pointer to a struct")
+	ErrBindFieldUnsupported = errors.New("bind: field type cannot be stored in an entry")
+	ErrBindValueOutOfRange  = errors.New("bind: value does not fit the field")
+)
+
+// Binding keeps the tagged fields of a struct in sync with entries. Remote
+// and local table changes are written into the struct as they happen, and
+// Push publishes fields the program has changed itself. Hold Lock while
+// reading or writing bound fields from other goroutines.
+type Binding struct {
+	nt       *NetworkTables
+	mu       sync.Mutex
+	fields   map[string]*boundField
+	listener int
+}
+
+type boundField struct {
+	value      reflect.Value
+	entryType  EntryType
+	persistent bool
+	published  []byte
+}
+
+// Bind publishes the tagged fields of the struct target points to as entries
+// under prefix. Entries that already exist with a matching type are copied
+// into the struct instead, so values tuned from a dashboard survive restarts.
+func (nt *NetworkTables) Bind(prefix string, target any) (*Binding, error) {
+	pointer := reflect.ValueOf(target)
+	if pointer.Kind() != reflect.Pointer || pointer.IsNil() || pointer.Elem().Kind() != reflect.Struct {
+		return nil, ErrBindTargetInvalid
+	}
+	binding := &Binding{
+		nt:     nt,
+		fields: map[string]*boundField{},
+	}
+	fieldsErr := binding.collect(strings.TrimSuffix(prefix, "/"), pointer.Elem())
+	if fieldsErr != nil {
+		return nil, fieldsErr
+	}
+	table := nt.Table()
+	for name, field := range binding.fields {
+		entry, ok := table.Get(name)
+		if ok && entry.Type == field.entryType {
+			if receiveErr := field.receive(entry.Value); receiveErr != nil {
+				return nil, receiveErr
+			}
+		} else {
+			value, _, valueErr := boundFieldValue(field.value)
+			if valueErr != nil {
+				return nil, valueErr
+			}
+			if setErr := nt.SetValue(name, field.entryType, value); setErr != nil {
+				return nil, setErr
+			}
+			field.published = value.GetRaw()
+		}
+		if field.persistent {
+			if flagsErr := nt.SetFlags(name, EntryFlagPersistent); flagsErr != nil {
+				return nil, flagsErr
+			}
+		}
+	}
+	binding.listener = table.AddListener(prefix, binding.handle)
+	return binding, nil
+}
+
+func (binding *Binding) Lock() {
+	binding.mu.Lock()
+}
+
+func (binding *Binding) Unlock() {
+	binding.mu.Unlock()
+}
+
+// Push publishes every bound field whose value changed since it was last
+// published or received.
+func (binding *Binding) Push() error {
+	type change struct {
+		name      string
+		entryType EntryType
+		value     EntryValue
+	}
+	changes := []change{}
+	binding.mu.Lock()
+	for name, field := range binding.fields {
+		value, entryType, valueErr := boundFieldValue(field.value)
+		if valueErr != nil {
+			binding.mu.Unlock()
+			return valueErr
+		}
+		raw := value.GetRaw()
+		if bytes.Equal(raw, field.published) {
+			continue
+		}
+		field.published = raw
+		changes = append(changes, change{name: name, entryType: entryType, value: value})
+	}
+	binding.mu.Unlock()
+	for _, c := range changes {
+		if setErr := binding.nt.SetValue(c.name, c.entryType, c.value); setErr != nil {
+			return setErr
+		}
+	}
+	return nil
+}
+
+// Close stops copying entry changes into the struct.
+func (binding *Binding) Close() {
+	binding.nt.Table().RemoveListener(binding.listener)
+}
+
+func (binding *Binding) handle(event EntryEvent) {
+	if event.Kind != EntryEventCreated && event.Kind != EntryEventUpdated {
+		return
+	}
+	binding.mu.Lock()
+	defer binding.mu.Unlock()
+	field, ok := binding.fields[event.Entry.Name.Value]
+	if !ok || event.Entry.Type != field.entryType {
+		return
+	}
+	field.receive(event.Entry.Value)
+}
+
+// receive copies value into the field. What the field then holds counts as
+// published, rather than value, so that a value the field holds differently,
+// such as 2.5 in an int, is not pushed back over the one received.
+func (field *boundField) receive(value EntryValue) error {
+	if setErr := setBoundField(field.value, value); setErr != nil {
+		return setErr
+	}
+	held, _, heldErr := boundFieldValue(field.value)
+	if heldErr != nil {
+		return heldErr
+	}
+	field.published = held.GetRaw()
+	return nil
+}
+
+func (binding *Binding) collect(prefix string, value reflect.Value) error {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		tag, ok := structField.Tag.Lookup(BindTag)
+		if !ok || tag == "-" || !structField.IsExported() {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if name == "" {
+			name = structField.Name
+		}
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Struct {
+			if nestedErr := binding.collect(prefix+"/"+name, fieldValue); nestedErr != nil {
+				return nestedErr
+			}
+			continue
+		}
+		entryType := boundEntryType(fieldValue.Type())
+		if entryType == EntryTypeUndef {
+			return ErrBindFieldUnsupported
+		}
+		field := &boundField{
+			value:     fieldValue,
+			entryType: entryType,
+		}
+		for _, option := range options[1:] {
+			if option == bindOptionPersistent {
+				field.persistent = true
+			}
+		}
+		binding.fields[prefix+"/"+name] = field
+	}
+	return nil
+}
+
+func boundEntryType(fieldType reflect.Type) EntryType {
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		return EntryTypeBoolean
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return EntryTypeDouble
+	case reflect.String:
+		return EntryTypeString
+	case reflect.Slice:
+		switch fieldType.Elem().Kind() {
+		case reflect.Uint8:
+			return EntryTypeRawData
+		case reflect.Bool:
+			return EntryTypeBooleanArr
+		case reflect.Float64:
+			return EntryTypeDoubleArr
+		case reflect.String:
+			return EntryTypeStringArr
+		}
+	}
+	return EntryTypeUndef
+}
+
+func boundFieldValue(field reflect.Value) (EntryValue, EntryType, error) {
+	entryType := boundEntryType(field.Type())
+	switch entryType {
+	case EntryTypeBoolean:
+		return BuildBoolean(field.Bool()), entryType, nil
+	case EntryTypeDouble:
+		var double float64
+		switch {
+		case field.CanFloat():
+			double = field.Float()
+		case field.CanInt():
+			double = float64(field.Int())
+		default:
+			double = float64(field.Uint())
+		}
+		return BuildDouble(double), entryType, nil
+	case EntryTypeString:
+		return BuildString(field.String()), entryType, nil
+	case EntryTypeRawData:
+		return BuildRaw(append([]byte{}, field.Bytes()...)), entryType, nil
+	// Arrays are copied element by element, as the field may be a named
+	// slice type, or a slice of a named element type.
+	case EntryTypeBooleanArr:
+		values := make([]bool, field.Len())
+		for i := range values {
+			values[i] = field.Index(i).Bool()
+		}
+		value, arrayErr := BuildBooleanArrayFrom(values)
+		return value, entryType, arrayErr
+	case EntryTypeDoubleArr:
+		values := make([]float64, field.Len())
+		for i := range values {
+			values[i] = field.Index(i).Float()
+		}
+		value, arrayErr := BuildDoubleArrayFrom(values)
+		return value, entryType, arrayErr
+	case EntryTypeStringArr:
+		values := make([]string, field.Len())
+		for i := range values {
+			values[i] = field.Index(i).String()
+		}
+		value, arrayErr := BuildStringArrayFrom(values)
+		return value, entryType, arrayErr
+	default:
+		return nil, entryType, ErrBindFieldUnsupported
+	}
+}
+
+// setBoundField stores value in field. A double that a numeric field cannot
+// hold, such as NaN, 300 for an int8 or a negative number for a uint, is
+// refused with ErrBindValueOutOfRange and the field is left as it was.
+func setBoundField(field reflect.Value, value EntryValue) error {
+	switch v := value.(type) {
+	case *ValueBoolean:
+		field.SetBool(v.Value)
+	case *ValueDouble:
+		return setBoundNumber(field, v.Value)
+	case *ValueString:
+		field.SetString(v.Value)
+	case *ValueRaw:
+		field.SetBytes(append([]byte{}, v.Value...))
+	case *ValueBooleanArray:
+		values := v.Values()
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, element := range values {
+			slice.Index(i).SetBool(element)
+		}
+		field.Set(slice)
+	case *ValueDoubleArray:
+		values := v.Values()
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, element := range values {
+			slice.Index(i).SetFloat(element)
+		}
+		field.Set(slice)
+	case *ValueStringArray:
+		values := v.Values()
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, element := range values {
+			slice.Index(i).SetString(element)
+		}
+		field.Set(slice)
+	default:
+		return ErrEntryCastInvalid
+	}
+	return nil
+}
+
+func setBoundNumber(field reflect.Value, number float64) error {
+	switch {
+	case field.CanFloat():
+		if !math.IsNaN(number) && !math.IsInf(number, 0) && field.OverflowFloat(number) {
+			return ErrBindValueOutOfRange
+		}
+		field.SetFloat(number)
+	case math.IsNaN(number) || math.IsInf(number, 0):
+		return ErrBindValueOutOfRange
+	case field.CanInt():
+		// 2^63 itself does not fit, and converting it would wrap.
+		if number < math.MinInt64 || number >= math.MaxInt64 || field.OverflowInt(int64(number)) {
+			return ErrBindValueOutOfRange
+		}
+		field.SetInt(int64(number))
+	default:
+		if number < 0 || number >= math.MaxUint64 || field.OverflowUint(uint64(number)) {
+			return ErrBindValueOutOfRange
+		}
+		field.SetUint(uint64(number))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package ntgo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type testPIDConfig struct {
+	P       float64 `nt:"kP,persistent"`
+	I       float64 `nt:"kI"`
+	Enabled bool    `nt:"enabled"`
+	Ignored string
+	Limits  struct {
+		Max int `nt:"max"`
+	} `nt:"limits"`
+}
+
+func TestBindPublishesFields(t *testing.T) {
+	nt := &NetworkTables{}
+	cfg := &testPIDConfig{P: 0.5, Enabled: true}
+	cfg.Limits.Max = 12
+	binding, err := nt.Bind("/drive", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	if nt.Double("/drive/kP").GetOr(0) != 0.5 {
+		t.Fatal("Expected /drive/kP to be published")
+	}
+	if nt.Double("/drive/limits/max").GetOr(0) != 12 {
+		t.Fatal("Expected /drive/limits/max to be published")
+	}
+	entry, _ := nt.Table().Get("/drive/kP")
+	if entry.Flags != EntryFlagPersistent {
+		t.Fatalf("Expected persistent flag but got %v", entry.Flags)
+	}
+	entry, _ = nt.Table().Get("/drive/kI")
+	if entry.Flags != EntryFlagTemporary {
+		t.Fatalf("Expected temporary flag but got %v", entry.Flags)
+	}
+	if _, ok := nt.Table().Get("/drive/Ignored"); ok {
+		t.Fatal("Expected untagged field not to be published")
+	}
+}
+
+func TestBindReceivesChanges(t *testing.T) {
+	nt := &NetworkTables{}
+	nt.Double("/drive/kI").Set(0.25)
+	cfg := &testPIDConfig{}
+	binding, err := nt.Bind("/drive", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	if cfg.I != 0.25 {
+		t.Fatalf("Expected existing entry to be copied in but got %v", cfg.I)
+	}
+	nt.Double("/drive/kP").Set(1.75)
+	binding.Lock()
+	p := cfg.P
+	binding.Unlock()
+	if p != 1.75 {
+		t.Fatalf("Expected %v but got %v", 1.75, p)
+	}
+}
+
+func TestBindPush(t *testing.T) {
+	nt := &NetworkTables{}
+	cfg := &testPIDConfig{}
+	binding, err := nt.Bind("/drive", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	before, _ := nt.Table().Get("/drive/kI")
+	binding.Lock()
+	cfg.Enabled = true
+	binding.Unlock()
+	if err := binding.Push(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !nt.Boolean("/drive/enabled").GetOr(false) {
+		t.Fatal("Expected pushed field to be published")
+	}
+	after, _ := nt.Table().Get("/drive/kI")
+	if after.Sequence != before.Sequence {
+		t.Fatal("Expected unchanged field not to be republished")
+	}
+}
+
+func TestBindPushAfterTruncation(t *testing.T) {
+	nt := &NetworkTables{}
+	cfg := &testPIDConfig{}
+	binding, err := nt.Bind("/drive", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	nt.Double("/drive/limits/max").Set(2.5)
+	binding.Lock()
+	limit := cfg.Limits.Max
+	binding.Unlock()
+	if limit != 2 {
+		t.Fatalf("Expected 2.5 to be truncated to 2 but got %d", limit)
+	}
+	if err := binding.Push(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	// The field did not change, so the value received stays.
+	if value := nt.Double("/drive/limits/max").GetOr(0); value != 2.5 {
+		t.Fatalf("Expected the received 2.5 to stay but got %v", value)
+	}
+}
+
+func TestBindInvalidTarget(t *testing.T) {
+	nt := &NetworkTables{}
+	if _, err := nt.Bind("/drive", testPIDConfig{}); err != ErrBindTargetInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrBindTargetInvalid, err)
+	}
+	unsupported := &struct {
+		Channel chan int `nt:"channel"`
+	}{}
+	if _, err := nt.Bind("/drive", unsupported); err != ErrBindFieldUnsupported {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrBindFieldUnsupported, err)
+	}
+}
+
+type testFloats []float64
+
+type testNamedConfig struct {
+	Values testFloats `nt:"values"`
+	Names  []string   `nt:"names"`
+	Count  uint8      `nt:"count"`
+	Offset int        `nt:"offset"`
+}
+
+func TestBindNamedSlices(t *testing.T) {
+	nt := &NetworkTables{}
+	cfg := &testNamedConfig{Values: testFloats{1, 2}, Names: []string{"a"}}
+	binding, err := nt.Bind("/config", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	if values := nt.DoubleArray("/config/values").GetOr(nil); len(values) != 2 || values[1] != 2 {
+		t.Fatalf("Expected /config/values to be published but got %v", values)
+	}
+	updated, _ := BuildDoubleArrayFrom([]float64{3, 4, 5})
+	nt.SetValue("/config/values", EntryTypeDoubleArr, updated)
+	binding.Lock()
+	values := cfg.Values
+	binding.Unlock()
+	if len(values) != 3 || values[2] != 5 {
+		t.Fatalf("Expected the named slice to be updated but got %v", values)
+	}
+}
+
+func TestBindNumberOutOfRange(t *testing.T) {
+	nt := &NetworkTables{}
+	cfg := &testNamedConfig{Count: 7, Offset: -3}
+	binding, err := nt.Bind("/config", cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer binding.Close()
+	cases := []struct {
+		name  string
+		value float64
+	}{
+		{"/config/count", -1},
+		{"/config/count", 256},
+		{"/config/count", math.NaN()},
+		{"/config/offset", math.Inf(1)},
+		{"/config/offset", 1e19},
+	}
+	for _, c := range cases {
+		nt.Double(c.name).Set(c.value)
+	}
+	binding.Lock()
+	count, offset := cfg.Count, cfg.Offset
+	binding.Unlock()
+	if count != 7 || offset != -3 {
+		t.Fatalf("Expected out of range values to be refused but got %d and %d", count, offset)
+	}
+	if err := setBoundField(reflect.ValueOf(&count).Elem(), BuildDouble(-1)); err != ErrBindValueOutOfRange {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrBindValueOutOfRange, err)
+	}
+}
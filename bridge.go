@@ -0,0 +1,90 @@
+package ntgo
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Bridge mirrors the entries of two NetworkTables instances into each other:
+// values, flags and deletes made on either side are applied to the other.
+// The two sides may speak different protocols, such as a 3.0 client on one
+// side and a 4.0 server from package nt4 on the other.
+//
+// Only changes that arrived from the network are forwarded, and a change is
+// dropped when the other side already holds it. The first rule keeps the
+// bridge from forwarding its own writes; the second stops a peer that echoes
+// a value back from bouncing it between the two sides forever.
+type Bridge struct {
+	a, b      *NetworkTables
+	listeners [2]int
+	closeOnce sync.Once
+}
+
+// NewBridge starts mirroring a and b, which should both be initialized.
+// Entries that already exist are copied across first; where both sides have
+// an entry, a wins. Remote procedures are not bridged.
+func NewBridge(a, b *NetworkTables) *Bridge {
+	bridge := &Bridge{a: a, b: b}
+	bridge.listeners[0] = a.Table().AddListener("", func(event EntryEvent) { bridge.forward(event, b) })
+	bridge.listeners[1] = b.Table().AddListener("", func(event EntryEvent) { bridge.forward(event, a) })
+	for _, entry := range a.Table().Entries("") {
+		applyEntry(entry, b)
+	}
+	for _, entry := range b.Table().Entries("") {
+		if _, exists := a.Table().Get(entry.Name.Value); !exists {
+			applyEntry(entry, a)
+		}
+	}
+	return bridge
+}
+
+func (bridge *Bridge) forward(event EntryEvent, to *NetworkTables) {
+	if event.Local {
+		return
+	}
+	if event.Kind != EntryEventDeleted {
+		applyEntry(event.Entry, to)
+		return
+	}
+	name := event.Entry.Name.Value
+	if _, exists := to.Table().Get(name); !exists {
+		return
+	}
+	if deleteErr := to.Delete(name); deleteErr != nil {
+		to.logger().Printf("ntgo: bridging delete of %s: %s", name, deleteErr)
+	}
+}
+
+// applyEntry brings the entry on to in line with entry, changing nothing
+// that already matches.
+func applyEntry(entry Entry, to *NetworkTables) {
+	if entry.Type == EntryTypeRPCDef || entry.Value == nil {
+		return
+	}
+	name := entry.Name.Value
+	current, exists := to.Table().Get(name)
+	if !exists || current.Type != entry.Type || !bytes.Equal(current.Value.GetRaw(), entry.Value.GetRaw()) {
+		if exists && current.Type != entry.Type {
+			// An entry cannot change type, so it is replaced.
+			to.Delete(name)
+		}
+		if setErr := to.SetValue(name, entry.Type, entry.Value); setErr != nil {
+			to.logger().Printf("ntgo: setting %s: %s", name, setErr)
+			return
+		}
+		current, _ = to.Table().Get(name)
+	}
+	if current.Flags != entry.Flags {
+		if flagsErr := to.SetFlags(name, entry.Flags); flagsErr != nil {
+			to.logger().Printf("ntgo: setting flags of %s: %s", name, flagsErr)
+		}
+	}
+}
+
+// Close stops mirroring. It does not close either side.
+func (bridge *Bridge) Close() {
+	bridge.closeOnce.Do(func() {
+		bridge.a.Table().RemoveListener(bridge.listeners[0])
+		bridge.b.Table().RemoveListener(bridge.listeners[1])
+	})
+}
@@ -0,0 +1,65 @@
+package ntgo
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startBridge bridges a client of one server to a second server, the way
+// a protocol bridge sits between a robot and a dashboard.
+func startBridge(t *testing.T) (robot, robotClient, dashboard, dashboardClient *NetworkTables) {
+	robot = startServer(t, &NetworkTables{})
+	robot.Double("/existing").Set(1)
+	robotClient = connectClient(t, robot, "bridge")
+	dashboard = startServer(t, &NetworkTables{})
+	bridge := NewBridge(robotClient, dashboard)
+	t.Cleanup(bridge.Close)
+	dashboardClient = connectClient(t, dashboard, "dashboard")
+	return robot, robotClient, dashboard, dashboardClient
+}
+
+func TestBridgeMirrors(t *testing.T) {
+	robot, _, _, dashboard := startBridge(t)
+	if dashboard.Double("/existing").GetOr(0) != 1 {
+		t.Fatal("Expected existing entries to be copied across")
+	}
+	robot.String("/robot/mode").Set("auto")
+	waitFor(t, "robot value to reach the dashboard", func() bool {
+		return dashboard.String("/robot/mode").GetOr("") == "auto"
+	})
+	dashboard.Boolean("/dashboard/enable").Set(true)
+	waitFor(t, "dashboard value to reach the robot", func() bool {
+		return robot.Boolean("/dashboard/enable").GetOr(false)
+	})
+	if err := robot.SetFlags("/robot/mode", EntryFlagPersistent); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "flags to reach the dashboard", func() bool {
+		entry, _ := dashboard.Table().Get("/robot/mode")
+		return entry.Flags == EntryFlagPersistent
+	})
+	if err := dashboard.Delete("/robot/mode"); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "delete to reach the robot", func() bool {
+		_, exists := robot.Table().Get("/robot/mode")
+		return !exists
+	})
+}
+
+func TestBridgeNoLoop(t *testing.T) {
+	robot, _, _, dashboard := startBridge(t)
+	var updates atomic.Int32
+	robot.Table().AddListener("/loop", func(event EntryEvent) {
+		updates.Add(1)
+	})
+	dashboard.Double("/loop").Set(1)
+	waitFor(t, "value to cross the bridge", func() bool {
+		return robot.Double("/loop").GetOr(0) == 1
+	})
+	time.Sleep(100 * time.Millisecond)
+	if updates.Load() != 1 {
+		t.Fatalf("Expected 1 change on the robot but got %d", updates.Load())
+	}
+}
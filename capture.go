@@ -0,0 +1,499 @@
+package ntgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// Link types of the captures DissectCapture can read.
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLoop     = 108
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+// dissectMaxAhead is how many segments may arrive ahead of a gap in a TCP
+// stream before the gap is taken as lost from the capture.
+const dissectMaxAhead = 64
+
+// captureMaxLength bounds the packets and blocks a capture may hold, so that
+// a corrupt length does not exhaust memory.
+const captureMaxLength = 16 << 20
+
+var (
+	ErrCaptureInvalid  = errors.New("capture: not a pcap or pcapng file")
+	ErrCaptureLinkType = errors.New("capture: unsupported link type")
+)
+
+// DissectCapture reads a pcap or pcapng capture file, reassembles the TCP
+// connections to or from port, and hands every NetworkTables 3.0 message
+// they carry to handle, in the order they were captured. An empty port means
+// DefaultPort. Segments that are retransmitted or out of order are put back
+// in order; data missing from the capture is reported as such, and reading
+// resynchronizes after it as DissectStream does.
+//
+// Ethernet, Linux cooked, loopback and raw IP captures of IPv4 and IPv6 are
+// understood. Fragmented IP packets are ignored. A capture that was cut off
+// mid-packet is dissected up to the cut and io.ErrUnexpectedEOF returned.
+func DissectCapture(r io.Reader, port string, handle func(Dissection)) error {
+	if port == "" {
+		port = DefaultPort
+	}
+	portNumber, portErr := strconv.ParseUint(port, 10, 16)
+	if portErr != nil {
+		return portErr
+	}
+	dissector := &captureDissector{port: uint16(portNumber), handle: handle, conversations: map[[2]netip.AddrPort]*dissectConversation{}}
+	readErr := readCapture(r, dissector.packet)
+	for _, conversation := range dissector.order {
+		for _, stream := range conversation.streams {
+			stream.finish(dissector.last)
+		}
+	}
+	return readErr
+}
+
+// readCapture hands every packet of a pcap or pcapng file to packet.
+func readCapture(r io.Reader, packet func(at time.Time, linkType uint16, frame []byte)) error {
+	reader := bufio.NewReader(r)
+	magic, peekErr := reader.Peek(4)
+	if peekErr != nil {
+		return ErrCaptureInvalid
+	}
+	if binary.LittleEndian.Uint32(magic) == 0x0A0D0D0A {
+		return readPcapng(reader, packet)
+	}
+	return readPcap(reader, packet)
+}
+
+func readPcap(r io.Reader, packet func(at time.Time, linkType uint16, frame []byte)) error {
+	header := make([]byte, 24)
+	if _, readErr := io.ReadFull(r, header); readErr != nil {
+		return ErrCaptureInvalid
+	}
+	var order binary.ByteOrder
+	var unit time.Duration
+	switch {
+	case binary.LittleEndian.Uint32(header) == 0xA1B2C3D4:
+		order, unit = binary.LittleEndian, time.Microsecond
+	case binary.BigEndian.Uint32(header) == 0xA1B2C3D4:
+		order, unit = binary.BigEndian, time.Microsecond
+	case binary.LittleEndian.Uint32(header) == 0xA1B23C4D:
+		order, unit = binary.LittleEndian, time.Nanosecond
+	case binary.BigEndian.Uint32(header) == 0xA1B23C4D:
+		order, unit = binary.BigEndian, time.Nanosecond
+	default:
+		return ErrCaptureInvalid
+	}
+	// The upper bits of the link type field are flags.
+	linkType := uint16(order.Uint32(header[20:]))
+	if !supportedLinkType(linkType) {
+		return ErrCaptureLinkType
+	}
+	record := make([]byte, 16)
+	for {
+		if _, readErr := io.ReadFull(r, record); readErr != nil {
+			if readErr == io.EOF {
+				return nil
+			}
+			return io.ErrUnexpectedEOF
+		}
+		at := time.Unix(int64(order.Uint32(record)), 0).Add(time.Duration(order.Uint32(record[4:])) * unit)
+		captured := order.Uint32(record[8:])
+		if captured > captureMaxLength {
+			return ErrCaptureInvalid
+		}
+		frame := make([]byte, captured)
+		if _, readErr := io.ReadFull(r, frame); readErr != nil {
+			return io.ErrUnexpectedEOF
+		}
+		packet(at, linkType, frame)
+	}
+}
+
+// pcapngInterface is what a pcapng Interface Description Block says about
+// the packets captured on it.
+type pcapngInterface struct {
+	linkType uint16
+	// resolution is the timestamp unit as a power of base.
+	base       float64
+	resolution int
+}
+
+func readPcapng(r io.Reader, packet func(at time.Time, linkType uint16, frame []byte)) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	interfaces := []pcapngInterface{}
+	header := make([]byte, 8)
+	for {
+		if _, readErr := io.ReadFull(r, header); readErr != nil {
+			if readErr == io.EOF {
+				return nil
+			}
+			return io.ErrUnexpectedEOF
+		}
+		blockType := order.Uint32(header)
+		if blockType == 0x0A0D0D0A {
+			// A Section Header Block sets the byte order of its section,
+			// which its length is already written in.
+			magic := make([]byte, 4)
+			if _, readErr := io.ReadFull(r, magic); readErr != nil {
+				return io.ErrUnexpectedEOF
+			}
+			switch {
+			case binary.LittleEndian.Uint32(magic) == 0x1A2B3C4D:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(magic) == 0x1A2B3C4D:
+				order = binary.BigEndian
+			default:
+				return ErrCaptureInvalid
+			}
+			length := order.Uint32(header[4:])
+			if length < 16 || length%4 != 0 {
+				return ErrCaptureInvalid
+			}
+			if _, skipErr := io.CopyN(io.Discard, r, int64(length-12)); skipErr != nil {
+				return io.ErrUnexpectedEOF
+			}
+			interfaces = interfaces[:0]
+			continue
+		}
+		length := order.Uint32(header[4:])
+		if length < 12 || length%4 != 0 || length > captureMaxLength {
+			return ErrCaptureInvalid
+		}
+		body := make([]byte, length-8)
+		if _, readErr := io.ReadFull(r, body); readErr != nil {
+			return io.ErrUnexpectedEOF
+		}
+		body = body[:len(body)-4]
+		switch blockType {
+		case 1:
+			// Interface Description Block.
+			if len(body) < 8 {
+				return ErrCaptureInvalid
+			}
+			iface := pcapngInterface{linkType: order.Uint16(body), base: 10, resolution: 6}
+			for options := body[8:]; len(options) >= 4; {
+				code, optionLength := order.Uint16(options), int(order.Uint16(options[2:]))
+				if code == 0 || 4+optionLength > len(options) {
+					break
+				}
+				if code == 9 && optionLength == 1 {
+					// if_tsresol: a power of ten, or of two with the top bit set.
+					iface.resolution = int(options[4] & 0x7F)
+					if options[4]&0x80 != 0 {
+						iface.base = 2
+					}
+				}
+				options = options[min(4+(optionLength+3)/4*4, len(options)):]
+			}
+			interfaces = append(interfaces, iface)
+		case 6:
+			// Enhanced Packet Block.
+			if len(body) < 20 {
+				return ErrCaptureInvalid
+			}
+			index, captured := order.Uint32(body), order.Uint32(body[12:])
+			if int(index) >= len(interfaces) || int(captured) > len(body)-20 {
+				return ErrCaptureInvalid
+			}
+			iface := interfaces[index]
+			timestamp := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			packet(iface.time(timestamp), iface.linkType, body[20:20+captured])
+		case 3:
+			// Simple Packet Block: no timestamp, always the first interface.
+			if len(body) < 4 || len(interfaces) == 0 {
+				return ErrCaptureInvalid
+			}
+			captured := min(int(order.Uint32(body)), len(body)-4)
+			packet(time.Time{}, interfaces[0].linkType, body[4:4+captured])
+		case 2:
+			// Packet Block, made obsolete by the Enhanced Packet Block.
+			if len(body) < 20 {
+				return ErrCaptureInvalid
+			}
+			index, captured := order.Uint16(body), order.Uint32(body[12:])
+			if int(index) >= len(interfaces) || int(captured) > len(body)-20 {
+				return ErrCaptureInvalid
+			}
+			iface := interfaces[index]
+			timestamp := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			packet(iface.time(timestamp), iface.linkType, body[20:20+captured])
+		}
+	}
+}
+
+func (iface pcapngInterface) time(timestamp uint64) time.Time {
+	if iface.base == 10 && iface.resolution <= 9 {
+		scale := uint64(math.Pow10(9 - iface.resolution))
+		return time.Unix(0, 0).Add(time.Duration(timestamp/uint64(math.Pow10(iface.resolution)))*time.Second +
+			time.Duration(timestamp%uint64(math.Pow10(iface.resolution))*scale))
+	}
+	seconds := float64(timestamp) / math.Pow(iface.base, float64(iface.resolution))
+	return time.Unix(0, 0).Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func supportedLinkType(linkType uint16) bool {
+	switch linkType {
+	case linkTypeNull, linkTypeEthernet, linkTypeRaw, linkTypeLoop, linkTypeLinuxSLL, linkTypeIPv4, linkTypeIPv6, linkTypeSLL2:
+		return true
+	}
+	return false
+}
+
+// networkLayer strips the link layer header off frame, returning the IP
+// packet it carries, or nil.
+func networkLayer(linkType uint16, frame []byte) []byte {
+	switch linkType {
+	case linkTypeNull, linkTypeLoop:
+		// The address family, in the byte order of the capturing host.
+		if len(frame) < 4 {
+			return nil
+		}
+		return frame[4:]
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil
+		}
+		etherType, payload := binary.BigEndian.Uint16(frame[12:]), frame[14:]
+		// VLAN tags.
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(payload) >= 4 {
+			etherType, payload = binary.BigEndian.Uint16(payload[2:]), payload[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86DD {
+			return nil
+		}
+		return payload
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return frame
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return nil
+		}
+		return frame[16:]
+	case linkTypeSLL2:
+		if len(frame) < 20 {
+			return nil
+		}
+		return frame[20:]
+	}
+	return nil
+}
+
+// tcpSegment is the part of a TCP segment that reassembly needs.
+type tcpSegment struct {
+	source, destination netip.AddrPort
+	sequence            uint32
+	syn, ack, fin, rst  bool
+	payload             []byte
+}
+
+// transportLayer reads the TCP segment in an IP packet.
+func transportLayer(packet []byte) (tcpSegment, bool) {
+	if len(packet) < 1 {
+		return tcpSegment{}, false
+	}
+	var source, destination netip.Addr
+	var payload []byte
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return tcpSegment{}, false
+		}
+		headerLength, totalLength := int(packet[0]&0x0F)*4, int(binary.BigEndian.Uint16(packet[2:]))
+		fragment := binary.BigEndian.Uint16(packet[6:])
+		if packet[9] != 6 || fragment&0x3FFF != 0 || headerLength < 20 || totalLength < headerLength || totalLength > len(packet) {
+			return tcpSegment{}, false
+		}
+		source, destination = netip.AddrFrom4([4]byte(packet[12:16])), netip.AddrFrom4([4]byte(packet[16:20]))
+		payload = packet[headerLength:totalLength]
+	case 6:
+		if len(packet) < 40 {
+			return tcpSegment{}, false
+		}
+		next, length := packet[6], int(binary.BigEndian.Uint16(packet[4:]))
+		if 40+length > len(packet) {
+			return tcpSegment{}, false
+		}
+		source, destination = netip.AddrFrom16([16]byte(packet[8:24])), netip.AddrFrom16([16]byte(packet[24:40]))
+		payload = packet[40 : 40+length]
+		// Hop-by-hop, routing and destination options headers.
+		for (next == 0 || next == 43 || next == 60) && len(payload) >= 8 {
+			extension := (int(payload[1]) + 1) * 8
+			if extension > len(payload) {
+				return tcpSegment{}, false
+			}
+			next, payload = payload[0], payload[extension:]
+		}
+		if next != 6 {
+			return tcpSegment{}, false
+		}
+	default:
+		return tcpSegment{}, false
+	}
+	if len(payload) < 20 {
+		return tcpSegment{}, false
+	}
+	offset := int(payload[12]>>4) * 4
+	if offset < 20 || offset > len(payload) {
+		return tcpSegment{}, false
+	}
+	flags := payload[13]
+	return tcpSegment{
+		source:      netip.AddrPortFrom(source, binary.BigEndian.Uint16(payload)),
+		destination: netip.AddrPortFrom(destination, binary.BigEndian.Uint16(payload[2:])),
+		sequence:    binary.BigEndian.Uint32(payload[4:]),
+		fin:         flags&0x01 != 0,
+		syn:         flags&0x02 != 0,
+		rst:         flags&0x04 != 0,
+		ack:         flags&0x10 != 0,
+		payload:     payload[offset:],
+	}, true
+}
+
+// captureDissector sorts the segments of a capture into conversations.
+type captureDissector struct {
+	port          uint16
+	handle        func(Dissection)
+	conversations map[[2]netip.AddrPort]*dissectConversation
+	order         []*dissectConversation
+	last          time.Time
+}
+
+func (dissector *captureDissector) packet(at time.Time, linkType uint16, frame []byte) {
+	segment, ok := transportLayer(networkLayer(linkType, frame))
+	if !ok {
+		return
+	}
+	dissector.last = at
+	var client, server netip.AddrPort
+	switch dissector.port {
+	case segment.destination.Port():
+		client, server = segment.source, segment.destination
+	case segment.source.Port():
+		client, server = segment.destination, segment.source
+	default:
+		return
+	}
+	fromServer := segment.source == server
+	key := [2]netip.AddrPort{client, server}
+	conversation, exists := dissector.conversations[key]
+	if exists && segment.syn && !segment.ack && !fromServer {
+		// The client port is being reused for a new connection.
+		for _, stream := range conversation.streams {
+			stream.finish(at)
+		}
+		exists = false
+	}
+	if !exists {
+		conversation = &dissectConversation{
+			client: addrString(client),
+			server: addrString(server),
+			names:  map[[2]byte]string{},
+			handle: dissector.handle,
+		}
+		conversation.streams[0] = &dissectStream{conversation: conversation}
+		conversation.streams[1] = &dissectStream{conversation: conversation, fromServer: true}
+		dissector.conversations[key] = conversation
+		dissector.order = append(dissector.order, conversation)
+	}
+	stream := conversation.streams[0]
+	if fromServer {
+		stream = conversation.streams[1]
+	}
+	stream.segment(at, segment)
+}
+
+func addrString(addr netip.AddrPort) string {
+	return net.JoinHostPort(addr.Addr().Unmap().String(), strconv.Itoa(int(addr.Port())))
+}
+
+// segment adds a TCP segment to the stream, in sequence order.
+func (stream *dissectStream) segment(at time.Time, segment tcpSegment) {
+	if segment.syn {
+		stream.started, stream.next = true, segment.sequence+1
+		stream.buffer, stream.unknown, stream.ahead = nil, nil, nil
+		return
+	}
+	if !stream.started {
+		// The capture began after the connection did.
+		stream.started, stream.next = true, segment.sequence
+	}
+	if len(segment.payload) > 0 {
+		if stream.ahead == nil {
+			stream.ahead = map[uint32][]byte{}
+		}
+		if _, exists := stream.ahead[segment.sequence]; !exists || len(segment.payload) > len(stream.ahead[segment.sequence]) {
+			stream.ahead[segment.sequence] = append([]byte{}, segment.payload...)
+		}
+		stream.reassemble(at)
+		if len(stream.ahead) > dissectMaxAhead {
+			stream.skipGap(at)
+		}
+	}
+	if segment.fin || segment.rst {
+		stream.finish(at)
+	}
+}
+
+// reassemble moves every segment that continues the stream from ahead into
+// the buffer and decodes it.
+func (stream *dissectStream) reassemble(at time.Time) {
+	for progressed := true; progressed; {
+		progressed = false
+		for sequence, payload := range stream.ahead {
+			offset := int32(sequence - stream.next)
+			if offset > 0 {
+				continue
+			}
+			delete(stream.ahead, sequence)
+			if int(-offset) < len(payload) {
+				// Retransmissions may overlap what the stream already has.
+				stream.buffer = append(stream.buffer, payload[-offset:]...)
+				stream.next += uint32(len(payload) + int(offset))
+			}
+			progressed = true
+		}
+	}
+	stream.decode(at, false)
+}
+
+// skipGap gives up on the bytes missing before the earliest segment still
+// ahead of the stream.
+func (stream *dissectStream) skipGap(at time.Time) {
+	if len(stream.ahead) == 0 {
+		return
+	}
+	first := true
+	var earliest uint32
+	for sequence := range stream.ahead {
+		if first || int32(sequence-earliest) < 0 {
+			earliest, first = sequence, false
+		}
+	}
+	// Whatever message was in progress cannot be completed.
+	stream.decode(at, true)
+	stream.emit(Dissection{Time: at, Missing: int(earliest - stream.next)})
+	stream.next = earliest
+	stream.reassemble(at)
+}
+
+// finish decodes whatever is left of the stream once it has ended.
+func (stream *dissectStream) finish(at time.Time) {
+	for len(stream.ahead) > 0 {
+		stream.skipGap(at)
+	}
+	stream.decode(at, true)
+}
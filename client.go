@@ -1,24 +1,555 @@
 package ntgo
 
-import "net"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"sync"
+	"time"
+)
+
+const (
+	// KeepAliveInterval is how long a client lets the connection sit idle
+	// before sending a Keep Alive message.
+	KeepAliveInterval = time.Second
+)
+
+var (
+	ErrProtocolUnsupported = errors.New("client: server does not support protocol revision 3.0")
+	ErrHandshakeInvalid    = errors.New("client: unexpected message during handshake")
+)
 
 type client struct {
-	conn *net.Conn
+	table      *Table
+	outbox     *outbox
+	updateRate time.Duration
+	identity   string
+	timeout    time.Duration
+	backoff    Backoff
+	dialer     Dialer
+	tls        *tls.Config
+	log        *log.Logger
+
+	// team holds the addresses tried in parallel for a team number. When nil,
+	// the client rotates through endpoints one at a time instead.
+	team      []string
+	endpoints []string
+	next      int
+
+	conns  *connections
+	connID int
+
+	// offline holds the names of entries changed or deleted locally while
+	// the client was not connected. They are sent to the server on the
+	// next connection even if it has seen the client before.
+	offlineMu      sync.Mutex
+	offline        map[string]bool
+	offlineWatcher int
+
+	// allowRevision2 lets the client fall back to revision 2.0, and
+	// supported is the revision the last server that refused the client
+	// said it speaks.
+	allowRevision2 bool
+	supported      ProtocolRevision
+	recorder       *Recorder
+
+	writeMu   sync.Mutex
+	conn      io.ReadWriteCloser
+	endpoint  string
+	wire      wire
+	lastWrite time.Time
+
+	rpcMu    sync.Mutex
+	rpcID    uint16
+	rpcCalls map[[2]byte]chan []byte
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
+// Initialize tries each endpoint once and fails if none of them completes
+// the handshake. Once connected, the client reconnects by itself whenever
+// the connection drops, until it is closed.
 func (cl *client) Initialize(nt NetworkTables) error {
-	conn, connErr := net.Dial("tcp", net.JoinHostPort(nt.Address, nt.Port))
-	if connErr != nil {
-		return connErr
+	cl.table = nt.Table()
+	cl.outbox = newOutbox()
+	cl.updateRate = nt.updateRate()
+	cl.identity = nt.identity()
+	cl.timeout = nt.timeout()
+	cl.backoff = nt.Backoff
+	cl.dialer = nt.dialer()
+	cl.tls = nt.TLS
+	cl.allowRevision2 = nt.AllowRevision2
+	cl.recorder = nt.Recorder
+	cl.log = nt.logger()
+	cl.conns = nt.conns
+	cl.endpoints = nt.endpoints()
+	if nt.Team > 0 {
+		cl.team = nt.teamCandidates()
+	}
+	cl.rpcCalls = map[[2]byte]chan []byte{}
+	cl.done = make(chan struct{})
+	cl.offline = map[string]bool{}
+	for _, entry := range cl.table.Entries("") {
+		cl.offline[entry.Name.Value] = true
+	}
+	cl.offlineWatcher = cl.table.AddListener("", cl.watchOffline)
+	attempts := len(cl.endpoints)
+	if cl.team != nil {
+		attempts = 1
+	}
+	var reader *bufio.Reader
+	var connectErr error
+	for range attempts {
+		reader, connectErr = cl.connect()
+		if connectErr == nil {
+			break
+		}
+	}
+	if connectErr != nil {
+		cl.table.RemoveListener(cl.offlineWatcher)
+		return connectErr
+	}
+	go cl.run(reader)
+	go cl.writeLoop()
+	return nil
+}
+
+// connect dials the next endpoint and runs the handshake on it. A server
+// that only speaks revision 2.0 is dialed again in that revision if the
+// client allows it.
+func (cl *client) connect() (*bufio.Reader, error) {
+	conn, endpoint, dialErr := cl.dial()
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	w := cl.wireFor(ProtocolRevision3)
+	conn, reader, serverIdentity, openErr := cl.open(conn, endpoint, w)
+	if openErr == ErrProtocolUnsupported && cl.allowRevision2 && cl.supported == ProtocolRevision2 {
+		ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
+		conn, dialErr = cl.dialer.Dial(ctx, endpoint)
+		cancel()
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		w = cl.wireFor(ProtocolRevision2)
+		conn, reader, serverIdentity, openErr = cl.open(conn, endpoint, w)
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+	cl.writeMu.Lock()
+	cl.conn, cl.endpoint, cl.wire = conn, endpoint, w
+	cl.writeMu.Unlock()
+	cl.connID = cl.conns.add(ConnectionInfo{
+		RemoteIdentity:   serverIdentity,
+		RemoteAddr:       endpoint,
+		ProtocolRevision: w.revision,
+		Connected:        time.Now(),
+	})
+	cl.log.Printf("ntgo: connected to %s", endpoint)
+	return reader, nil
+}
+
+// open sets up TLS, if configured, and runs the handshake on a freshly
+// dialed connection. The connection is closed if either fails.
+func (cl *client) open(conn io.ReadWriteCloser, endpoint string, w wire) (io.ReadWriteCloser, *bufio.Reader, string, error) {
+	if cl.tls != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
+		tlsConn, tlsErr := clientTLS(ctx, conn, cl.tls, endpoint)
+		cancel()
+		if tlsErr != nil {
+			conn.Close()
+			return nil, nil, "", tlsErr
+		}
+		conn = tlsConn
+	}
+	cl.table.unassignIDs()
+	cl.outbox.drain()
+	setDeadline(conn, time.Now().Add(cl.timeout))
+	reader := bufio.NewReader(conn)
+	serverIdentity, handshakeErr := cl.handshake(conn, reader, w)
+	if handshakeErr != nil {
+		conn.Close()
+		return nil, nil, "", handshakeErr
+	}
+	setDeadline(conn, time.Time{})
+	return conn, reader, serverIdentity, nil
+}
+
+func (cl *client) wireFor(revision ProtocolRevision) wire {
+	return wire{
+		revision: revision,
+		typeOf: func(id [2]byte) (EntryType, bool) {
+			entry, ok := cl.table.GetByID(id)
+			return entry.Type, ok
+		},
+		recorder: cl.recorder,
+		session:  cl.recorder.session(),
+	}
+}
+
+// dial opens a connection to the team candidates, or else to the next
+// endpoint in the rotation.
+func (cl *client) dial() (io.ReadWriteCloser, string, error) {
+	if cl.team != nil {
+		return dialFirst(cl.dialer, cl.team, cl.timeout)
+	}
+	endpoint := cl.endpoints[cl.next]
+	cl.next = (cl.next + 1) % len(cl.endpoints)
+	ctx, cancel := context.WithTimeout(context.Background(), cl.timeout)
+	defer cancel()
+	conn, dialErr := cl.dialer.Dial(ctx, endpoint)
+	return conn, endpoint, dialErr
+}
+
+// run reads from the connection until it drops, then reconnects, waiting
+// between attempts as the backoff policy says.
+func (cl *client) run(reader *bufio.Reader) {
+	for {
+		cl.readLoop(reader)
+		cl.writeMu.Lock()
+		cl.conn.Close()
+		lost := cl.endpoint
+		cl.conn, cl.endpoint = nil, ""
+		cl.writeMu.Unlock()
+		cl.conns.remove(cl.connID)
+		select {
+		case <-cl.done:
+			return
+		default:
+		}
+		cl.log.Printf("ntgo: lost connection to %s", lost)
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-cl.done:
+				return
+			case <-time.After(cl.backoff.Delay(attempt)):
+			}
+			var connectErr error
+			reader, connectErr = cl.connect()
+			if connectErr == nil {
+				break
+			}
+		}
+		select {
+		case <-cl.done:
+			// Close ran while connecting and found no connection to close,
+			// so close the new one here; readLoop then returns at once.
+			cl.writeMu.Lock()
+			cl.conn.Close()
+			cl.writeMu.Unlock()
+		default:
+		}
+	}
+}
+
+// watchOffline records local changes made while disconnected.
+func (cl *client) watchOffline(event EntryEvent) {
+	if !event.Local || cl.Endpoint() != "" {
+		return
+	}
+	cl.offlineMu.Lock()
+	cl.offline[event.Entry.Name.Value] = true
+	cl.offlineMu.Unlock()
+}
+
+// handshake runs the connection procedure from the Client side: it announces
+// itself, takes in every entry the server knows about, then announces the
+// local entries the server did not mention. It returns the identity of the
+// server.
+//
+// How the two sides are reconciled depends on whether the server has seen
+// the client before. A new client publishes its local entries, and its
+// values win over the ones the server announces. A returning client defers
+// to the server: it takes the announced values and drops local entries the
+// server no longer has, except for entries changed or deleted locally while
+// it was disconnected, which are sent to the server.
+func (cl *client) handshake(conn io.ReadWriteCloser, reader *bufio.Reader, w wire) (string, error) {
+	helloErr := w.send(conn, BuildMessage(&MessageDataClientHello{
+		ProtocVersion: w.revision,
+		Identity:      BuildString(cl.identity),
+	}))
+	if helloErr != nil {
+		return "", helloErr
+	}
+	// Listeners run while the table is brought up to date, and may change
+	// it again; those changes are recorded for the next connection.
+	cl.offlineMu.Lock()
+	offline := cl.offline
+	cl.offline = map[string]bool{}
+	cl.offlineMu.Unlock()
+	synced := false
+	defer func() {
+		if !synced {
+			cl.offlineMu.Lock()
+			maps.Copy(cl.offline, offline)
+			cl.offlineMu.Unlock()
+		}
+	}()
+	serverIdentity := ""
+	seen := false
+	announced := map[string]bool{}
+	for {
+		message, decodeErr := w.decode(reader)
+		if decodeErr != nil {
+			return "", decodeErr
+		}
+		switch data := message.Data.(type) {
+		case *MessageDataProtocVersionUnsupported:
+			cl.supported = data.SupportedProtoc
+			return "", ErrProtocolUnsupported
+		case *MessageDataServerHello:
+			serverIdentity = data.Identity.Value
+			seen = data.Flags == FlagMessageClientSeen
+		case *MessageDataKeepAlive:
+		case *MessageDataEntryAssignment:
+			name := data.Entry.Name.Value
+			announced[name] = true
+			if _, exists := cl.table.Get(name); !exists && offline[name] {
+				cl.outbox.push(BuildMessage(&MessageDataEntryDelete{Entry: data.Entry}))
+				continue
+			}
+			cl.handleAssignment(*data.Entry, !seen || offline[name])
+		case *MessageDataServerHelloComplete:
+			for _, entry := range cl.table.Entries("") {
+				name := entry.Name.Value
+				if announced[name] || entry.Type == EntryTypeRPCDef {
+					continue
+				}
+				if seen && !offline[name] {
+					cl.table.Delete(name, false)
+					continue
+				}
+				cl.outbox.push(assignmentRequest(entry))
+			}
+			cl.outbox.push(BuildMessage(&MessageDataClientHelloComplete{}))
+			sendErr := w.send(conn, cl.outbox.drain()...)
+			synced = sendErr == nil
+			return serverIdentity, sendErr
+		default:
+			return "", ErrHandshakeInvalid
+		}
+	}
+}
+
+func (cl *client) readLoop(reader *bufio.Reader) {
+	cl.writeMu.Lock()
+	w := cl.wire
+	cl.writeMu.Unlock()
+	for {
+		message, decodeErr := w.decode(reader)
+		if decodeErr != nil {
+			return
+		}
+		cl.conns.touch(cl.connID)
+		cl.handle(message)
+	}
+}
+
+func (cl *client) writeLoop() {
+	ticker := time.NewTicker(cl.updateRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cl.done:
+			return
+		case <-ticker.C:
+		}
+		cl.writeMu.Lock()
+		idle := time.Since(cl.lastWrite)
+		cl.writeMu.Unlock()
+		if idle >= KeepAliveInterval {
+			cl.outbox.push(BuildMessage(&MessageDataKeepAlive{}))
+		}
+		cl.flush()
+	}
+}
+
+// handle applies a message received from the server to the local table.
+func (cl *client) handle(message *Message) {
+	switch data := message.Data.(type) {
+	case *MessageDataEntryAssignment:
+		cl.handleAssignment(*data.Entry, true)
+	case *MessageDataEntryUpdate:
+		local, ok := cl.table.GetByID(data.Entry.ID)
+		if !ok || local.Type != data.Entry.Type || SequenceNewer(local.Sequence, data.Entry.Sequence) {
+			return
+		}
+		local.Sequence = data.Entry.Sequence
+		local.Value = data.Entry.Value
+		cl.table.Assign(local, false)
+	case *MessageDataEntryFlagsUpdate:
+		local, ok := cl.table.GetByID(data.Entry.ID)
+		if ok {
+			cl.table.SetFlags(local.Name.Value, data.Entry.Flags, false)
+		}
+	case *MessageDataEntryDelete:
+		local, ok := cl.table.GetByID(data.Entry.ID)
+		if ok {
+			cl.table.Delete(local.Name.Value, false)
+		}
+	case *MessageDataClearAll:
+		if data.PotentialMagic == DangerousMagic {
+			cl.table.Clear(false)
+		}
+	case *MessageDataRPCResponse:
+		cl.rpcMu.Lock()
+		call, ok := cl.rpcCalls[data.UniqueID]
+		delete(cl.rpcCalls, data.UniqueID)
+		cl.rpcMu.Unlock()
+		if ok {
+			call <- data.Results
+		}
+	}
+}
+
+// handleAssignment stores an assignment from the server. If the entry exists
+// locally without an ID and keepLocal is set, the local value and flags win
+// and are sent back to the server.
+func (cl *client) handleAssignment(assigned Entry, keepLocal bool) {
+	local, ok := cl.table.Get(assigned.Name.Value)
+	if !ok || !keepLocal || local.ID != EntryIDUnassigned || local.Type != assigned.Type {
+		cl.table.Assign(assigned, false)
+		return
+	}
+	merged := assigned
+	merged.Value = local.Value
+	merged.Flags = local.Flags
+	if !bytes.Equal(local.Value.GetRaw(), assigned.Value.GetRaw()) {
+		merged.Sequence = nextSequence(assigned.Sequence)
+		cl.outbox.push(BuildMessage(&MessageDataEntryUpdate{Entry: &merged}))
+	}
+	if local.Flags != assigned.Flags {
+		cl.outbox.push(BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &merged}))
+	}
+	cl.table.Assign(merged, false)
+}
+
+// flush writes the buffered messages. While disconnected they stay
+// buffered; the handshake on the next connection replaces them.
+func (cl *client) flush() error {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+	if cl.conn == nil {
+		return ErrNotConnected
+	}
+	messages := cl.outbox.drain()
+	if len(messages) == 0 {
+		return nil
+	}
+	writeErr := cl.wire.send(cl.conn, messages...)
+	cl.lastWrite = time.Now()
+	if writeErr != nil {
+		// Closing the connection hands reconnecting over to run.
+		cl.conn.Close()
+	}
+	return writeErr
+}
+
+// Endpoint returns the address of the server the client is connected to, or
+// an empty string while it is disconnected.
+func (cl *client) Endpoint() string {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+	return cl.endpoint
+}
+
+func (cl *client) CreateEntry(entry Entry) error {
+	cl.outbox.push(assignmentRequest(entry))
+	return nil
+}
+
+func (cl *client) UpdateEntry(entry Entry) error {
+	if entry.ID == EntryIDUnassigned {
+		// The latest value goes out once the server assigns an ID.
+		return nil
+	}
+	cl.outbox.push(BuildMessage(&MessageDataEntryUpdate{Entry: &entry}))
+	return nil
+}
+
+func (cl *client) UpdateEntryFlags(entry Entry) error {
+	if entry.ID == EntryIDUnassigned {
+		return nil
 	}
-	cl.conn = &conn
+	cl.outbox.push(BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &entry}))
 	return nil
 }
 
-func (cl *client) CreateEntry(entry Entry) error { return nil }
+func (cl *client) DeleteEntry(entry Entry) error {
+	if entry.ID == EntryIDUnassigned {
+		return nil
+	}
+	cl.outbox.push(BuildMessage(&MessageDataEntryDelete{Entry: &entry}))
+	return nil
+}
 
-func (cl *client) UpdateEntry(entry Entry) error { return nil }
+func (cl *client) GetEntry(id [2]byte) error { return nil }
+
+func (cl *client) CallRPC(ctx context.Context, id [2]byte, params []byte) ([]byte, error) {
+	cl.writeMu.Lock()
+	revision := cl.wire.revision
+	cl.writeMu.Unlock()
+	if revision == ProtocolRevision2 {
+		return nil, ErrProtocolUnsupported
+	}
+	response := make(chan []byte, 1)
+	cl.rpcMu.Lock()
+	cl.rpcID++
+	uniqueID := [2]byte{byte(cl.rpcID >> 8), byte(cl.rpcID)}
+	cl.rpcCalls[uniqueID] = response
+	cl.rpcMu.Unlock()
+	defer func() {
+		cl.rpcMu.Lock()
+		delete(cl.rpcCalls, uniqueID)
+		cl.rpcMu.Unlock()
+	}()
+	cl.outbox.push(BuildMessage(&MessageDataRPCExecute{
+		EntryID:     id,
+		UniqueID:    uniqueID,
+		ParamLength: uint32(len(params)),
+		Params:      params,
+	}))
+	if flushErr := cl.flush(); flushErr != nil {
+		return nil, flushErr
+	}
+	select {
+	case results := <-response:
+		return results, nil
+	case <-cl.done:
+		return nil, ErrNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-func (cl *client) DeleteEntry(entry Entry) error { return nil }
+// Close flushes any buffered changes, closes the connection and stops
+// reconnecting.
+func (cl *client) Close() error {
+	var closeErr error
+	cl.closeOnce.Do(func() {
+		cl.table.RemoveListener(cl.offlineWatcher)
+		cl.flush()
+		close(cl.done)
+		cl.writeMu.Lock()
+		if cl.conn != nil {
+			closeErr = cl.conn.Close()
+		}
+		cl.writeMu.Unlock()
+	})
+	return closeErr
+}
 
-func (cl *client) GetEntry(id [2]byte) error { return nil }
\ No newline at end of file
+// assignmentRequest returns the Entry Assignment a client sends to ask the
+// server to create entry.
+func assignmentRequest(entry Entry) *Message {
+	entry.ID = EntryIDUnassigned
+	entry.Sequence = [2]byte{}
+	return BuildMessage(&MessageDataEntryAssignment{Entry: &entry})
+}
@@ -0,0 +1,206 @@
+package ntgo
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts a single client on a local port and hands the
+// connection to script.
+func fakeServer(t *testing.T, script func(conn net.Conn, reader *bufio.Reader)) *NetworkTables {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close()
+		script(conn, bufio.NewReader(conn))
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return &NetworkTables{
+		Address:    host,
+		Port:       port,
+		Mode:       ModeClient,
+		Identity:   "test",
+		UpdateRate: 10 * time.Millisecond,
+	}
+}
+
+// readMessage returns the next message that is not a Keep Alive.
+func readMessage(conn net.Conn, reader *bufio.Reader) (*Message, error) {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		message, err := DecodeMessage(reader)
+		if err != nil || message.Type != MessageTypeKeepAlive {
+			return message, err
+		}
+	}
+}
+
+func writeMessages(conn net.Conn, messages ...*Message) {
+	for _, message := range messages {
+		conn.Write(message.GetRaw())
+	}
+}
+
+func TestClientHandshakeAndSync(t *testing.T) {
+	received := make(chan *Message, 8)
+	nt := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		hello, err := readMessage(conn, reader)
+		if err != nil {
+			return
+		}
+		received <- hello
+		writeMessages(conn,
+			BuildMessage(&MessageDataServerHello{Flags: FlagMessageClientNew, Identity: BuildString("server")}),
+			BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{
+				Name:     BuildString("/robot/mode"),
+				Type:     EntryTypeString,
+				ID:       [2]byte{0x00, 0x01},
+				Sequence: [2]byte{0x00, 0x01},
+				Value:    BuildString("auto"),
+			}}),
+			BuildMessage(&MessageDataServerHelloComplete{}),
+		)
+		for i := 0; i < 3; i++ {
+			message, err := readMessage(conn, reader)
+			if err != nil {
+				return
+			}
+			received <- message
+		}
+		writeMessages(conn, BuildMessage(&MessageDataEntryDelete{Entry: &Entry{ID: [2]byte{0x00, 0x01}}}))
+		readMessage(conn, reader)
+	})
+	nt.Double("/dash/local").Set(1)
+	if err := nt.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer nt.Close()
+
+	hello := (<-received).Data.(*MessageDataClientHello)
+	if hello.ProtocVersion != ProtocolRevision3 || hello.Identity.Value != "test" {
+		t.Fatalf("Unexpected client hello %v", hello)
+	}
+	if mode := nt.String("/robot/mode").GetOr(""); mode != "auto" {
+		t.Fatalf("Expected server entry to be synced but got %q", mode)
+	}
+	assignment, ok := (<-received).Data.(*MessageDataEntryAssignment)
+	if !ok || assignment.Entry.Name.Value != "/dash/local" || assignment.Entry.ID != EntryIDUnassigned {
+		t.Fatalf("Expected assignment request for local entry but got %v", assignment)
+	}
+	if message := <-received; message.Type != MessageTypeClientHelloComplete {
+		t.Fatalf("Expected client hello complete but got type %d", message.Type)
+	}
+
+	if err := nt.String("/robot/mode").Set("teleop"); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	update, ok := (<-received).Data.(*MessageDataEntryUpdate)
+	if !ok {
+		t.Fatal("Expected entry update")
+	}
+	if update.Entry.ID != [2]byte{0x00, 0x01} || update.Entry.Sequence != [2]byte{0x00, 0x02} {
+		t.Fatalf("Unexpected update id %v sequence %v", update.Entry.ID, update.Entry.Sequence)
+	}
+	if update.Entry.Value.(*ValueString).Value != "teleop" {
+		t.Fatalf("Unexpected update value %s", update.Entry.Value)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := nt.Table().Get("/robot/mode"); !ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Expected server delete to remove the entry")
+}
+
+func TestClientProtocolUnsupported(t *testing.T) {
+	nt := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		readMessage(conn, reader)
+		writeMessages(conn, BuildMessage(&MessageDataProtocVersionUnsupported{SupportedProtoc: ProtocolRevision{0x02, 0x00}}))
+	})
+	if err := nt.Initialize(); err != ErrProtocolUnsupported {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrProtocolUnsupported, err)
+	}
+}
+
+func TestOutboxCoalescesUpdates(t *testing.T) {
+	box := newOutbox()
+	first := Entry{ID: [2]byte{0x00, 0x01}, Type: EntryTypeDouble, Value: BuildDouble(1)}
+	second := Entry{ID: [2]byte{0x00, 0x01}, Type: EntryTypeDouble, Value: BuildDouble(2)}
+	box.push(BuildMessage(&MessageDataEntryUpdate{Entry: &first}))
+	box.push(BuildMessage(&MessageDataKeepAlive{}))
+	box.push(BuildMessage(&MessageDataEntryUpdate{Entry: &second}))
+	messages := box.drain()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages but got %d", len(messages))
+	}
+	update := messages[0].Data.(*MessageDataEntryUpdate)
+	if update.Entry.Value.(*ValueDouble).Value != 2 {
+		t.Fatalf("Expected latest value but got %s", update.Entry.Value)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, delay := range expected {
+		if result := backoff.Delay(attempt); result != delay*time.Millisecond {
+			t.Fatalf("Expected %s for attempt %d but got %s", delay*time.Millisecond, attempt, result)
+		}
+	}
+	if result := (Backoff{}).Delay(0); result != DefaultBackoff.Initial {
+		t.Fatalf("Expected %s but got %s", DefaultBackoff.Initial, result)
+	}
+}
+
+func TestClientFailover(t *testing.T) {
+	first := startServer(t, &NetworkTables{})
+	second := startServer(t, &NetworkTables{})
+	closed, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("Unexpected error! %s", listenErr)
+	}
+	closed.Close()
+	firstAddr := first.ListenAddrs()[0].String()
+	secondAddr := second.ListenAddrs()[0].String()
+	cl := &NetworkTables{
+		Mode:       ModeClient,
+		Servers:    []string{closed.Addr().String(), firstAddr, secondAddr},
+		UpdateRate: 5 * time.Millisecond,
+		Backoff:    Backoff{Initial: 5 * time.Millisecond, Max: 20 * time.Millisecond},
+	}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if cl.Endpoint() != firstAddr {
+		t.Fatalf("Expected endpoint %s but got %s", firstAddr, cl.Endpoint())
+	}
+	cl.Double("/robot/speed").Set(1)
+	waitFor(t, "entry to reach the first server", func() bool {
+		return first.Double("/robot/speed").GetOr(0) == 1
+	})
+
+	first.Close()
+	waitFor(t, "client to fail over", func() bool {
+		return cl.Endpoint() == secondAddr
+	})
+	waitFor(t, "entry to be re-created on the second server", func() bool {
+		return second.Double("/robot/speed").GetOr(0) == 1
+	})
+	cl.Double("/robot/speed").Set(2)
+	waitFor(t, "update to reach the second server", func() bool {
+		return second.Double("/robot/speed").GetOr(0) == 2
+	})
+}
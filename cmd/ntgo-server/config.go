@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// config is the on-disk configuration of the daemon, stored as JSON.
+type config struct {
+	// Listen is the list of addresses to accept clients on, either host:port
+	// or unix: followed by a socket path.
+	Listen []string `json:"listen"`
+	// Identity is the server name sent to clients.
+	Identity string `json:"identity"`
+	// PersistFile is where persistent entries are kept. Leave it empty to
+	// disable persistence.
+	PersistFile string `json:"persist_file"`
+	// PersistPeriod is how often changed persistent entries are saved.
+	PersistPeriod duration `json:"persist_period"`
+	// UpdateRate is how often buffered changes are sent to clients.
+	UpdateRate duration `json:"update_rate"`
+	// MaxClients and MaxEntries limit connections and client-created
+	// entries. Zero means no limit.
+	MaxClients int `json:"max_clients"`
+	MaxEntries int `json:"max_entries"`
+	// AllowRevision2 lets clients speaking NetworkTables 2.0 connect.
+	AllowRevision2 bool `json:"allow_revision2"`
+	// LogFile is where log messages go: a path, "stderr", "stdout" or "off".
+	LogFile string `json:"log_file"`
+	// TLSCert and TLSKey are PEM files holding the server certificate and
+	// key. TLS is enabled when both are set.
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	// TLSClientCA is a PEM file of certificate authorities. When set,
+	// clients must present a certificate signed by one of them, and its
+	// subject becomes their identity.
+	TLSClientCA string `json:"tls_client_ca"`
+	// HTTP is the address to serve the web dashboard on, along with the
+	// REST API and push socket it uses. Leave it empty to disable it. It is
+	// served over TLS when TLS is enabled.
+	HTTP string `json:"http"`
+	// AllowedOrigins are the origins of other pages, such as
+	// "http://localhost:3000", that may use the push socket. Pages served
+	// by the daemon itself always may.
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// duration is a time.Duration written as a string such as "1s" in JSON.
+type duration time.Duration
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	parsed, parseErr := time.ParseDuration(string(text))
+	if parseErr != nil {
+		return parseErr
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func defaultConfig() *config {
+	return &config{
+		Listen:        []string{":" + ntgo.DefaultPort},
+		Identity:      "ntgo-server",
+		PersistFile:   "networktables.ini",
+		PersistPeriod: duration(ntgo.DefaultPersistPeriod),
+		UpdateRate:    duration(ntgo.DefaultUpdateRate),
+		LogFile:       "stderr",
+	}
+}
+
+// loadConfig reads the configuration at path on top of the defaults. An
+// empty path gives the defaults.
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+	data, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if parseErr := json.Unmarshal(data, cfg); parseErr != nil {
+		return nil, parseErr
+	}
+	return cfg, nil
+}
+
+// logger opens the log destination. The returned closer releases a log
+// file and is a no-op otherwise.
+func (cfg *config) logger() (*log.Logger, io.Closer, error) {
+	var out io.WriteCloser
+	switch cfg.LogFile {
+	case "", "stderr":
+		out = nopCloser{os.Stderr}
+	case "stdout":
+		out = nopCloser{os.Stdout}
+	case "off":
+		out = nopCloser{io.Discard}
+	default:
+		file, openErr := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if openErr != nil {
+			return nil, nil, openErr
+		}
+		out = file
+	}
+	return log.New(out, "", log.LstdFlags), out, nil
+}
+
+// tlsConfig loads the certificates named in the configuration. It returns
+// nil when TLS is not configured.
+func (cfg *config) tlsConfig() (*tls.Config, error) {
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return nil, nil
+	}
+	cert, certErr := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if certErr != nil {
+		return nil, certErr
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if cfg.TLSClientCA != "" {
+		pem, readErr := os.ReadFile(cfg.TLSClientCA)
+		if readErr != nil {
+			return nil, readErr
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", cfg.TLSClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// apply copies the configuration onto nt.
+func (cfg *config) apply(nt *ntgo.NetworkTables, logger *log.Logger, tlsConfig *tls.Config) {
+	nt.Mode = ntgo.ModeServer
+	nt.Listen = cfg.Listen
+	nt.Identity = cfg.Identity
+	nt.PersistFile = cfg.PersistFile
+	nt.PersistPeriod = time.Duration(cfg.PersistPeriod)
+	nt.UpdateRate = time.Duration(cfg.UpdateRate)
+	nt.MaxClients = cfg.MaxClients
+	nt.MaxEntries = cfg.MaxEntries
+	nt.AllowRevision2 = cfg.AllowRevision2
+	nt.Logger = logger
+	nt.TLS = tlsConfig
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
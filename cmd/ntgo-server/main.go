@@ -0,0 +1,161 @@
+// Command ntgo-server runs a standalone NetworkTables server.
+//
+// Usage:
+//
+//	ntgo-server [-config path]
+//
+// The configuration file is JSON:
+//
+//	{
+//	  "listen": [":1735"],
+//	  "identity": "ntgo-server",
+//	  "persist_file": "networktables.ini",
+//	  "persist_period": "1s",
+//	  "update_rate": "100ms",
+//	  "max_clients": 0,
+//	  "max_entries": 0,
+//	  "allow_revision2": false,
+//	  "log_file": "stderr",
+//	  "tls_cert": "",
+//	  "tls_key": "",
+//	  "tls_client_ca": "",
+//	  "http": "",
+//	  "allowed_origins": []
+//	}
+//
+// When http is set to an address such as ":5800", a web dashboard for the
+// entries is served there, with a REST API under /api/ and a WebSocket push
+// socket at /push. Only pages from that address, or from allowed_origins,
+// may open the push socket.
+//
+// SIGTERM and SIGINT save the persistent entries and exit. SIGHUP reloads the
+// configuration file: the server saves its persistent entries, disconnects
+// every client and starts again with the new settings, keeping its entries.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/HowardStark/ntgo"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to the JSON configuration `file`")
+	flag.Parse()
+	if err := run(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath string) error {
+	cfg, configErr := loadConfig(configPath)
+	if configErr != nil {
+		return configErr
+	}
+	nt := &ntgo.NetworkTables{}
+	running, startErr := start(nt, cfg)
+	if startErr != nil {
+		return startErr
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			nt.Logger.Printf("ntgo-server: received %s, shutting down", sig)
+			closeErr := nt.Close()
+			running.Close()
+			return closeErr
+		}
+		reloaded, reloadErr := loadConfig(configPath)
+		if reloadErr != nil {
+			nt.Logger.Printf("ntgo-server: keeping current configuration, reload failed: %s", reloadErr)
+			continue
+		}
+		nt.Logger.Printf("ntgo-server: reloading configuration")
+		if closeErr := nt.Close(); closeErr != nil {
+			nt.Logger.Printf("ntgo-server: saving before reload: %s", closeErr)
+		}
+		running.Close()
+		running, startErr = start(nt, reloaded)
+		if startErr != nil {
+			// The old listeners are already closed, so go back to serving
+			// with the configuration that worked.
+			var restartErr error
+			running, restartErr = start(nt, cfg)
+			if restartErr != nil {
+				return restartErr
+			}
+			nt.Logger.Printf("ntgo-server: keeping current configuration, reload failed: %s", startErr)
+			continue
+		}
+		cfg = reloaded
+	}
+	return nil
+}
+
+// start applies cfg to nt and starts serving. The entries in nt survive
+// across restarts. The returned closer stops the dashboard and releases the
+// log file.
+func start(nt *ntgo.NetworkTables, cfg *config) (io.Closer, error) {
+	tlsConfig, tlsErr := cfg.tlsConfig()
+	if tlsErr != nil {
+		return nil, tlsErr
+	}
+	logger, logFile, logErr := cfg.logger()
+	if logErr != nil {
+		return nil, logErr
+	}
+	cfg.apply(nt, logger, tlsConfig)
+	if initErr := nt.Initialize(); initErr != nil {
+		logFile.Close()
+		return nil, initErr
+	}
+	if cfg.HTTP == "" {
+		return logFile, nil
+	}
+	dashboard, serveErr := serveDashboard(nt, cfg.HTTP, cfg.AllowedOrigins, tlsConfig)
+	if serveErr != nil {
+		nt.Close()
+		logFile.Close()
+		return nil, serveErr
+	}
+	nt.Logger.Printf("ntgo-server: serving the dashboard on %s", cfg.HTTP)
+	return closers{dashboard, logFile}, nil
+}
+
+// serveDashboard serves the web dashboard for nt on address until the
+// returned server is closed.
+func serveDashboard(nt *ntgo.NetworkTables, address string, allowedOrigins []string, tlsConfig *tls.Config) (*http.Server, error) {
+	listener, listenErr := net.Listen("tcp", address)
+	if listenErr != nil {
+		return nil, listenErr
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	server := &http.Server{Handler: ntgo.NewDashboardHandler(nt, allowedOrigins...), ErrorLog: nt.Logger}
+	go server.Serve(listener)
+	return server, nil
+}
+
+// closers closes each of its closers in turn, returning the first error.
+type closers []io.Closer
+
+func (all closers) Close() error {
+	var firstErr error
+	for _, closer := range all {
+		if closeErr := closer.Close(); closeErr != nil && firstErr == nil {
+			firstErr = closeErr
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/HowardStark/ntgo"
+	"github.com/HowardStark/ntgo/nt4"
+)
+
+// runBridge connects to the server as a 3.0 client and serves everything as
+// a 4.0 server, or with --reverse, connects as a 4.0 client and serves a 3.0
+// server, until interrupted.
+func runBridge(opts *options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	remote := opts.client()
+	local := &ntgo.NetworkTables{NewOperator: nt4.NewServer, Listen: []string{":" + nt4.DefaultPort}}
+	if opts.reverse {
+		remote.Port = nt4.DefaultPort
+		remote.NewOperator = nt4.NewClient
+		if opts.team != 0 {
+			remote.Servers = ntgo.TeamAddresses(opts.team)
+		}
+		local = &ntgo.NetworkTables{Mode: ntgo.ModeServer, Listen: []string{":" + ntgo.DefaultPort}}
+	}
+	if len(args) == 1 {
+		local.Listen = []string{args[0]}
+	}
+	if initErr := remote.Initialize(); initErr != nil {
+		return initErr
+	}
+	defer remote.Close()
+	if initErr := local.Initialize(); initErr != nil {
+		return initErr
+	}
+	defer local.Close()
+	bridge := ntgo.NewBridge(remote, local)
+	defer bridge.Close()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	return nil
+}
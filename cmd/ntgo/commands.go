@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+)
+
+var (
+	errFlagsMissing = errors.New("ntgo: flags needs exactly one of --persistent or --temporary")
+	errTypeMissing  = errors.New("ntgo: entry does not exist, so set needs --type")
+)
+
+var eventNames = map[ntgo.EntryEventKind]string{
+	ntgo.EntryEventCreated:      "created",
+	ntgo.EntryEventUpdated:      "updated",
+	ntgo.EntryEventFlagsUpdated: "flags",
+	ntgo.EntryEventDeleted:      "deleted",
+}
+
+func runList(opts *options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	entries := nt.Table().Entries(prefix)
+	if opts.json {
+		return json.NewEncoder(os.Stdout).Encode(entries)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tTYPE\tFLAGS\tVALUE")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", entry.Name, entry.Type, entry.Flags, entry.Value)
+	}
+	return writer.Flush()
+}
+
+func runGet(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	entry, ok := nt.Table().Get(args[0])
+	if !ok {
+		return ntgo.ErrEntryNotFound
+	}
+	if opts.json {
+		return json.NewEncoder(os.Stdout).Encode(entry)
+	}
+	fmt.Println(entry.Value)
+	return nil
+}
+
+func runSet(opts *options, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	name := args[0]
+	var entryType ntgo.EntryType
+	if opts.entryType != "" {
+		parsed, typeErr := ntgo.ParseEntryType(opts.entryType)
+		if typeErr != nil {
+			return typeErr
+		}
+		entryType = parsed
+	} else if existing, ok := nt.Table().Get(name); ok {
+		entryType = existing.Type
+	} else {
+		return errTypeMissing
+	}
+	value, valueErr := parseValue(entryType, args[1:])
+	if valueErr != nil {
+		return valueErr
+	}
+	if setErr := nt.SetValue(name, entryType, value); setErr != nil {
+		return setErr
+	}
+	if opts.persistent || opts.temporary {
+		// Flags can only be sent once the server has assigned the new
+		// entry an ID.
+		if waitErr := waitAssigned(nt, name, opts.timeout); waitErr != nil {
+			return waitErr
+		}
+		return setFlags(opts, nt, name)
+	}
+	return nil
+}
+
+func waitAssigned(nt *ntgo.NetworkTables, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if entry, ok := nt.Table().Get(name); ok && entry.ID != ntgo.EntryIDUnassigned {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return context.DeadlineExceeded
+}
+
+func runWatch(opts *options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	// Listeners run on the connection's read goroutine, so events are only
+	// queued there, never waited on. Queueing starts before the snapshot is
+	// taken so that no change falls between the two.
+	var queueMu sync.Mutex
+	queue := []ntgo.EntryEvent{}
+	wake := make(chan struct{}, 1)
+	listener := nt.Table().AddListener(prefix, func(event ntgo.EntryEvent) {
+		queueMu.Lock()
+		queue = append(queue, event)
+		queueMu.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer nt.Table().RemoveListener(listener)
+	snapshot := map[string]ntgo.Entry{}
+	for _, entry := range nt.Table().Entries(prefix) {
+		snapshot[entry.Name.Value] = entry
+		printEvent(opts, ntgo.EntryEvent{Kind: ntgo.EntryEventCreated, Entry: entry, Time: time.Now()})
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-wake:
+		case <-interrupt:
+			return nil
+		}
+		queueMu.Lock()
+		events := queue
+		queue = []ntgo.EntryEvent{}
+		queueMu.Unlock()
+		for _, event := range events {
+			if inSnapshot(snapshot, event) {
+				continue
+			}
+			printEvent(opts, event)
+		}
+		// Only events queued while the snapshot was taken can repeat it.
+		snapshot = nil
+	}
+}
+
+// inSnapshot reports whether event only repeats what snapshot already
+// showed.
+func inSnapshot(snapshot map[string]ntgo.Entry, event ntgo.EntryEvent) bool {
+	entry, ok := snapshot[event.Entry.Name.Value]
+	if !ok || event.Kind == ntgo.EntryEventDeleted || event.Entry.Value == nil || entry.Value == nil {
+		return false
+	}
+	return entry.Sequence == event.Entry.Sequence && entry.Flags == event.Entry.Flags &&
+		bytes.Equal(entry.Value.GetRaw(), event.Entry.Value.GetRaw())
+}
+
+func runDelete(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	return nt.Delete(args[0])
+}
+
+func runFlags(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	return setFlags(opts, nt, args[0])
+}
+
+func runCall(opts *options, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	entry, ok := nt.Table().Get(args[0])
+	if !ok {
+		return ntgo.ErrEntryNotFound
+	}
+	definition, ok := entry.Value.(*ntgo.ValueRPC)
+	if !ok {
+		return ntgo.ErrEntryCastInvalid
+	}
+	if len(args)-1 > len(definition.Params) {
+		return ntgo.ErrRPCParamsInvalid
+	}
+	params := make([]ntgo.EntryValue, len(args)-1)
+	for i, arg := range args[1:] {
+		param, paramErr := parseValue(definition.Params[i].Type, []string{arg})
+		if paramErr != nil {
+			return paramErr
+		}
+		params[i] = param
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	results, callErr := nt.CallRPC(ctx, args[0], params...)
+	if callErr != nil {
+		return callErr
+	}
+	if opts.json {
+		named := map[string]ntgo.EntryValue{}
+		for i, output := range definition.Outputs {
+			named[output.Name.Value] = results[i]
+		}
+		return json.NewEncoder(os.Stdout).Encode(named)
+	}
+	for i, output := range definition.Outputs {
+		fmt.Printf("%s = %s\n", output.Name, results[i])
+	}
+	return nil
+}
+
+func setFlags(opts *options, nt *ntgo.NetworkTables, name string) error {
+	if opts.persistent == opts.temporary {
+		return errFlagsMissing
+	}
+	var flags ntgo.EntryFlag = ntgo.EntryFlagTemporary
+	if opts.persistent {
+		flags = ntgo.EntryFlagPersistent
+	}
+	return nt.SetFlags(name, flags)
+}
+
+func printEvent(opts *options, event ntgo.EntryEvent) {
+	if opts.json {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Event string     `json:"event"`
+			Time  time.Time  `json:"time"`
+			Entry ntgo.Entry `json:"entry"`
+		}{eventNames[event.Kind], event.Time, event.Entry})
+		return
+	}
+	fmt.Printf("%s %-7s %s\n", event.Time.Format("15:04:05.000"), eventNames[event.Kind], event.Entry)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// runCSV writes the number and boolean entries under a prefix as a CSV
+// table on stdout: from a recording with --recording, or else as the server
+// changes them until interrupted.
+func runCSV(opts *options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	if opts.recording != "" {
+		file, openErr := os.Open(opts.recording)
+		if openErr != nil {
+			return openErr
+		}
+		defer file.Close()
+		return ntgo.ExportCSV(os.Stdout, file, prefix, opts.rate)
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	export := ntgo.NewCSVExport(nt, prefix)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	export.Close()
+	return export.WriteCSV(os.Stdout, opts.rate)
+}
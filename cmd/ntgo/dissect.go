@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// runDissect prints a transcript of the NetworkTables traffic in a pcap or
+// pcapng capture, or in a file holding one direction of a raw TCP stream.
+func runDissect(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	file, openErr := os.Open(args[0])
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	handle := func(dissection ntgo.Dissection) { printDissection(opts, dissection) }
+	dissectErr := ntgo.DissectCapture(file, opts.port, handle)
+	if dissectErr != ntgo.ErrCaptureInvalid {
+		return dissectErr
+	}
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	return ntgo.DissectStream(file, handle)
+}
+
+func printDissection(opts *options, dissection ntgo.Dissection) {
+	if !opts.json {
+		fmt.Println(dissection)
+		return
+	}
+	line := struct {
+		Time    *time.Time `json:"time,omitempty"`
+		Client  string     `json:"client,omitempty"`
+		Server  string     `json:"server,omitempty"`
+		From    string     `json:"from"`
+		Type    string     `json:"type,omitempty"`
+		Name    string     `json:"name,omitempty"`
+		Text    string     `json:"text"`
+		Unknown string     `json:"unknown,omitempty"`
+		Missing int        `json:"missing,omitempty"`
+	}{Client: dissection.Client, Server: dissection.Server, From: "client", Name: dissection.Name, Missing: dissection.Missing}
+	if !dissection.Time.IsZero() {
+		line.Time = &dissection.Time
+	}
+	if dissection.FromServer {
+		line.From = "server"
+	}
+	if dissection.Message != nil {
+		line.Type = dissection.Message.Type.String()
+	}
+	line.Unknown = hex.EncodeToString(dissection.Unknown)
+	// The transcript line without the time and addresses.
+	dissection.Time, dissection.Client = time.Time{}, ""
+	line.Text = dissection.String()
+	json.NewEncoder(os.Stdout).Encode(line)
+}
@@ -0,0 +1,162 @@
+// Command ntgo reads and writes entries on a NetworkTables server.
+//
+// Usage:
+//
+//	ntgo [flags] list [prefix]
+//	ntgo [flags] get <key>
+//	ntgo [flags] set <key> [--type <type>] <value>...
+//	ntgo [flags] watch [prefix]
+//	ntgo [flags] delete <key>
+//	ntgo [flags] flags <key> (--persistent | --temporary)
+//	ntgo [flags] call <rpc> [<param>...]
+//	ntgo [flags] bridge [--reverse] [listen-address]
+//	ntgo [flags] record <file>
+//	ntgo [flags] replay <file> [--listen address] [--speed n] [--seek offset] [--loop]
+//	ntgo wpilog <in> <out>
+//	ntgo [flags] dissect [--port n] <capture>
+//	ntgo [flags] csv [--rate interval] [--recording file] [prefix]
+//	ntgo [flags] tui [prefix]
+//
+// Flags may appear anywhere on the command line. Use --server to pick the
+// server address or --team to connect to a robot by team number.
+//
+// bridge connects to the server over NetworkTables 3.0 and serves the same
+// entries over 4.0 on listen-address, :5810 by default. With --reverse it
+// connects over 4.0 and serves 3.0, on :1735 by default.
+//
+// record writes every message exchanged with the server to a recording
+// file until interrupted. replay plays a recording back with its original
+// timing into the server, or into a new server on --listen. wpilog converts
+// a recording into a WPILib DataLog, or a .wpilog file into a recording.
+//
+// dissect prints every message in a pcap or pcapng capture of connections to
+// --port, 1735 by default, or in a file of raw bytes sent one way over a
+// connection.
+//
+// csv writes the numbers and booleans under prefix as a CSV table with a
+// column per entry, and per element of a double array, collected from the
+// server until interrupted or read from --recording. --rate resamples it to
+// a fixed interval.
+//
+// tui browses the entries under prefix as a tree in the terminal, with live
+// values, highlighting of recent changes and sparklines of numbers. Arrow
+// keys move and fold, / searches, enter edits the value, p toggles
+// persistence, d deletes and q quits.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	errUsage = errors.New("ntgo: invalid usage")
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(opts *options, args []string) error
+}
+
+var commands = []command{
+	{name: "list", usage: "list [prefix]", run: runList},
+	{name: "get", usage: "get <key>", run: runGet},
+	{name: "set", usage: "set <key> [--type <type>] <value>...", run: runSet},
+	{name: "watch", usage: "watch [prefix]", run: runWatch},
+	{name: "delete", usage: "delete <key>", run: runDelete},
+	{name: "flags", usage: "flags <key> (--persistent | --temporary)", run: runFlags},
+	{name: "call", usage: "call <rpc> [<param>...]", run: runCall},
+	{name: "bridge", usage: "bridge [--reverse] [listen-address]", run: runBridge},
+	{name: "record", usage: "record <file>", run: runRecord},
+	{name: "replay", usage: "replay <file> [--listen address] [--speed n] [--seek offset] [--loop]", run: runReplay},
+	{name: "wpilog", usage: "wpilog <in> <out>", run: runWPILog},
+	{name: "dissect", usage: "dissect [--port n] <capture>", run: runDissect},
+	{name: "csv", usage: "csv [--rate interval] [--recording file] [prefix]", run: runCSV},
+	{name: "tui", usage: "tui [prefix]", run: runTUI},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if err != errUsage && err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	opts := &options{}
+	flags := opts.flagSet("ntgo")
+	positional, parseErr := parseInterspersed(flags, args)
+	if parseErr != nil {
+		return parseErr
+	}
+	if len(positional) == 0 {
+		usage()
+		return errUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name == positional[0] {
+			return cmd.run(opts, positional[1:])
+		}
+	}
+	usage()
+	return errUsage
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ntgo [--server host[:port] | --team number] [--json] <command>")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	(&options{}).flagSet("ntgo").PrintDefaults()
+}
+
+// parseInterspersed parses flags wherever they appear in args and returns
+// the remaining positional arguments in order. Arguments that parse as
+// numbers are always positional, so negative values need no escaping.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for len(args) > 0 {
+		arg := args[0]
+		switch {
+		case arg == "--":
+			return append(positional, args[1:]...), nil
+		case !strings.HasPrefix(arg, "-") || isNumber(arg):
+			positional = append(positional, arg)
+			args = args[1:]
+		default:
+			// Hand the flag package one flag at a time, so a negative number
+			// after a flag is not mistaken for another flag.
+			count := 1
+			name := strings.TrimLeft(arg, "-")
+			if !strings.Contains(name, "=") && len(args) > 1 {
+				if found := flags.Lookup(name); found != nil && !isBoolFlag(found) {
+					count = 2
+				}
+			}
+			if parseErr := flags.Parse(args[:count]); parseErr != nil {
+				return nil, parseErr
+			}
+			args = args[count:]
+		}
+	}
+	return positional, nil
+}
+
+func isBoolFlag(found *flag.Flag) bool {
+	boolFlag, ok := found.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+func isNumber(arg string) bool {
+	_, parseErr := strconv.ParseFloat(arg, 64)
+	return parseErr == nil
+}
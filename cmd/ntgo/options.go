@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+)
+
+type options struct {
+	server     string
+	team       int
+	json       bool
+	timeout    time.Duration
+	identity   string
+	entryType  string
+	persistent bool
+	temporary  bool
+	reverse    bool
+	listen     string
+	speed      float64
+	seek       time.Duration
+	loop       bool
+	port       string
+	rate       time.Duration
+	recording  string
+}
+
+func (opts *options) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&opts.server, "server", "localhost", "server `host[:port]` or unix:path to connect to")
+	flags.IntVar(&opts.team, "team", 0, "connect to the robot of this FRC team `number` instead of --server")
+	flags.BoolVar(&opts.json, "json", false, "print entries and events as JSON")
+	flags.DurationVar(&opts.timeout, "timeout", ntgo.DefaultTimeout, "connect and RPC timeout")
+	flags.StringVar(&opts.identity, "identity", "ntgo-cli", "identity to announce to the server")
+	flags.StringVar(&opts.entryType, "type", "", "entry `type` for set (boolean, double, string, raw, boolean[], double[], string[])")
+	flags.BoolVar(&opts.persistent, "persistent", false, "mark the entry persistent")
+	flags.BoolVar(&opts.temporary, "temporary", false, "mark the entry temporary")
+	flags.BoolVar(&opts.reverse, "reverse", false, "bridge from a 4.0 server to a 3.0 server instead")
+	flags.StringVar(&opts.listen, "listen", "", "replay into a server of its own listening on `address` instead of --server")
+	flags.Float64Var(&opts.speed, "speed", 1, "replay speed `multiplier`")
+	flags.DurationVar(&opts.seek, "seek", 0, "start replaying this far into the recording")
+	flags.BoolVar(&opts.loop, "loop", false, "replay the recording over and over")
+	flags.StringVar(&opts.port, "port", ntgo.DefaultPort, "server `port` of the connections to dissect")
+	flags.DurationVar(&opts.rate, "rate", 0, "resample the table to a row every `interval`")
+	flags.StringVar(&opts.recording, "recording", "", "export from a recording `file` instead of the server")
+	return flags
+}
+
+// client returns a client for the server, not yet connected.
+func (opts *options) client() *ntgo.NetworkTables {
+	return &ntgo.NetworkTables{
+		Servers:  []string{opts.server},
+		Port:     ntgo.DefaultPort,
+		Mode:     ntgo.ModeClient,
+		Team:     opts.team,
+		Identity: opts.identity,
+		Timeout:  opts.timeout,
+	}
+}
+
+// connect dials the server and waits for the initial entry sync.
+func (opts *options) connect() (*ntgo.NetworkTables, error) {
+	nt := opts.client()
+	if initErr := nt.Initialize(); initErr != nil {
+		return nil, initErr
+	}
+	return nt, nil
+}
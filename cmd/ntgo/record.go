@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// runRecord records every message exchanged with the server into a file
+// until interrupted.
+func runRecord(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	recorder, createErr := ntgo.CreateRecording(args[0])
+	if createErr != nil {
+		return createErr
+	}
+	defer recorder.Close()
+	nt := opts.client()
+	nt.Recorder = recorder
+	if initErr := nt.Initialize(); initErr != nil {
+		return initErr
+	}
+	defer nt.Close()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// runReplay plays a recording into the server, or with --listen into a
+// server of its own, until it ends or is interrupted.
+func runReplay(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	file, openErr := os.Open(args[0])
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	var nt *ntgo.NetworkTables
+	if opts.listen != "" {
+		nt = &ntgo.NetworkTables{Mode: ntgo.ModeServer, Listen: []string{opts.listen}}
+		if initErr := nt.Initialize(); initErr != nil {
+			return initErr
+		}
+	} else {
+		var connErr error
+		if nt, connErr = opts.connect(); connErr != nil {
+			return connErr
+		}
+	}
+	defer nt.Close()
+	player, playerErr := ntgo.NewPlayer(file, nt)
+	if playerErr != nil {
+		return playerErr
+	}
+	player.Speed = opts.speed
+	player.Loop = opts.loop
+	if opts.seek > 0 {
+		player.Seek(opts.seek)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if playErr := player.Play(ctx); playErr != nil && playErr != context.Canceled {
+		return playErr
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	errNotTerminal = errors.New("ntgo: tui needs an interactive terminal")
+)
+
+// Keys other than printable characters, as decodeKeys names them.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdn"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyInterrupt = "ctrl-c"
+)
+
+var escapeKeys = map[string]string{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+	"[5~": keyPageUp, "[6~": keyPageDown,
+}
+
+// stty runs stty on the terminal of stdin. Going through stty keeps the
+// tool free of terminal libraries and works on any Unix.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, runErr := cmd.Output()
+	return strings.TrimSpace(string(out)), runErr
+}
+
+// makeRaw puts the terminal in raw mode, so that keys arrive as they are
+// pressed and are not echoed, and returns a function that restores it.
+func makeRaw() (func(), error) {
+	saved, savedErr := stty("-g")
+	if savedErr != nil {
+		return nil, errNotTerminal
+	}
+	if _, rawErr := stty("raw", "-echo"); rawErr != nil {
+		return nil, errNotTerminal
+	}
+	return func() { stty(saved) }, nil
+}
+
+// terminalSize returns the rows and columns of the terminal, or 24 by 80 if
+// they cannot be found.
+func terminalSize() (int, int) {
+	size, sizeErr := stty("size")
+	if sizeErr == nil {
+		if fields := strings.Fields(size); len(fields) == 2 {
+			rows, rowsErr := strconv.Atoi(fields[0])
+			cols, colsErr := strconv.Atoi(fields[1])
+			if rowsErr == nil && colsErr == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// decodeKeys splits what the terminal sent in one read into keys: the
+// names above, or a single character as it is.
+func decodeKeys(input []byte) []string {
+	keys := []string{}
+	for len(input) > 0 {
+		switch b := input[0]; {
+		case b == 0x1b && len(input) == 1:
+			keys = append(keys, keyEscape)
+			input = input[1:]
+		case b == 0x1b && (input[1] == '[' || input[1] == 'O'):
+			// A control sequence runs to its final byte.
+			end := 2
+			for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+				end++
+			}
+			end = min(end+1, len(input))
+			if key, known := escapeKeys[string(input[1:end])]; known {
+				keys = append(keys, key)
+			}
+			input = input[end:]
+		case b == 0x1b:
+			keys = append(keys, keyEscape)
+			input = input[1:]
+		case b == '\r' || b == '\n':
+			keys = append(keys, keyEnter)
+			input = input[1:]
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, keyBackspace)
+			input = input[1:]
+		case b == 0x03:
+			keys = append(keys, keyInterrupt)
+			input = input[1:]
+		case b < 0x20:
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, string(r))
+			input = input[size:]
+		}
+	}
+	return keys
+}
+
+// fit cuts s to width characters, or pads it with spaces to width. Control
+// characters are shown escaped, as names and values from the network must
+// not move the cursor or send escape sequences to the terminal.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = escapeControls(s)
+	count := utf8.RuneCountInString(s)
+	if count <= width {
+		return s + strings.Repeat(" ", width-count)
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// escapeControls writes the control characters in s as \x escapes.
+func escapeControls(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			fmt.Fprintf(&escaped, "\\x%02x", r)
+		} else {
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
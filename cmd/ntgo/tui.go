@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+)
+
+const (
+	// tuiHighlight is how long a changed value stays highlighted.
+	tuiHighlight = time.Second
+	// tuiSparkWidth and tuiSparkStep set the sparklines: one character per
+	// step, the latest on the right.
+	tuiSparkWidth = 16
+	tuiSparkStep  = 500 * time.Millisecond
+	tuiHistory    = 256
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+type tuiMode int
+
+const (
+	tuiBrowse tuiMode = iota
+	tuiSearch
+	tuiEdit
+	tuiConfirmDelete
+)
+
+// tuiRow is a line of the tree: a folder, or an entry.
+type tuiRow struct {
+	key    string
+	folder bool
+	path   string
+	label  string
+	depth  int
+	entry  ntgo.Entry
+}
+
+// tui is the state of the terminal browser.
+type tui struct {
+	nt      *ntgo.NetworkTables
+	prefix  string
+	history *ntgo.EntryHistory
+
+	rows      []tuiRow
+	cursor    int
+	offset    int
+	selected  string
+	collapsed map[string]bool
+	query     string
+	mode      tuiMode
+	input     string
+	editing   ntgo.Entry
+	status    string
+
+	height, width int
+	sized         time.Time
+}
+
+// runTUI shows the entries under a prefix as a tree that updates live, until
+// q is pressed or the tool is interrupted.
+func runTUI(opts *options, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	nt, connErr := opts.connect()
+	if connErr != nil {
+		return connErr
+	}
+	defer nt.Close()
+	restore, rawErr := makeRaw()
+	if rawErr != nil {
+		return rawErr
+	}
+	defer restore()
+	history := ntgo.NewEntryHistory(nt, tuiHistory)
+	history.Track(prefix)
+	defer history.Close()
+
+	ui := &tui{nt: nt, prefix: prefix, history: history, collapsed: map[string]bool{}}
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []string)
+	go func() {
+		buffer := make([]byte, 256)
+		for {
+			n, readErr := os.Stdin.Read(buffer)
+			if readErr != nil {
+				close(keys)
+				return
+			}
+			keys <- decodeKeys(buffer[:n])
+		}
+	}()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		ui.draw()
+		select {
+		case pressed, open := <-keys:
+			if !open {
+				return nil
+			}
+			for _, key := range pressed {
+				if !ui.handle(key) {
+					return nil
+				}
+			}
+		case <-ticker.C:
+		case <-interrupt:
+			return nil
+		}
+	}
+}
+
+// handle acts on a key. It returns false to quit.
+func (ui *tui) handle(key string) bool {
+	if key == keyInterrupt {
+		return false
+	}
+	switch ui.mode {
+	case tuiSearch:
+		switch key {
+		case keyEnter:
+			ui.mode = tuiBrowse
+		case keyEscape:
+			ui.query, ui.mode = "", tuiBrowse
+		default:
+			ui.query = editLine(ui.query, key)
+		}
+		return true
+	case tuiEdit:
+		switch key {
+		case keyEnter:
+			ui.commit()
+			ui.mode = tuiBrowse
+		case keyEscape:
+			ui.mode = tuiBrowse
+		default:
+			ui.input = editLine(ui.input, key)
+		}
+		return true
+	case tuiConfirmDelete:
+		if row, ok := ui.current(); ok && key == "y" {
+			ui.report(ui.nt.Delete(row.entry.Name.Value), "deleted "+row.entry.Name.Value)
+		}
+		ui.mode = tuiBrowse
+		return true
+	}
+
+	row, hasRow := ui.current()
+	ui.status = ""
+	switch key {
+	case "q":
+		return false
+	case keyUp, "k":
+		ui.move(-1)
+	case keyDown, "j":
+		ui.move(1)
+	case keyPageUp:
+		ui.move(-ui.pageSize())
+	case keyPageDown:
+		ui.move(ui.pageSize())
+	case keyHome, "g":
+		ui.move(-len(ui.rows))
+	case keyEnd, "G":
+		ui.move(len(ui.rows))
+	case "/":
+		ui.mode = tuiSearch
+	case keyEscape:
+		ui.query = ""
+	case keyLeft, "h":
+		if hasRow && row.folder && !ui.collapsed[row.path] {
+			ui.collapsed[row.path] = true
+		} else if hasRow {
+			// Go up to the folder the row is in.
+			parent := row.path[:max(strings.LastIndex(row.path, "/"), 0)]
+			ui.selected = "d" + parent
+		}
+	case keyRight, "l":
+		if hasRow && row.folder {
+			delete(ui.collapsed, row.path)
+		}
+	case keyEnter, " ", "e":
+		if !hasRow {
+			break
+		}
+		if row.folder {
+			ui.collapsed[row.path] = !ui.collapsed[row.path]
+			break
+		}
+		ui.edit(row.entry)
+	case "p":
+		if hasRow && !row.folder {
+			var flags ntgo.EntryFlag = ntgo.EntryFlagPersistent
+			if row.entry.Flags == ntgo.EntryFlagPersistent {
+				flags = ntgo.EntryFlagTemporary
+			}
+			ui.report(ui.nt.SetFlags(row.entry.Name.Value, flags), fmt.Sprintf("%s is now %s", row.entry.Name.Value, flags))
+		}
+	case "d":
+		if hasRow && !row.folder {
+			ui.mode = tuiConfirmDelete
+		}
+	}
+	return true
+}
+
+// edit starts editing the value of entry. Booleans are toggled straight
+// away instead.
+func (ui *tui) edit(entry ntgo.Entry) {
+	switch value := entry.Value.(type) {
+	case *ntgo.ValueBoolean:
+		ui.report(ui.nt.SetValue(entry.Name.Value, entry.Type, ntgo.BuildBoolean(!value.Value)), "")
+		return
+	case *ntgo.ValueRPC:
+		ui.status = "remote procedures are called with ntgo call"
+		return
+	case *ntgo.ValueString:
+		ui.input = value.Value
+	case *ntgo.ValueDouble:
+		ui.input = value.String()
+	default:
+		// Arrays are edited as JSON, and raw data as base64.
+		encoded, _ := json.Marshal(entry.Value)
+		ui.input = string(encoded)
+		if entry.Type == ntgo.EntryTypeRawData {
+			json.Unmarshal(encoded, &ui.input)
+		}
+	}
+	ui.editing, ui.mode = entry, tuiEdit
+}
+
+// commit sets the entry being edited to the value typed.
+func (ui *tui) commit() {
+	value, parseErr := parseValue(ui.editing.Type, []string{ui.input})
+	if parseErr != nil {
+		ui.report(parseErr, "")
+		return
+	}
+	ui.report(ui.nt.SetValue(ui.editing.Name.Value, ui.editing.Type, value), "")
+}
+
+func (ui *tui) report(err error, done string) {
+	ui.status = done
+	if err != nil {
+		ui.status = err.Error()
+	}
+}
+
+// editLine applies a key to a line being typed.
+func editLine(line, key string) string {
+	if key == keyBackspace {
+		runes := []rune(line)
+		return string(runes[:max(len(runes)-1, 0)])
+	}
+	if len([]rune(key)) == 1 {
+		return line + key
+	}
+	return line
+}
+
+func (ui *tui) current() (tuiRow, bool) {
+	if ui.cursor < 0 || ui.cursor >= len(ui.rows) {
+		return tuiRow{}, false
+	}
+	return ui.rows[ui.cursor], true
+}
+
+func (ui *tui) move(delta int) {
+	ui.cursor = max(min(ui.cursor+delta, len(ui.rows)-1), 0)
+	if row, ok := ui.current(); ok {
+		ui.selected = row.key
+	}
+}
+
+// pageSize is the number of rows that fit between the header and the
+// footer.
+func (ui *tui) pageSize() int {
+	return max(ui.height-3, 1)
+}
+
+// layout builds the rows of the tree from the table. While searching, only
+// entries whose names contain the query are shown, and folders are opened.
+func (ui *tui) layout() {
+	entries := ui.nt.Table().Entries(ui.prefix)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name.Value < entries[j].Name.Value })
+	query := strings.ToLower(ui.query)
+	rows := []tuiRow{}
+	placed := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name.Value
+		if query != "" && !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+		parts := strings.FieldsFunc(name, func(r rune) bool { return r == '/' })
+		path, hidden := "", false
+		for depth, part := range parts[:max(len(parts)-1, 0)] {
+			path += "/" + part
+			if !placed["d"+path] {
+				placed["d"+path] = true
+				rows = append(rows, tuiRow{key: "d" + path, folder: true, path: path, label: part + "/", depth: depth})
+			}
+			if query == "" && ui.collapsed[path] {
+				hidden = true
+				break
+			}
+		}
+		if hidden {
+			continue
+		}
+		label := name
+		if len(parts) > 0 {
+			label = parts[len(parts)-1]
+		}
+		rows = append(rows, tuiRow{key: "e" + name, path: "/" + strings.Join(parts, "/"), label: label, depth: max(len(parts)-1, 0), entry: entry})
+	}
+	ui.rows = rows
+	// Keep the cursor on the same row as the tree changes under it.
+	for i, row := range rows {
+		if row.key == ui.selected {
+			ui.cursor = i
+			return
+		}
+	}
+	ui.cursor = max(min(ui.cursor, len(rows)-1), 0)
+	if row, ok := ui.current(); ok {
+		ui.selected = row.key
+	}
+}
+
+func (ui *tui) draw() {
+	if time.Since(ui.sized) > time.Second {
+		ui.height, ui.width = terminalSize()
+		ui.sized = time.Now()
+	}
+	ui.layout()
+	page := ui.pageSize()
+	if ui.cursor < ui.offset {
+		ui.offset = ui.cursor
+	}
+	if ui.cursor >= ui.offset+page {
+		ui.offset = ui.cursor - page + 1
+	}
+	ui.offset = max(min(ui.offset, len(ui.rows)-page), 0)
+
+	var screen bytes.Buffer
+	screen.WriteString("\x1b[H")
+	header := fmt.Sprintf(" ntgo tui  %s  %d entries", strings.Join(ui.nt.Servers, ","), ui.nt.Table().Len())
+	if ui.query != "" {
+		header += fmt.Sprintf("  matching %q", ui.query)
+	}
+	screen.WriteString("\x1b[7m" + fit(header, ui.width) + "\x1b[0m\r\n")
+
+	nameWidth := max(ui.width*2/5, 16)
+	valueWidth := max(ui.width-nameWidth-tuiSparkWidth-15, 8)
+	now := time.Now()
+	for i := ui.offset; i < ui.offset+page; i++ {
+		if i >= len(ui.rows) {
+			screen.WriteString("\x1b[K\r\n")
+			continue
+		}
+		row := ui.rows[i]
+		line := ""
+		if row.folder {
+			marker := "▾ "
+			if ui.collapsed[row.path] && ui.query == "" {
+				marker = "▸ "
+			}
+			line = fit(strings.Repeat("  ", row.depth)+marker+row.label, ui.width)
+		} else {
+			flag := " "
+			if row.entry.Flags == ntgo.EntryFlagPersistent {
+				flag = "P"
+			}
+			value := "<nil>"
+			if row.entry.Value != nil {
+				value = fmt.Sprint(row.entry.Value)
+			}
+			value = fit(value, valueWidth)
+			if now.Sub(ui.nt.Table().LastChange(row.entry.Name.Value)) < tuiHighlight && i != ui.cursor {
+				value = "\x1b[1;33m" + value + "\x1b[0m"
+			}
+			seq := fmt.Sprint(binary.BigEndian.Uint16(row.entry.Sequence[:]))
+			line = fit(strings.Repeat("  ", row.depth+1)+row.label, nameWidth) + " " +
+				fit(row.entry.Type.String(), 9) + " " + flag + " " + fit(seq, 5) + " " + value + " " +
+				ui.sparkline(row.entry.Name.Value, now)
+		}
+		if i == ui.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		screen.WriteString(line + "\x1b[K\r\n")
+	}
+
+	footer := "↑↓ move  ←→ fold  / search  enter edit  p persist  d delete  q quit"
+	switch {
+	case ui.mode == tuiSearch:
+		footer = "/" + ui.query + "█"
+	case ui.mode == tuiEdit:
+		footer = fmt.Sprintf("%s (%s) = %s█", ui.editing.Name.Value, ui.editing.Type, ui.input)
+	case ui.mode == tuiConfirmDelete:
+		row, _ := ui.current()
+		footer = fmt.Sprintf("delete %s? (y/n)", row.entry.Name.Value)
+	case ui.status != "":
+		footer = ui.status
+	}
+	screen.WriteString(fit(footer, ui.width) + "\x1b[K\x1b[J")
+	os.Stdout.Write(screen.Bytes())
+}
+
+// sparkline draws the recent history of a number or boolean entry, one
+// character per tuiSparkStep, scaled to its range over that time. NaN and
+// infinities leave a gap. It is blank for other entries.
+func (ui *tui) sparkline(name string, now time.Time) string {
+	values := make([]float64, tuiSparkWidth)
+	known := make([]bool, tuiSparkWidth)
+	low, high := 0.0, 0.0
+	seen := false
+	for i := range values {
+		value, ok := ui.history.ValueAt(name, now.Add(-time.Duration(tuiSparkWidth-1-i)*tuiSparkStep))
+		if !ok {
+			continue
+		}
+		switch value := value.(type) {
+		case *ntgo.ValueDouble:
+			if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+				// Not a point on the line, and would poison the range.
+				continue
+			}
+			values[i] = value.Value
+		case *ntgo.ValueBoolean:
+			if value.Value {
+				values[i] = 1
+			}
+		default:
+			continue
+		}
+		known[i] = true
+		if !seen {
+			low, high, seen = values[i], values[i], true
+		}
+		low, high = min(low, values[i]), max(high, values[i])
+	}
+	if !seen {
+		return ""
+	}
+	spark := make([]rune, tuiSparkWidth)
+	for i, value := range values {
+		switch {
+		case !known[i]:
+			spark[i] = ' '
+		case high == low:
+			spark[i] = sparkLevels[len(sparkLevels)/2]
+		default:
+			spark[i] = sparkLevels[int((value-low)/(high-low)*float64(len(sparkLevels)-1)+0.5)]
+		}
+	}
+	return string(spark)
+}
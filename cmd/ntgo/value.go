@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/HowardStark/ntgo"
+)
+
+var (
+	errValueCount = errors.New("ntgo: wrong number of values for entry type")
+)
+
+// parseValue turns command-line arguments into a value of entryType. Arrays
+// take one argument per element, or a single JSON array. Raw data is base64.
+// A string that starts with "[" is taken as it is.
+func parseValue(entryType ntgo.EntryType, args []string) (ntgo.EntryValue, error) {
+	isArray := entryType == ntgo.EntryTypeBooleanArr || entryType == ntgo.EntryTypeDoubleArr || entryType == ntgo.EntryTypeStringArr
+	if isArray && len(args) == 1 && strings.HasPrefix(args[0], "[") {
+		return ntgo.ParseEntryValueJSON(entryType, json.RawMessage(args[0]))
+	}
+	switch entryType {
+	case ntgo.EntryTypeBoolean, ntgo.EntryTypeDouble, ntgo.EntryTypeRawData:
+		if len(args) != 1 {
+			return nil, errValueCount
+		}
+	}
+	switch entryType {
+	case ntgo.EntryTypeBoolean:
+		value, parseErr := strconv.ParseBool(args[0])
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		return ntgo.BuildBoolean(value), nil
+	case ntgo.EntryTypeDouble:
+		value, parseErr := strconv.ParseFloat(args[0], 64)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		return ntgo.BuildDouble(value), nil
+	case ntgo.EntryTypeString:
+		return ntgo.BuildString(strings.Join(args, " ")), nil
+	case ntgo.EntryTypeRawData:
+		value, decodeErr := base64.StdEncoding.DecodeString(args[0])
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		return ntgo.BuildRaw(value), nil
+	case ntgo.EntryTypeBooleanArr:
+		values := make([]bool, len(args))
+		for i, arg := range args {
+			value, parseErr := strconv.ParseBool(arg)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			values[i] = value
+		}
+		return ntgo.BuildBooleanArrayFrom(values)
+	case ntgo.EntryTypeDoubleArr:
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			value, parseErr := strconv.ParseFloat(arg, 64)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			values[i] = value
+		}
+		return ntgo.BuildDoubleArrayFrom(values)
+	case ntgo.EntryTypeStringArr:
+		return ntgo.BuildStringArrayFrom(args)
+	default:
+		return nil, ntgo.ErrEntryNoSuchType
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/HowardStark/ntgo"
+)
+
+// runWPILog converts between recordings and WPILib DataLogs. A .wpilog
+// input is imported into a recording; anything else is exported to one.
+func runWPILog(opts *options, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	in, openErr := os.Open(args[0])
+	if openErr != nil {
+		return openErr
+	}
+	defer in.Close()
+	out, createErr := os.Create(args[1])
+	if createErr != nil {
+		return createErr
+	}
+	var convertErr error
+	if filepath.Ext(args[0]) == ".wpilog" {
+		convertErr = ntgo.ImportDataLog(out, in)
+	} else {
+		convertErr = ntgo.ExportDataLog(out, in)
+	}
+	if closeErr := out.Close(); convertErr == nil {
+		convertErr = closeErr
+	}
+	return convertErr
+}
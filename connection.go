@@ -0,0 +1,140 @@
+package ntgo
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// ConnectionInfo describes a live connection: for a client, the one to its
+// server; for a server, one per client.
+type ConnectionInfo struct {
+	// RemoteIdentity is the identity the other end announced, or for a
+	// client authenticated with TLS, the subject of its certificate.
+	RemoteIdentity   string
+	RemoteAddr       string
+	ProtocolRevision ProtocolRevision
+	Connected        time.Time
+	// LastActivity is when a message was last received on the connection.
+	LastActivity time.Time
+}
+
+// ConnectionEvent reports that a connection was made or lost. Info is the
+// state of the connection at that moment.
+type ConnectionEvent struct {
+	Connected bool
+	Info      ConnectionInfo
+}
+
+// ConnectionListener is called synchronously for every ConnectionEvent, from
+// the goroutine handling the connection.
+type ConnectionListener func(event ConnectionEvent)
+
+// connections keeps track of the live connections of a client or server and
+// tells listeners when they come and go.
+type connections struct {
+	mu           sync.Mutex
+	active       map[int]*ConnectionInfo
+	nextID       int
+	listeners    map[int]ConnectionListener
+	nextListener int
+}
+
+func newConnections() *connections {
+	return &connections{
+		active:    map[int]*ConnectionInfo{},
+		listeners: map[int]ConnectionListener{},
+	}
+}
+
+// add records a new connection and returns the ID to touch and remove it
+// with.
+func (c *connections) add(info ConnectionInfo) int {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	info.LastActivity = info.Connected
+	c.active[id] = &info
+	c.mu.Unlock()
+	c.notify(ConnectionEvent{Connected: true, Info: info})
+	return id
+}
+
+// touch marks the connection active now.
+func (c *connections) touch(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if info, ok := c.active[id]; ok {
+		info.LastActivity = time.Now()
+	}
+}
+
+func (c *connections) remove(id int) {
+	c.mu.Lock()
+	info, ok := c.active[id]
+	delete(c.active, id)
+	c.mu.Unlock()
+	if ok {
+		c.notify(ConnectionEvent{Connected: false, Info: *info})
+	}
+}
+
+// list returns the live connections, oldest first.
+func (c *connections) list() []ConnectionInfo {
+	c.mu.Lock()
+	infos := []ConnectionInfo{}
+	for _, info := range c.active {
+		infos = append(infos, *info)
+	}
+	c.mu.Unlock()
+	slices.SortFunc(infos, func(a, b ConnectionInfo) int {
+		return a.Connected.Compare(b.Connected)
+	})
+	return infos
+}
+
+func (c *connections) notify(event ConnectionEvent) {
+	c.mu.Lock()
+	listeners := []ConnectionListener{}
+	for _, listener := range c.listeners {
+		listeners = append(listeners, listener)
+	}
+	c.mu.Unlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+var connectionsInit sync.Mutex
+
+func (nt *NetworkTables) connections() *connections {
+	connectionsInit.Lock()
+	defer connectionsInit.Unlock()
+	if nt.conns == nil {
+		nt.conns = newConnections()
+	}
+	return nt.conns
+}
+
+// Connections returns the live connections of nt, oldest first.
+func (nt *NetworkTables) Connections() []ConnectionInfo {
+	return nt.connections().list()
+}
+
+// AddConnectionListener calls fn whenever nt connects or disconnects, and
+// returns an ID to remove it with. It can be added before Initialize.
+func (nt *NetworkTables) AddConnectionListener(fn ConnectionListener) int {
+	c := nt.connections()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextListener++
+	c.listeners[c.nextListener] = fn
+	return c.nextListener
+}
+
+func (nt *NetworkTables) RemoveConnectionListener(id int) {
+	c := nt.connections()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.listeners, id)
+}
@@ -0,0 +1,58 @@
+package ntgo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnections(t *testing.T) {
+	srv := startServer(t, &NetworkTables{Identity: "robot"})
+	var mu sync.Mutex
+	events := []ConnectionEvent{}
+	srv.AddConnectionListener(func(event ConnectionEvent) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+	before := time.Now()
+	cl := connectClient(t, srv, "dashboard")
+
+	clientSide := cl.Connections()
+	if len(clientSide) != 1 {
+		t.Fatalf("Expected 1 connection but got %d", len(clientSide))
+	}
+	if clientSide[0].RemoteIdentity != "robot" || clientSide[0].RemoteAddr != cl.Endpoint() {
+		t.Fatalf("Expected connection to robot at %s but got %+v", cl.Endpoint(), clientSide[0])
+	}
+	if clientSide[0].ProtocolRevision != ProtocolRevision3 || clientSide[0].Connected.Before(before) {
+		t.Fatalf("Unexpected connection details %+v", clientSide[0])
+	}
+	waitFor(t, "server to register the client", func() bool {
+		return len(srv.Connections()) == 1
+	})
+	serverSide := srv.Connections()[0]
+	if serverSide.RemoteIdentity != "dashboard" {
+		t.Fatalf("Expected identity dashboard but got %s", serverSide.RemoteIdentity)
+	}
+
+	cl.Double("/dashboard/value").Set(1)
+	waitFor(t, "activity to be recorded", func() bool {
+		return srv.Connections()[0].LastActivity.After(serverSide.LastActivity)
+	})
+
+	cl.Close()
+	waitFor(t, "server to drop the client", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(srv.Connections()) == 0 && len(events) == 2
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || !events[0].Connected || events[1].Connected {
+		t.Fatalf("Expected a connect and a disconnect event but got %+v", events)
+	}
+	if events[1].Info.RemoteIdentity != "dashboard" {
+		t.Fatalf("Expected disconnect of dashboard but got %s", events[1].Info.RemoteIdentity)
+	}
+}
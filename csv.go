@@ -0,0 +1,224 @@
+package ntgo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// csvSample is one change to a column of a CSVExport. A nil value clears
+// the column, as when the entry is deleted or an array shrinks.
+type csvSample struct {
+	time   time.Duration
+	column string
+	value  *float64
+}
+
+// CSVExport collects the history of the number and boolean entries under a
+// prefix, for writing as a wide CSV table: a time column, in seconds from
+// the start of the export, then one column per entry. Booleans are written
+// as 1 and 0, and each element of a double array gets a column of its own,
+// named after the entry with the index in brackets. Other entries are left
+// out.
+type CSVExport struct {
+	mu      sync.Mutex
+	columns map[string]bool
+	samples []csvSample
+	// lengths holds the length of each array entry as last seen, so that
+	// elements dropped by a shorter array can be cleared.
+	lengths map[string]int
+
+	table    *Table
+	listener int
+	start    time.Time
+}
+
+func newCSVExport() *CSVExport {
+	return &CSVExport{columns: map[string]bool{}, lengths: map[string]int{}}
+}
+
+// NewCSVExport starts collecting the entries of nt under prefix, beginning
+// with their current values, until Close is called.
+func NewCSVExport(nt *NetworkTables, prefix string) *CSVExport {
+	export := newCSVExport()
+	export.table, export.start = nt.Table(), time.Now()
+	export.listener = export.table.AddListener(prefix, func(event EntryEvent) {
+		elapsed := event.Time.Sub(export.start)
+		if event.Kind == EntryEventDeleted {
+			export.add(elapsed, event.Entry.Name.Value, nil)
+		} else if event.Kind != EntryEventFlagsUpdated {
+			export.add(elapsed, event.Entry.Name.Value, event.Entry.Value)
+		}
+	})
+	for _, entry := range export.table.Entries(prefix) {
+		export.add(0, entry.Name.Value, entry.Value)
+	}
+	return export
+}
+
+// ExportCSV writes the entries under prefix that a recording changed as a
+// CSV table, as CSVExport does. rate is passed on to CSVExport.WriteCSV.
+func ExportCSV(w io.Writer, recording io.Reader, prefix string, rate time.Duration) error {
+	events, readErr := readReplayEvents(recording)
+	if readErr != nil {
+		return readErr
+	}
+	export := newCSVExport()
+	for _, event := range events {
+		name := event.entry.Name.Value
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if event.deleted {
+			export.add(event.time, name, nil)
+		} else {
+			export.add(event.time, name, event.entry.Value)
+		}
+	}
+	return export.WriteCSV(w, rate)
+}
+
+// add records the value of an entry at a time, or its deletion if value is
+// nil.
+func (export *CSVExport) add(at time.Duration, name string, value EntryValue) {
+	export.mu.Lock()
+	defer export.mu.Unlock()
+	set := func(column string, number *float64) {
+		if number != nil {
+			export.columns[column] = true
+		}
+		export.samples = append(export.samples, csvSample{time: at, column: column, value: number})
+	}
+	// Anything other than a number clears the column of the entry, if it
+	// had one: it was deleted, or became an array or another type.
+	var number *float64
+	if scalar, ok := numericValue(value); ok {
+		number = &scalar
+	}
+	elements := []float64{}
+	if array, ok := value.(*ValueDoubleArray); ok {
+		elements = array.Values()
+	}
+	if number != nil || export.columns[name] {
+		set(name, number)
+	}
+	for i := range elements {
+		set(csvElement(name, i), &elements[i])
+	}
+	for i := len(elements); i < export.lengths[name]; i++ {
+		set(csvElement(name, i), nil)
+	}
+	if len(elements) > 0 {
+		export.lengths[name] = len(elements)
+	} else {
+		delete(export.lengths, name)
+	}
+}
+
+func csvElement(name string, index int) string {
+	return fmt.Sprintf("%s[%d]", name, index)
+}
+
+// Close stops collecting. What has been collected can still be written.
+func (export *CSVExport) Close() {
+	if export.table != nil {
+		export.table.RemoveListener(export.listener)
+	}
+}
+
+// WriteCSV writes the table collected so far. With a rate of zero there is
+// a row for every moment a value changed; otherwise the history is
+// resampled to a row every rate, starting at zero. Either way each row
+// holds the latest value of every column at its time, and cells are empty
+// before an entry has a value and after it is deleted.
+func (export *CSVExport) WriteCSV(w io.Writer, rate time.Duration) error {
+	export.mu.Lock()
+	samples := append([]csvSample{}, export.samples...)
+	columns := make([]string, 0, len(export.columns))
+	for column := range export.columns {
+		columns = append(columns, column)
+	}
+	export.mu.Unlock()
+	sort.Slice(columns, func(i, j int) bool { return csvColumnLess(columns[i], columns[j]) })
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].time < samples[j].time })
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+
+	writer := csv.NewWriter(w)
+	if writeErr := writer.Write(append([]string{"time"}, columns...)); writeErr != nil {
+		return writeErr
+	}
+	row := make([]string, len(columns)+1)
+	writeRow := func(at time.Duration) error {
+		row[0] = strconv.FormatFloat(at.Seconds(), 'f', 6, 64)
+		return writer.Write(row)
+	}
+	next := time.Duration(0)
+	for i := 0; i < len(samples); {
+		at := samples[i].time
+		if rate > 0 {
+			for ; next < at; next += rate {
+				if writeErr := writeRow(next); writeErr != nil {
+					return writeErr
+				}
+			}
+		}
+		// Apply every sample at this time before writing it out.
+		changed := false
+		for ; i < len(samples) && samples[i].time == at; i++ {
+			column, ok := index[samples[i].column]
+			if !ok {
+				// Cleared, but never given a value.
+				continue
+			}
+			cell := ""
+			if samples[i].value != nil {
+				cell = strconv.FormatFloat(*samples[i].value, 'g', -1, 64)
+			}
+			changed = changed || row[column+1] != cell
+			row[column+1] = cell
+		}
+		if rate == 0 && changed {
+			if writeErr := writeRow(at); writeErr != nil {
+				return writeErr
+			}
+		}
+	}
+	if rate > 0 && len(samples) > 0 && next == samples[len(samples)-1].time {
+		if writeErr := writeRow(next); writeErr != nil {
+			return writeErr
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumnLess orders columns by name, with the elements of an array in
+// index order after one another.
+func csvColumnLess(a, b string) bool {
+	aName, aIndex := csvSplitElement(a)
+	bName, bIndex := csvSplitElement(b)
+	if aName != bName {
+		return aName < bName
+	}
+	return aIndex < bIndex
+}
+
+func csvSplitElement(column string) (string, int) {
+	open := strings.LastIndexByte(column, '[')
+	if open < 0 || !strings.HasSuffix(column, "]") {
+		return column, -1
+	}
+	index, parseErr := strconv.Atoi(column[open+1 : len(column)-1])
+	if parseErr != nil {
+		return column, -1
+	}
+	return column[:open], index
+}
@@ -0,0 +1,86 @@
+package ntgo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readCSV(t *testing.T, raw string) [][]string {
+	rows, err := csv.NewReader(strings.NewReader(raw)).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	return rows
+}
+
+func TestExportCSV(t *testing.T) {
+	cases := []struct {
+		rate     time.Duration
+		expected string
+	}{
+		// The flags update at 200ms changes no value, so it has no row.
+		{0, "time,/speed\n0.000000,1\n0.100000,2\n0.300000,\n"},
+		{75 * time.Millisecond, "time,/speed\n0.000000,1\n0.075000,1\n0.150000,2\n0.225000,2\n0.300000,\n"},
+	}
+	for _, c := range cases {
+		var buffer bytes.Buffer
+		if err := ExportCSV(&buffer, testRecording(t), "/", c.rate); err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if buffer.String() != c.expected {
+			t.Fatalf("Expected %q at rate %s but got %q", c.expected, c.rate, buffer.String())
+		}
+	}
+	var buffer bytes.Buffer
+	if err := ExportCSV(&buffer, testRecording(t), "/other", 0); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if buffer.String() != "time\n" {
+		t.Fatalf("Expected only a time column but got %q", buffer.String())
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	nt := &NetworkTables{}
+	nt.Double("/robot/speed").Set(1.5)
+	export := NewCSVExport(nt, "/robot")
+	nt.Boolean("/robot/enabled").Set(true)
+	pose, _ := BuildDoubleArrayFrom([]float64{1, 2, 3})
+	nt.SetValue("/robot/pose", EntryTypeDoubleArr, pose)
+	pose, _ = BuildDoubleArrayFrom([]float64{4, 5})
+	nt.SetValue("/robot/pose", EntryTypeDoubleArr, pose)
+	nt.SetValue("/robot/name", EntryTypeString, BuildString("ignored"))
+	nt.Double("/other").Set(2)
+	nt.Delete("/robot/speed")
+	export.Close()
+	nt.Double("/robot/speed").Set(3)
+
+	var buffer bytes.Buffer
+	if err := export.WriteCSV(&buffer, 0); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	rows := readCSV(t, buffer.String())
+	expected := [][]string{
+		{"time", "/robot/enabled", "/robot/pose[0]", "/robot/pose[1]", "/robot/pose[2]", "/robot/speed"},
+		{"", "", "", "", "", "1.5"},
+		{"", "1", "", "", "", "1.5"},
+		{"", "1", "1", "2", "3", "1.5"},
+		{"", "1", "4", "5", "", "1.5"},
+		{"", "1", "4", "5", "", ""},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows but got %d: %v", len(expected), len(rows), rows)
+	}
+	for i, row := range rows {
+		if i > 0 {
+			// Times depend on the clock; only the values are compared.
+			row[0] = ""
+		}
+		if strings.Join(row, ",") != strings.Join(expected[i], ",") {
+			t.Fatalf("Expected row %d to be %v but got %v", i, expected[i], row)
+		}
+	}
+}
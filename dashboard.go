@@ -0,0 +1,34 @@
+package ntgo
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// NewDashboardHandler serves a web dashboard for the entries of nt: a live
+// tree of every entry with its type, flags and sequence number, where
+// values can be edited, persistence toggled, entries deleted and remote
+// procedures called through a form built from their parameters. It needs
+// nothing but a browser. The handler serves
+//
+//	/        the dashboard itself
+//	/api/    a RESTHandler for nt
+//	/push    a PushHandler for nt
+//
+// and can be mounted under a prefix with http.StripPrefix, as every path the
+// dashboard uses is relative. allowedOrigins become the AllowedOrigins of
+// the push socket.
+func NewDashboardHandler(nt *NetworkTables, allowedOrigins ...string) http.Handler {
+	static, _ := fs.Sub(dashboardFiles, "dashboard")
+	push := NewPushHandler(nt)
+	push.AllowedOrigins = allowedOrigins
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", NewRESTHandler(nt)))
+	mux.Handle("/push", push)
+	mux.Handle("/", http.FileServerFS(static))
+	return mux
+}
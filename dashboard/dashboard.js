@@ -0,0 +1,372 @@
+"use strict";
+
+// The dashboard keeps a copy of every entry, fed by the push socket, and
+// shows it as a tree. Edits go back over the socket; procedure calls go
+// through the REST API. Every URL is relative to the page, so the dashboard
+// works wherever it is mounted.
+
+const entries = new Map();
+// rows holds the row of every entry, keyed "e" + name, and of every folder,
+// keyed "d" + path, since an entry and a folder may share a name.
+const rows = new Map();
+const collapsed = new Set();
+const changed = new Set();
+let restructure = true;
+let renderPending = false;
+let socket = null;
+
+const tbody = document.getElementById("entries");
+const filter = document.getElementById("filter");
+const statusLine = document.getElementById("status");
+const errorLine = document.getElementById("error");
+
+function connect() {
+  const url = new URL("push", location.href);
+  url.protocol = location.protocol === "https:" ? "wss:" : "ws:";
+  socket = new WebSocket(url);
+  socket.onopen = () => {
+    // The first frame is a snapshot of every entry.
+    entries.clear();
+    restructure = true;
+    setStatus("connected");
+  };
+  socket.onclose = () => {
+    setStatus("disconnected, retrying");
+    setTimeout(connect, 1000);
+  };
+  socket.onmessage = (message) => {
+    const data = JSON.parse(message.data);
+    if (!Array.isArray(data)) {
+      showError(data.name ? data.name + ": " + data.error : data.error);
+      return;
+    }
+    for (const event of data) {
+      apply(event);
+    }
+    scheduleRender();
+  };
+}
+
+function setStatus(text) {
+  statusLine.textContent = text;
+  statusLine.classList.toggle("connected", text === "connected");
+}
+
+function showError(text) {
+  errorLine.textContent = text;
+  errorLine.hidden = false;
+  clearTimeout(showError.timer);
+  showError.timer = setTimeout(() => { errorLine.hidden = true; }, 5000);
+}
+
+function send(command) {
+  if (!socket || socket.readyState !== WebSocket.OPEN) {
+    showError("not connected");
+    return;
+  }
+  socket.send(JSON.stringify(command));
+}
+
+function apply(event) {
+  const name = event.entry.name;
+  if (event.event === "deleted") {
+    entries.delete(name);
+    restructure = true;
+    return;
+  }
+  if (!entries.has(name)) {
+    restructure = true;
+  }
+  entries.set(name, event.entry);
+  changed.add(name);
+}
+
+function scheduleRender() {
+  if (!renderPending) {
+    renderPending = true;
+    requestAnimationFrame(render);
+  }
+}
+
+function render() {
+  renderPending = false;
+  if (restructure) {
+    layout();
+    restructure = false;
+  }
+  for (const name of changed) {
+    const entry = entries.get(name);
+    const row = rows.get("e" + name);
+    if (entry && row) {
+      update(row, entry);
+      flash(row);
+    }
+  }
+  changed.clear();
+}
+
+// layout puts a row in the table for every folder and entry, in tree
+// order, and hides the ones filtered out or inside a collapsed folder.
+function layout() {
+  const query = filter.value.toLowerCase();
+  const names = [...entries.keys()].sort();
+  const order = [];
+  const placed = new Set();
+  const visible = new Set();
+  for (const name of names) {
+    const parts = name.split("/").filter((part) => part !== "");
+    const folders = [];
+    let path = "";
+    for (let depth = 0; depth < parts.length - 1; depth++) {
+      path += "/" + parts[depth];
+      const key = "d" + path;
+      if (!rows.has(key)) {
+        rows.set(key, folderRow(path, parts[depth], depth));
+      }
+      if (!placed.has(key)) {
+        placed.add(key);
+        order.push(key);
+      }
+      folders.push(key);
+    }
+    const key = "e" + name;
+    if (!rows.has(key)) {
+      const row = entryRow(name, parts[parts.length - 1] ?? name, Math.max(parts.length - 1, 0));
+      row.dataset.path = "/" + parts.join("/");
+      rows.set(key, row);
+      changed.add(name);
+    }
+    placed.add(key);
+    order.push(key);
+    if (name.toLowerCase().includes(query)) {
+      // A folder is shown when anything in it is.
+      visible.add(key);
+      folders.forEach((folder) => visible.add(folder));
+    }
+  }
+  for (const key of [...rows.keys()]) {
+    if (!placed.has(key)) {
+      rows.get(key).remove();
+      rows.delete(key);
+    }
+  }
+  // Rows already in place are left alone, so an edit in progress keeps its
+  // focus.
+  order.forEach((key, i) => {
+    const row = rows.get(key);
+    if (tbody.children[i] !== row) {
+      tbody.insertBefore(row, tbody.children[i] ?? null);
+    }
+    const path = row.dataset.path;
+    const inCollapsed = [...collapsed].some((folder) => path.startsWith(folder + "/"));
+    row.hidden = !visible.has(key) || inCollapsed;
+  });
+}
+
+function folderRow(path, label, depth) {
+  const row = document.createElement("tr");
+  row.className = "folder";
+  row.dataset.path = path;
+  const name = cell(row, "name", label);
+  name.style.paddingLeft = 1 + depth * 1.25 + "em";
+  name.title = path;
+  name.onclick = () => {
+    if (collapsed.has(path)) {
+      collapsed.delete(path);
+    } else {
+      collapsed.add(path);
+    }
+    row.classList.toggle("collapsed", collapsed.has(path));
+    layout();
+  };
+  row.append(document.createElement("td"), document.createElement("td"),
+    document.createElement("td"), document.createElement("td"), document.createElement("td"));
+  return row;
+}
+
+function entryRow(name, label, depth) {
+  const row = document.createElement("tr");
+  const nameCell = cell(row, "name", label);
+  nameCell.style.paddingLeft = 1 + depth * 1.25 + "em";
+  nameCell.title = name;
+  cell(row, "type", "");
+  cell(row, "value", "");
+  const persistent = document.createElement("input");
+  persistent.type = "checkbox";
+  persistent.onchange = () => {
+    send({ op: "flags", name: name, flags: persistent.checked ? "persistent" : "temporary" });
+  };
+  cell(row, "flags", "").append(persistent);
+  cell(row, "seq", "");
+  const remove = document.createElement("button");
+  remove.textContent = "Delete";
+  remove.onclick = () => {
+    if (confirm("Delete " + name + "?")) {
+      send({ op: "delete", name: name });
+    }
+  };
+  cell(row, "actions", "").append(remove);
+  return row;
+}
+
+function cell(row, className, text) {
+  const td = document.createElement("td");
+  td.className = className;
+  td.textContent = text;
+  row.append(td);
+  return td;
+}
+
+function update(row, entry) {
+  const [, type, value, flags, seq] = row.children;
+  type.textContent = entry.type;
+  seq.textContent = entry.sequence;
+  flags.firstChild.checked = entry.flags === "persistent";
+  flags.firstChild.disabled = entry.type === "rpc";
+  const definition = JSON.stringify(entry.value);
+  if (value.dataset.type !== entry.type || (entry.type === "rpc" && value.dataset.definition !== definition)) {
+    value.replaceChildren(entry.type === "rpc" ? rpcForm(entry) : editor(entry));
+    value.dataset.type = entry.type;
+    value.dataset.definition = definition;
+  }
+  const input = value.firstChild;
+  if (entry.type === "boolean") {
+    input.checked = entry.value;
+  } else if (entry.type !== "rpc" && document.activeElement !== input) {
+    input.value = formatValue(entry.type, entry.value);
+  }
+}
+
+function flash(row) {
+  row.classList.remove("changed");
+  // Reading the layout restarts the animation.
+  void row.offsetWidth;
+  row.classList.add("changed");
+}
+
+// editor returns an input for the value of entry that sets it when changed.
+function editor(entry) {
+  const input = document.createElement("input");
+  if (entry.type === "boolean") {
+    input.type = "checkbox";
+    input.onchange = () => send({ op: "set", name: entry.name, type: entry.type, value: input.checked });
+    return input;
+  }
+  input.type = "text";
+  input.onchange = () => {
+    try {
+      send({ op: "set", name: entry.name, type: entry.type, value: parseValue(entry.type, input.value) });
+    } catch (err) {
+      showError(entry.name + ": " + err.message);
+    }
+  };
+  input.onkeydown = (event) => {
+    if (event.key === "Escape") {
+      input.value = formatValue(entry.type, entries.get(entry.name)?.value);
+      input.blur();
+    }
+  };
+  return input;
+}
+
+function formatValue(type, value) {
+  switch (type) {
+  case "string":
+  case "raw":
+  case "double":
+    return String(value);
+  }
+  return JSON.stringify(value);
+}
+
+// parseValue reads a value as typed into an editor: strings and raw data
+// (in base64) as they are, and everything else as JSON.
+function parseValue(type, text) {
+  switch (type) {
+  case "string":
+  case "raw":
+    return text;
+  case "boolean":
+    return text === "true" || text === "1";
+  case "double": {
+    const number = Number(text.trim());
+    // NaN and the infinities are sent by name, as the server expects.
+    return text.trim() !== "" && Number.isFinite(number) ? number : text.trim();
+  }
+  }
+  return JSON.parse(text);
+}
+
+// rpcForm returns a form with an input for every parameter of the
+// procedure, filled in with its default, that calls it when submitted.
+function rpcForm(entry) {
+  const form = document.createElement("form");
+  form.className = "rpc";
+  const inputs = [];
+  for (const param of entry.value.params) {
+    const label = document.createElement("label");
+    label.textContent = param.name + " (" + param.type + ") ";
+    const input = document.createElement("input");
+    if (param.type === "boolean") {
+      input.type = "checkbox";
+      input.checked = param.default;
+    } else {
+      input.type = "text";
+      input.value = formatValue(param.type, param.default);
+    }
+    label.append(input);
+    form.append(label);
+    inputs.push([param, input]);
+  }
+  const call = document.createElement("button");
+  call.textContent = "Call";
+  const output = document.createElement("output");
+  form.append(call, output);
+  form.onsubmit = async (event) => {
+    event.preventDefault();
+    const params = {};
+    try {
+      for (const [param, input] of inputs) {
+        params[param.name] = param.type === "boolean" ? input.checked : parseValue(param.type, input.value);
+      }
+    } catch (err) {
+      showError(entry.name + ": " + err.message);
+      return;
+    }
+    output.textContent = "calling";
+    try {
+      // The key parameter reaches any name, even one without a leading slash.
+      const response = await fetch(new URL("api/rpc/?key=" + encodeURIComponent(entry.name), location.href), {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ params: params }),
+      });
+      const body = await response.json();
+      if (!response.ok) {
+        output.textContent = "";
+        showError(entry.name + ": " + body.error);
+        return;
+      }
+      output.textContent = body.results.map((result) => result.name + " = " + JSON.stringify(result.value)).join(", ");
+    } catch (err) {
+      output.textContent = "";
+      showError(entry.name + ": " + err.message);
+    }
+  };
+  return form;
+}
+
+document.getElementById("create").onsubmit = (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const type = form.elements.type.value;
+  try {
+    send({ op: "set", name: form.elements.name.value, type: type, value: parseValue(type, form.elements.value.value) });
+  } catch (err) {
+    showError(form.elements.name.value + ": " + err.message);
+  }
+};
+
+filter.oninput = () => layout();
+
+connect();
@@ -0,0 +1,34 @@
+package ntgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	nt := &NetworkTables{}
+	nt.Double("/SmartDashboard/speed").Set(1.5)
+	server := httptest.NewServer(NewDashboardHandler(nt))
+	defer server.Close()
+
+	cases := []struct {
+		path     string
+		status   int
+		contains string
+	}{
+		{"/", http.StatusOK, "dashboard.js"},
+		{"/dashboard.js", http.StatusOK, "new WebSocket"},
+		{"/dashboard.css", http.StatusOK, "table"},
+		{"/api/entries/SmartDashboard/speed", http.StatusOK, `"value":1.5`},
+		{"/api/entries/SmartDashboard/missing", http.StatusNotFound, "error"},
+		{"/missing.html", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		status, body := restRequest(t, server, http.MethodGet, c.path, "")
+		if status != c.status || !strings.Contains(body, c.contains) {
+			t.Fatalf("Expected status %d with %q for %s but got %d: %s", c.status, c.contains, c.path, status, body)
+		}
+	}
+}
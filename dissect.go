@@ -0,0 +1,235 @@
+package ntgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// dissectMaxPending is how many bytes a message that has not been completed
+// yet may take before its first byte is given up on as unknown. A garbage
+// byte can look like the start of a string of any length.
+const dissectMaxPending = 1 << 20
+
+// Dissection is one line of a transcript: a message read from a byte stream,
+// or the bytes that had to be skipped to find the next one.
+type Dissection struct {
+	// Time is when the last byte of the message was captured. It is zero
+	// for raw byte streams.
+	Time time.Time
+	// Client and Server are the addresses of the connection. They are empty
+	// for raw byte streams.
+	Client, Server string
+	// FromServer is set for messages the server sent.
+	FromServer bool
+	// Message is the message read, or nil when Unknown or Missing is set.
+	Message *Message
+	// Name is the entry the message concerns, when the message only carries
+	// its ID and the connection assigned that ID earlier.
+	Name string
+	// Unknown holds bytes that could not be read as a message.
+	Unknown []byte
+	// Missing counts bytes of the connection that were not captured.
+	Missing int
+}
+
+// String returns the dissection as a line of a transcript.
+func (dissection Dissection) String() string {
+	line := &strings.Builder{}
+	if !dissection.Time.IsZero() {
+		line.WriteString(dissection.Time.Format("15:04:05.000000 "))
+	}
+	if dissection.Client != "" {
+		if dissection.FromServer {
+			fmt.Fprintf(line, "%s > %s ", dissection.Server, dissection.Client)
+		} else {
+			fmt.Fprintf(line, "%s > %s ", dissection.Client, dissection.Server)
+		}
+	}
+	switch {
+	case dissection.Missing > 0:
+		fmt.Fprintf(line, "[%d bytes not captured]", dissection.Missing)
+	case dissection.Message == nil:
+		fmt.Fprintf(line, "[%d unknown bytes] %s", len(dissection.Unknown), hex.EncodeToString(dissection.Unknown))
+	default:
+		line.WriteString(describeMessage(dissection.Message, dissection.Name))
+	}
+	return line.String()
+}
+
+// describeMessage returns the type of message followed by its contents.
+// name, if known, is shown next to entry IDs.
+func describeMessage(message *Message, name string) string {
+	entryID := func(id [2]byte) string {
+		if name == "" {
+			return fmt.Sprintf("id %d", binary.BigEndian.Uint16(id[:]))
+		}
+		return fmt.Sprintf("%s (id %d)", name, binary.BigEndian.Uint16(id[:]))
+	}
+	detail := ""
+	switch data := message.Data.(type) {
+	case *MessageDataClientHello:
+		detail = fmt.Sprintf("revision %d.%d, identity %q", data.ProtocVersion[0], data.ProtocVersion[1], data.Identity.Value)
+	case *MessageDataProtocVersionUnsupported:
+		detail = fmt.Sprintf("supported revision %d.%d", data.SupportedProtoc[0], data.SupportedProtoc[1])
+	case *MessageDataServerHello:
+		detail = fmt.Sprintf("identity %q", data.Identity.Value)
+		if data.Flags == FlagMessageClientSeen {
+			detail += ", client seen before"
+		}
+	case *MessageDataEntryAssignment:
+		detail = data.Entry.String()
+	case *MessageDataEntryUpdate:
+		detail = fmt.Sprintf("%s, seq %d (%s) = %v", entryID(data.Entry.ID), binary.BigEndian.Uint16(data.Entry.Sequence[:]), data.Entry.Type, data.Entry.Value)
+	case *MessageDataEntryFlagsUpdate:
+		detail = fmt.Sprintf("%s %s", entryID(data.Entry.ID), data.Entry.Flags)
+	case *MessageDataEntryDelete:
+		detail = entryID(data.Entry.ID)
+	case *MessageDataRPCExecute:
+		detail = fmt.Sprintf("%s, call %d, %d bytes of parameters", entryID(data.EntryID), binary.BigEndian.Uint16(data.UniqueID[:]), len(data.Params))
+	case *MessageDataRPCResponse:
+		detail = fmt.Sprintf("%s, call %d, %d bytes of results", entryID(data.EntryID), binary.BigEndian.Uint16(data.UniqueID[:]), len(data.Results))
+	}
+	if detail == "" {
+		return message.Type.String()
+	}
+	return message.Type.String() + " " + detail
+}
+
+// DissectStream reads a raw NetworkTables 3.0 byte stream, one direction of
+// a connection, and hands every message in it to handle. Bytes that cannot
+// be read as a message are skipped one at a time until a message can be
+// read again, and reported together.
+func DissectStream(r io.Reader, handle func(Dissection)) error {
+	conversation := &dissectConversation{names: map[[2]byte]string{}, handle: handle}
+	stream := &dissectStream{conversation: conversation}
+	buffer := make([]byte, 32*1024)
+	for {
+		n, readErr := r.Read(buffer)
+		stream.buffer = append(stream.buffer, buffer[:n]...)
+		if readErr == io.EOF {
+			stream.decode(time.Time{}, true)
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+		stream.decode(time.Time{}, false)
+	}
+}
+
+// dissectConversation is one connection. Entry IDs are shared by both of
+// its directions.
+type dissectConversation struct {
+	client, server string
+	names          map[[2]byte]string
+	streams        [2]*dissectStream
+	handle         func(Dissection)
+}
+
+// dissectStream is one direction of a conversation.
+type dissectStream struct {
+	conversation *dissectConversation
+	fromServer   bool
+	buffer       []byte
+	unknown      []byte
+
+	// TCP reassembly: the sequence number of the next byte expected, and
+	// segments that arrived ahead of it.
+	started bool
+	next    uint32
+	ahead   map[uint32][]byte
+}
+
+func (stream *dissectStream) emit(dissection Dissection) {
+	dissection.Client, dissection.Server = stream.conversation.client, stream.conversation.server
+	dissection.FromServer = stream.fromServer
+	stream.conversation.handle(dissection)
+}
+
+// decode reads every message in the buffer. A message cut off by the end of
+// the buffer is left for more data to complete, unless final is set.
+func (stream *dissectStream) decode(at time.Time, final bool) {
+	for len(stream.buffer) > 0 {
+		reader := bytes.NewReader(stream.buffer)
+		message, decodeErr := DecodeMessage(reader)
+		if decodeErr == nil && plausibleMessage(message) {
+			stream.flushUnknown(at)
+			stream.buffer = stream.buffer[len(stream.buffer)-reader.Len():]
+			stream.emit(Dissection{Time: at, Message: message, Name: stream.conversation.name(message)})
+			continue
+		}
+		// Running out of bytes is the only failure more data can fix.
+		if decodeErr != nil && reader.Len() == 0 && !final && len(stream.buffer) < dissectMaxPending {
+			return
+		}
+		stream.unknown = append(stream.unknown, stream.buffer[0])
+		stream.buffer = stream.buffer[1:]
+	}
+	if final {
+		stream.flushUnknown(at)
+	}
+}
+
+func (stream *dissectStream) flushUnknown(at time.Time) {
+	if len(stream.unknown) == 0 {
+		return
+	}
+	stream.emit(Dissection{Time: at, Unknown: stream.unknown})
+	stream.unknown = nil
+}
+
+// plausibleMessage rules out messages that decode but could not have been
+// sent, so that resynchronizing does not settle on garbage too easily.
+func plausibleMessage(message *Message) bool {
+	switch data := message.Data.(type) {
+	case *MessageDataClientHello:
+		return data.ProtocVersion == ProtocolRevision3 && utf8.ValidString(data.Identity.Value)
+	case *MessageDataProtocVersionUnsupported:
+		return data.SupportedProtoc == ProtocolRevision3 || data.SupportedProtoc == ProtocolRevision2
+	case *MessageDataServerHello:
+		return utf8.ValidString(data.Identity.Value)
+	case *MessageDataEntryAssignment:
+		return utf8.ValidString(data.Entry.Name.Value) && data.Entry.Flags != EntryFlagReserved
+	case *MessageDataEntryFlagsUpdate:
+		return data.Entry.Flags != EntryFlagReserved
+	case *MessageDataClearAll:
+		return data.PotentialMagic == DangerousMagic
+	}
+	return true
+}
+
+// name keeps track of the entry IDs the conversation assigns, and returns
+// the name of the entry message concerns if it only carries an ID.
+func (conversation *dissectConversation) name(message *Message) string {
+	var id [2]byte
+	switch data := message.Data.(type) {
+	case *MessageDataEntryAssignment:
+		if data.Entry.ID != EntryIDUnassigned {
+			conversation.names[data.Entry.ID] = data.Entry.Name.Value
+		}
+		return ""
+	case *MessageDataClearAll:
+		clear(conversation.names)
+		return ""
+	case *MessageDataEntryUpdate:
+		id = data.Entry.ID
+	case *MessageDataEntryFlagsUpdate:
+		id = data.Entry.ID
+	case *MessageDataEntryDelete:
+		id = data.Entry.ID
+		defer delete(conversation.names, id)
+	case *MessageDataRPCExecute:
+		id = data.EntryID
+	case *MessageDataRPCResponse:
+		id = data.EntryID
+	default:
+		return ""
+	}
+	return conversation.names[id]
+}
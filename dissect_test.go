@@ -0,0 +1,246 @@
+package ntgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+var (
+	dissectClient = netip.MustParseAddrPort("10.12.34.5:51234")
+	dissectServer = netip.MustParseAddrPort("10.12.34.2:1735")
+)
+
+// tcpFrame builds an Ethernet frame carrying a TCP segment over IPv4.
+func tcpFrame(source, destination netip.AddrPort, sequence uint32, flags byte, payload []byte) []byte {
+	frame := make([]byte, 12, 54+len(payload))
+	frame = binary.BigEndian.AppendUint16(frame, 0x0800)
+	frame = append(frame, 0x45, 0)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(40+len(payload)))
+	frame = append(frame, 0, 0, 0x40, 0, 64, 6, 0, 0)
+	frame = append(frame, source.Addr().AsSlice()...)
+	frame = append(frame, destination.Addr().AsSlice()...)
+	frame = binary.BigEndian.AppendUint16(frame, source.Port())
+	frame = binary.BigEndian.AppendUint16(frame, destination.Port())
+	frame = binary.BigEndian.AppendUint32(frame, sequence)
+	frame = append(frame, 0, 0, 0, 0, 0x50, flags, 0xFF, 0xFF, 0, 0, 0, 0)
+	return append(frame, payload...)
+}
+
+type capturedFrame struct {
+	at    time.Time
+	frame []byte
+}
+
+func pcapFile(frames []capturedFrame) []byte {
+	raw := binary.LittleEndian.AppendUint32(nil, 0xA1B2C3D4)
+	raw = binary.LittleEndian.AppendUint16(raw, 2)
+	raw = binary.LittleEndian.AppendUint16(raw, 4)
+	raw = append(raw, make([]byte, 8)...)
+	raw = binary.LittleEndian.AppendUint32(raw, 65535)
+	raw = binary.LittleEndian.AppendUint32(raw, linkTypeEthernet)
+	for _, frame := range frames {
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(frame.at.Unix()))
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(frame.at.Nanosecond()/1000))
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(len(frame.frame)))
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(len(frame.frame)))
+		raw = append(raw, frame.frame...)
+	}
+	return raw
+}
+
+// pcapngFile writes a big-endian pcapng file with nanosecond timestamps.
+func pcapngFile(frames []capturedFrame) []byte {
+	block := func(raw []byte, blockType uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		raw = binary.BigEndian.AppendUint32(raw, blockType)
+		raw = binary.BigEndian.AppendUint32(raw, uint32(12+len(body)))
+		raw = append(raw, body...)
+		return binary.BigEndian.AppendUint32(raw, uint32(12+len(body)))
+	}
+	section := binary.BigEndian.AppendUint32(nil, 0x1A2B3C4D)
+	section = append(section, 0, 1, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	raw := block(nil, 0x0A0D0D0A, section)
+	iface := []byte{0, linkTypeEthernet, 0, 0, 0, 0, 0xFF, 0xFF, 0, 9, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0}
+	raw = block(raw, 1, iface)
+	for _, frame := range frames {
+		timestamp := uint64(frame.at.UnixNano())
+		packet := binary.BigEndian.AppendUint32(nil, 0)
+		packet = binary.BigEndian.AppendUint32(packet, uint32(timestamp>>32))
+		packet = binary.BigEndian.AppendUint32(packet, uint32(timestamp))
+		packet = binary.BigEndian.AppendUint32(packet, uint32(len(frame.frame)))
+		packet = binary.BigEndian.AppendUint32(packet, uint32(len(frame.frame)))
+		raw = block(raw, 6, append(packet, frame.frame...))
+	}
+	return raw
+}
+
+func dissectAll(t *testing.T, dissect func(handle func(Dissection)) error) []Dissection {
+	dissections := []Dissection{}
+	if err := dissect(func(dissection Dissection) { dissections = append(dissections, dissection) }); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	return dissections
+}
+
+func TestDissectStream(t *testing.T) {
+	id := [2]byte{0x00, 0x07}
+	stream := BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{Name: BuildString("/speed"), Type: EntryTypeDouble, ID: id, Flags: EntryFlagTemporary, Value: BuildDouble(1)}}).GetRaw()
+	stream = append(stream, 0xFF, 0xFE)
+	stream = append(stream, BuildMessage(&MessageDataEntryUpdate{Entry: &Entry{Type: EntryTypeDouble, ID: id, Value: BuildDouble(2)}}).GetRaw()...)
+	// A Keep Alive, then an Entry Delete cut off by the end of the stream.
+	stream = append(stream, 0x00, 0x13, 0x01)
+	dissections := dissectAll(t, func(handle func(Dissection)) error {
+		return DissectStream(bytes.NewReader(stream), handle)
+	})
+	if len(dissections) != 5 {
+		t.Fatalf("Expected 5 dissections but got %d: %v", len(dissections), dissections)
+	}
+	if dissections[0].Message == nil || dissections[0].Message.Type != MessageTypeEntryAssignment {
+		t.Fatalf("Expected an Entry Assignment but got %s", dissections[0])
+	}
+	if !bytes.Equal(dissections[1].Unknown, []byte{0xFF, 0xFE}) {
+		t.Fatalf("Expected unknown bytes ff fe but got %s", dissections[1])
+	}
+	if dissections[2].Message == nil || dissections[2].Name != "/speed" {
+		t.Fatalf("Expected an Entry Update of /speed but got %s", dissections[2])
+	}
+	if expected := "Entry Update /speed (id 7), seq 0 (double) = 2"; dissections[2].String() != expected {
+		t.Fatalf("Expected \"%s\" but got \"%s\"", expected, dissections[2])
+	}
+	if dissections[3].Message == nil || dissections[3].Message.Type != MessageTypeKeepAlive {
+		t.Fatalf("Expected a Keep Alive but got %s", dissections[3])
+	}
+	if !bytes.Equal(dissections[4].Unknown, []byte{0x13, 0x01}) {
+		t.Fatalf("Expected the cut off message as unknown bytes but got %s", dissections[4])
+	}
+}
+
+func TestDissectStreamHugeLengths(t *testing.T) {
+	// Entry Assignments whose names claim to be 4 GB long; resynchronizing
+	// tries each byte as a message start, and none may allocate the claim.
+	stream := bytes.Repeat([]byte{0x10, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, 20)
+	stream = append(stream, 0x00)
+	dissections := dissectAll(t, func(handle func(Dissection)) error {
+		return DissectStream(bytes.NewReader(stream), handle)
+	})
+	if len(dissections) != 2 || len(dissections[0].Unknown) != len(stream)-1 {
+		t.Fatalf("Expected %d unknown bytes and a Keep Alive but got %v", len(stream)-1, dissections)
+	}
+	if dissections[1].Message == nil || dissections[1].Message.Type != MessageTypeKeepAlive {
+		t.Fatalf("Expected a Keep Alive but got %s", dissections[1])
+	}
+	// RPC Execute and Response messages whose parameters and results
+	// claim to be 4 GB long.
+	for _, messageType := range []byte{0x20, 0x21} {
+		stream := []byte{messageType, 0x7F, 0x7F, 0x7F, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F, 0x00}
+		if _, err := DecodeMessage(bytes.NewReader(stream[:len(stream)-1])); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Expected error \"%s\" but received \"%s\"", io.ErrUnexpectedEOF, err)
+		}
+		dissections := dissectAll(t, func(handle func(Dissection)) error {
+			return DissectStream(bytes.NewReader(stream), handle)
+		})
+		if len(dissections) != 2 || len(dissections[0].Unknown) != len(stream)-1 {
+			t.Fatalf("Expected %d unknown bytes and a Keep Alive but got %v", len(stream)-1, dissections)
+		}
+	}
+}
+
+func TestDissectCapture(t *testing.T) {
+	hello := BuildMessage(&MessageDataClientHello{ProtocVersion: ProtocolRevision3, Identity: BuildString("dashboard")}).GetRaw()
+	serverHello := BuildMessage(&MessageDataServerHello{Flags: FlagMessageClientNew, Identity: BuildString("robot")}).GetRaw()
+	serverHello = append(serverHello, BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{Name: BuildString("/mode"), Type: EntryTypeString, ID: [2]byte{0, 1}, Flags: EntryFlagPersistent, Value: BuildString("auto")}}).GetRaw()...)
+	serverHello = append(serverHello, BuildMessage(&MessageDataServerHelloComplete{}).GetRaw()...)
+	update := BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &Entry{ID: [2]byte{0, 1}, Flags: EntryFlagTemporary}}).GetRaw()
+	start := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	at := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+	other := netip.MustParseAddrPort("10.12.34.2:80")
+	frames := []capturedFrame{
+		{at(0), tcpFrame(dissectClient, dissectServer, 999, 0x02, nil)},
+		{at(1), tcpFrame(dissectServer, dissectClient, 4999, 0x12, nil)},
+		// The Client Hello is split in two, and the halves arrive out of
+		// order; the first is then retransmitted.
+		{at(2), tcpFrame(dissectClient, dissectServer, 1005, 0x18, hello[5:])},
+		{at(3), tcpFrame(dissectClient, dissectServer, 1000, 0x18, hello[:5])},
+		{at(4), tcpFrame(dissectClient, dissectServer, 1000, 0x18, hello[:5])},
+		{at(5), tcpFrame(dissectClient, other, 1, 0x18, []byte{0x00})},
+		{at(6), tcpFrame(dissectServer, dissectClient, 5000, 0x18, serverHello)},
+		{at(7), tcpFrame(dissectClient, dissectServer, 1000+uint32(len(hello)), 0x19, update)},
+	}
+	expected := []struct {
+		at          time.Time
+		fromServer  bool
+		messageType MessageType
+		name        string
+	}{
+		{at(3), false, MessageTypeClientHello, ""},
+		{at(6), true, MessageTypeServerHello, ""},
+		{at(6), true, MessageTypeEntryAssignment, ""},
+		{at(6), true, MessageTypeServerHelloComplete, ""},
+		{at(7), false, MessageTypeEntryFlagsUpdate, "/mode"},
+	}
+	captures := map[string][]byte{"pcap": pcapFile(frames), "pcapng": pcapngFile(frames)}
+	for format, capture := range captures {
+		dissections := dissectAll(t, func(handle func(Dissection)) error {
+			return DissectCapture(bytes.NewReader(capture), "", handle)
+		})
+		if len(dissections) != len(expected) {
+			t.Fatalf("Expected %d dissections of the %s capture but got %d: %v", len(expected), format, len(dissections), dissections)
+		}
+		for i, dissection := range dissections {
+			e := expected[i]
+			if dissection.Message == nil || dissection.Message.Type != e.messageType || dissection.FromServer != e.fromServer || dissection.Name != e.name || !dissection.Time.Equal(e.at) {
+				t.Fatalf("Expected %s at %s from server %t in the %s capture but got %s", e.messageType, e.at, e.fromServer, format, dissection)
+			}
+			if dissection.Client != "10.12.34.5:51234" || dissection.Server != "10.12.34.2:1735" {
+				t.Fatalf("Expected the connection 10.12.34.5:51234 to 10.12.34.2:1735 but got %s to %s", dissection.Client, dissection.Server)
+			}
+		}
+	}
+}
+
+func TestDissectCaptureMissing(t *testing.T) {
+	first := BuildMessage(&MessageDataEntryDelete{Entry: &Entry{ID: [2]byte{0, 1}}}).GetRaw()
+	lost := BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{Name: BuildString("/lost/entry"), Type: EntryTypeBoolean, ID: [2]byte{0, 2}, Value: BuildBoolean(true)}}).GetRaw()
+	last := BuildMessage(&MessageDataEntryDelete{Entry: &Entry{ID: [2]byte{0, 3}}}).GetRaw()
+	start := time.Unix(1700000000, 0)
+	// The capture starts mid-connection, and a segment goes missing.
+	frames := []capturedFrame{
+		{start, tcpFrame(dissectServer, dissectClient, 70000, 0x18, append(first, lost[:4]...))},
+		{start.Add(time.Second), tcpFrame(dissectServer, dissectClient, 70000+uint32(len(first)+len(lost)), 0x18, last)},
+	}
+	dissections := dissectAll(t, func(handle func(Dissection)) error {
+		return DissectCapture(bytes.NewReader(pcapFile(frames)), "1735", handle)
+	})
+	if len(dissections) != 4 {
+		t.Fatalf("Expected 4 dissections but got %d: %v", len(dissections), dissections)
+	}
+	if dissections[0].Message == nil || dissections[0].Message.Type != MessageTypeEntryDelete {
+		t.Fatalf("Expected an Entry Delete but got %s", dissections[0])
+	}
+	if !bytes.Equal(dissections[1].Unknown, lost[:4]) {
+		t.Fatalf("Expected the start of the lost message as unknown bytes but got %s", dissections[1])
+	}
+	if dissections[2].Missing != len(lost)-4 {
+		t.Fatalf("Expected %d bytes missing but got %s", len(lost)-4, dissections[2])
+	}
+	if dissections[3].Message == nil || !bytes.Equal(dissections[3].Message.GetRaw(), last) {
+		t.Fatalf("Expected the Entry Delete after the gap but got %s", dissections[3])
+	}
+}
+
+func TestDissectCaptureInvalid(t *testing.T) {
+	if err := DissectCapture(bytes.NewReader([]byte("NTLOG\x01 not a capture at all")), "", func(Dissection) {}); err != ErrCaptureInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrCaptureInvalid, err)
+	}
+	capture := pcapFile(nil)
+	binary.LittleEndian.PutUint32(capture[20:], 147)
+	if err := DissectCapture(bytes.NewReader(capture), "", func(Dissection) {}); err != ErrCaptureLinkType {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrCaptureLinkType, err)
+	}
+}
@@ -1,9 +1,12 @@
 package ntgo
 
 import (
+	"bytes"
 	"errors"
 	"github.com/imdario/mergo"
 	"io"
+	"iter"
+	"slices"
 )
 
 const (
@@ -28,6 +31,10 @@ const (
 	BoolTrue
 )
 
+// MaxArrayLength is the largest number of elements an array value can hold,
+// since the protocol encodes the length in a single byte.
+const MaxArrayLength = 255
+
 var (
 	ErrEntryTypeCastInvalid = errors.New("entry: could not cast entrytype to type")
 
@@ -58,15 +65,18 @@ type EntryValue interface {
 }
 
 type EntryValueArray interface {
+	Len() int
 	Get(uint8) (EntryValue, error)
 	Update(uint8, EntryValue) error
 	Add(EntryValue) error
+	Insert(uint8, EntryValue) error
+	Remove(uint8) error
 	EntryValue
 }
 
 func DecodeEntryFlag(r io.Reader) (EntryFlag, error) {
 	flagRaw := make([]byte, 1)
-	_, flagErr := r.Read(flagRaw)
+	_, flagErr := io.ReadFull(r, flagRaw)
 	if flagErr != nil {
 		return EntryFlagUndef, flagErr
 	}
@@ -81,7 +91,7 @@ func DecodeEntryFlag(r io.Reader) (EntryFlag, error) {
 
 func DecodeEntryType(r io.Reader) (EntryType, error) {
 	rawType := make([]byte, 1)
-	_, readErr := r.Read(rawType)
+	_, readErr := io.ReadFull(r, rawType)
 	if readErr != nil {
 		return EntryTypeUndef, readErr
 	}
@@ -90,7 +100,7 @@ func DecodeEntryType(r io.Reader) (EntryType, error) {
 
 func DecodeEntryValueAndType(r io.Reader) (value EntryValue, entryType EntryType, err error) {
 	entryTypeRaw := make([]byte, 1)
-	_, readErr := r.Read(entryTypeRaw)
+	_, readErr := io.ReadFull(r, entryTypeRaw)
 	if readErr != nil {
 		return nil, EntryTypeUndef, readErr
 	}
@@ -115,11 +125,37 @@ func DecodeEntryValue(r io.Reader, entryType EntryType) (EntryValue, error) {
 		return DecodeDoubleArray(r)
 	case EntryTypeStringArr:
 		return DecodeStringArray(r)
+	case EntryTypeRPCDef:
+		return DecodeRPC(r)
 	default:
 		return nil, ErrEntryNoSuchType
 	}
 }
 
+// EntryValueType returns the EntryType value is encoded as.
+func EntryValueType(value EntryValue) EntryType {
+	switch value.(type) {
+	case *ValueBoolean:
+		return EntryTypeBoolean
+	case *ValueDouble:
+		return EntryTypeDouble
+	case *ValueString:
+		return EntryTypeString
+	case *ValueRaw:
+		return EntryTypeRawData
+	case *ValueBooleanArray:
+		return EntryTypeBooleanArr
+	case *ValueDoubleArray:
+		return EntryTypeDoubleArr
+	case *ValueStringArray:
+		return EntryTypeStringArr
+	case *ValueRPC:
+		return EntryTypeRPCDef
+	default:
+		return EntryTypeUndef
+	}
+}
+
 type ValueBoolean struct {
 	Value    bool
 	RawValue []byte
@@ -177,13 +213,36 @@ type ValueString struct {
 	RawValue []byte
 }
 
+// readLength reads the n bytes of a string or raw value. The length comes
+// off the wire, so the buffer grows with the bytes that actually arrive
+// rather than being allocated up front; when r knows how much it holds, a
+// length past that fails at once. Either way a short read consumes what is
+// left, as io.ReadFull does.
+func readLength(r io.Reader, n uint32) ([]byte, error) {
+	if n == 0 {
+		return []byte{}, nil
+	}
+	if sized, ok := r.(interface{ Len() int }); ok && int64(n) > int64(sized.Len()) {
+		io.Copy(io.Discard, r)
+		return nil, io.ErrUnexpectedEOF
+	}
+	var data bytes.Buffer
+	copied, copyErr := io.CopyN(&data, r, int64(n))
+	if copied < int64(n) {
+		if copyErr == io.EOF && copied > 0 {
+			copyErr = io.ErrUnexpectedEOF
+		}
+		return nil, copyErr
+	}
+	return data.Bytes(), nil
+}
+
 func DecodeString(r io.Reader) (*ValueString, error) {
 	uleb, ulebData, ulebErr := DecodeAndSaveULEB128(r)
 	if ulebErr != nil {
 		return nil, ulebErr
 	}
-	data := make([]byte, uleb)
-	_, readErr := io.ReadFull(r, data)
+	data, readErr := readLength(r, uleb)
 	if readErr != nil {
 		return nil, readErr
 	}
@@ -268,8 +327,7 @@ func DecodeRaw(r io.Reader) (*ValueRaw, error) {
 	if ulebErr != nil {
 		return nil, ulebErr
 	}
-	data := make([]byte, uleb)
-	_, readErr := io.ReadFull(r, data)
+	data, readErr := readLength(r, uleb)
 	if readErr != nil {
 		return nil, readErr
 	}
@@ -326,20 +384,39 @@ func DecodeBooleanArray(r io.Reader) (*ValueBooleanArray, error) {
 	}, nil
 }
 
-func BuildBooleanArray(values []*ValueBoolean) *ValueBooleanArray {
-	var index uint8
-	if len(values) > 255 {
-		index = 255
-	} else {
-		index = uint8(len(values))
+// BuildBooleanArray returns an array holding values. It fails with
+// ErrArrayOutOfSpace if there are more than MaxArrayLength values.
+func BuildBooleanArray(values []*ValueBoolean) (*ValueBooleanArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
 	}
+	elements := make([]*ValueBoolean, len(values))
+	copy(elements, values)
 	return &ValueBooleanArray{
-		elements: values[:index],
+		elements: elements,
+	}, nil
+}
+
+// BuildBooleanArrayFrom returns an array holding the native values.
+func BuildBooleanArrayFrom(values []bool) (*ValueBooleanArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
+	}
+	elements := make([]*ValueBoolean, len(values))
+	for i, value := range values {
+		elements[i] = BuildBoolean(value)
 	}
+	return &ValueBooleanArray{
+		elements: elements,
+	}, nil
+}
+
+func (array *ValueBooleanArray) Len() int {
+	return len(array.elements)
 }
 
 func (array *ValueBooleanArray) Get(index uint8) (EntryValue, error) {
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return nil, ErrArrayIndexOutOfBounds
 	}
 	return array.elements[index], nil
@@ -350,10 +427,11 @@ func (array *ValueBooleanArray) Update(index uint8, entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return ErrArrayIndexOutOfBounds
 	}
-	return mergo.MergeWithOverwrite(array.elements[index], *boolean)
+	array.elements[index] = boolean
+	return nil
 }
 
 func (array *ValueBooleanArray) Add(entry EntryValue) error {
@@ -361,18 +439,60 @@ func (array *ValueBooleanArray) Add(entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if len(array.elements) > 255 {
+	if len(array.elements) >= MaxArrayLength {
 		return ErrArrayOutOfSpace
 	}
 	array.elements = append(array.elements, boolean)
 	return nil
 }
 
+func (array *ValueBooleanArray) Insert(index uint8, entry EntryValue) error {
+	boolean, ok := entry.(*ValueBoolean)
+	if !ok {
+		return ErrEntryCastInvalid
+	}
+	if int(index) > len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	if len(array.elements) >= MaxArrayLength {
+		return ErrArrayOutOfSpace
+	}
+	array.elements = slices.Insert(array.elements, int(index), boolean)
+	return nil
+}
+
+func (array *ValueBooleanArray) Remove(index uint8) error {
+	if int(index) >= len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	array.elements = slices.Delete(array.elements, int(index), int(index)+1)
+	return nil
+}
+
+// All iterates over the index and element of every value in the array.
+func (array *ValueBooleanArray) All() iter.Seq2[uint8, *ValueBoolean] {
+	return func(yield func(uint8, *ValueBoolean) bool) {
+		for i, element := range array.elements {
+			if !yield(uint8(i), element) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns the elements of the array as a native slice.
+func (array *ValueBooleanArray) Values() []bool {
+	values := make([]bool, len(array.elements))
+	for i, element := range array.elements {
+		values[i] = element.Value
+	}
+	return values
+}
+
 func (array *ValueBooleanArray) GetRaw() []byte {
 	data := []byte{byte(uint8(len(array.elements)))}
-	var i uint8 = 0
-	for ; i < uint8(len(array.elements)); i++ {
-		data = append(data, array.elements[i].RawValue...)
+	for _, element := range array.elements {
+		data = append(data, element.RawValue...)
 	}
 	return data
 }
@@ -401,20 +521,39 @@ func DecodeDoubleArray(r io.Reader) (*ValueDoubleArray, error) {
 	}, nil
 }
 
-func BuildDoubleArray(values []*ValueDouble) *ValueDoubleArray {
-	var index uint8
-	if len(values) > 255 {
-		index = 255
-	} else {
-		index = uint8(len(values))
+// BuildDoubleArray returns an array holding values. It fails with
+// ErrArrayOutOfSpace if there are more than MaxArrayLength values.
+func BuildDoubleArray(values []*ValueDouble) (*ValueDoubleArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
 	}
+	elements := make([]*ValueDouble, len(values))
+	copy(elements, values)
 	return &ValueDoubleArray{
-		elements: values[:index],
+		elements: elements,
+	}, nil
+}
+
+// BuildDoubleArrayFrom returns an array holding the native values.
+func BuildDoubleArrayFrom(values []float64) (*ValueDoubleArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
 	}
+	elements := make([]*ValueDouble, len(values))
+	for i, value := range values {
+		elements[i] = BuildDouble(value)
+	}
+	return &ValueDoubleArray{
+		elements: elements,
+	}, nil
+}
+
+func (array *ValueDoubleArray) Len() int {
+	return len(array.elements)
 }
 
 func (array *ValueDoubleArray) Get(index uint8) (EntryValue, error) {
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return nil, ErrArrayIndexOutOfBounds
 	}
 	return array.elements[index], nil
@@ -425,10 +564,11 @@ func (array *ValueDoubleArray) Update(index uint8, entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return ErrArrayIndexOutOfBounds
 	}
-	return mergo.MergeWithOverwrite(&array.elements[index], double)
+	array.elements[index] = double
+	return nil
 }
 
 func (array *ValueDoubleArray) Add(entry EntryValue) error {
@@ -436,18 +576,60 @@ func (array *ValueDoubleArray) Add(entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if len(array.elements) > 255 {
+	if len(array.elements) >= MaxArrayLength {
 		return ErrArrayOutOfSpace
 	}
 	array.elements = append(array.elements, double)
 	return nil
 }
 
+func (array *ValueDoubleArray) Insert(index uint8, entry EntryValue) error {
+	double, ok := entry.(*ValueDouble)
+	if !ok {
+		return ErrEntryCastInvalid
+	}
+	if int(index) > len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	if len(array.elements) >= MaxArrayLength {
+		return ErrArrayOutOfSpace
+	}
+	array.elements = slices.Insert(array.elements, int(index), double)
+	return nil
+}
+
+func (array *ValueDoubleArray) Remove(index uint8) error {
+	if int(index) >= len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	array.elements = slices.Delete(array.elements, int(index), int(index)+1)
+	return nil
+}
+
+// All iterates over the index and element of every value in the array.
+func (array *ValueDoubleArray) All() iter.Seq2[uint8, *ValueDouble] {
+	return func(yield func(uint8, *ValueDouble) bool) {
+		for i, element := range array.elements {
+			if !yield(uint8(i), element) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns the elements of the array as a native slice.
+func (array *ValueDoubleArray) Values() []float64 {
+	values := make([]float64, len(array.elements))
+	for i, element := range array.elements {
+		values[i] = element.Value
+	}
+	return values
+}
+
 func (array *ValueDoubleArray) GetRaw() []byte {
 	data := []byte{byte(uint8(len(array.elements)))}
-	var i uint8 = 0
-	for ; i < uint8(len(array.elements)); i++ {
-		data = append(data, array.elements[i].RawValue...)
+	for _, element := range array.elements {
+		data = append(data, element.RawValue...)
 	}
 	return data
 }
@@ -476,20 +658,39 @@ func DecodeStringArray(r io.Reader) (*ValueStringArray, error) {
 	}, nil
 }
 
-func BuildStringArray(values []*ValueString) *ValueStringArray {
-	var index uint8
-	if len(values) > 255 {
-		index = 255
-	} else {
-		index = uint8(len(values))
+// BuildStringArray returns an array holding values. It fails with
+// ErrArrayOutOfSpace if there are more than MaxArrayLength values.
+func BuildStringArray(values []*ValueString) (*ValueStringArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
 	}
+	elements := make([]*ValueString, len(values))
+	copy(elements, values)
 	return &ValueStringArray{
-		elements: values[:index],
+		elements: elements,
+	}, nil
+}
+
+// BuildStringArrayFrom returns an array holding the native values.
+func BuildStringArrayFrom(values []string) (*ValueStringArray, error) {
+	if len(values) > MaxArrayLength {
+		return nil, ErrArrayOutOfSpace
+	}
+	elements := make([]*ValueString, len(values))
+	for i, value := range values {
+		elements[i] = BuildString(value)
 	}
+	return &ValueStringArray{
+		elements: elements,
+	}, nil
+}
+
+func (array *ValueStringArray) Len() int {
+	return len(array.elements)
 }
 
 func (array *ValueStringArray) Get(index uint8) (EntryValue, error) {
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return nil, ErrArrayIndexOutOfBounds
 	}
 	return array.elements[index], nil
@@ -500,10 +701,11 @@ func (array *ValueStringArray) Update(index uint8, entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if index >= uint8(len(array.elements)) {
+	if int(index) >= len(array.elements) {
 		return ErrArrayIndexOutOfBounds
 	}
-	return mergo.MergeWithOverwrite(array.elements[index], *string)
+	array.elements[index] = string
+	return nil
 }
 
 func (array *ValueStringArray) Add(entry EntryValue) error {
@@ -511,18 +713,60 @@ func (array *ValueStringArray) Add(entry EntryValue) error {
 	if !ok {
 		return ErrEntryCastInvalid
 	}
-	if len(array.elements) > 255 {
+	if len(array.elements) >= MaxArrayLength {
 		return ErrArrayOutOfSpace
 	}
 	array.elements = append(array.elements, string)
 	return nil
 }
 
+func (array *ValueStringArray) Insert(index uint8, entry EntryValue) error {
+	string, ok := entry.(*ValueString)
+	if !ok {
+		return ErrEntryCastInvalid
+	}
+	if int(index) > len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	if len(array.elements) >= MaxArrayLength {
+		return ErrArrayOutOfSpace
+	}
+	array.elements = slices.Insert(array.elements, int(index), string)
+	return nil
+}
+
+func (array *ValueStringArray) Remove(index uint8) error {
+	if int(index) >= len(array.elements) {
+		return ErrArrayIndexOutOfBounds
+	}
+	array.elements = slices.Delete(array.elements, int(index), int(index)+1)
+	return nil
+}
+
+// All iterates over the index and element of every value in the array.
+func (array *ValueStringArray) All() iter.Seq2[uint8, *ValueString] {
+	return func(yield func(uint8, *ValueString) bool) {
+		for i, element := range array.elements {
+			if !yield(uint8(i), element) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns the elements of the array as a native slice.
+func (array *ValueStringArray) Values() []string {
+	values := make([]string, len(array.elements))
+	for i, element := range array.elements {
+		values[i] = element.Value
+	}
+	return values
+}
+
 func (array *ValueStringArray) GetRaw() []byte {
 	data := []byte{byte(uint8(len(array.elements)))}
-	var i uint8 = 0
-	for ; i < uint8(len(array.elements)); i++ {
-		data = append(data, array.elements[i].RawValue...)
+	for _, element := range array.elements {
+		data = append(data, element.RawValue...)
 	}
 	return data
 }
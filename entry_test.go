@@ -51,7 +51,10 @@ func TestBuildRaw(t *testing.T) {
 }
 
 func TestBuildBooleanArray(t *testing.T) {
-	result := BuildBooleanArray([]*ValueBoolean{BuildBoolean(true)})
+	result, err := BuildBooleanArray([]*ValueBoolean{BuildBoolean(true)})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
 	var expected = &ValueBooleanArray{
 		elements: []*ValueBoolean{BuildBoolean(true)},
 	}
@@ -61,7 +64,10 @@ func TestBuildBooleanArray(t *testing.T) {
 }
 
 func TestBuildDoubleArray(t *testing.T) {
-	result := BuildDoubleArray([]*ValueDouble{BuildDouble(49.04)})
+	result, err := BuildDoubleArray([]*ValueDouble{BuildDouble(49.04)})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
 	var expected = &ValueDoubleArray{
 		elements: []*ValueDouble{BuildDouble(49.04)},
 	}
@@ -71,7 +77,10 @@ func TestBuildDoubleArray(t *testing.T) {
 }
 
 func TestBuildStringArray(t *testing.T) {
-	result := BuildStringArray([]*ValueString{BuildString("str")})
+	result, err := BuildStringArray([]*ValueString{BuildString("str")})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
 	var expected = &ValueStringArray{
 		elements: []*ValueString{BuildString("str")},
 	}
@@ -146,7 +155,7 @@ func TestEntryValueBooleanArrayGetSafe(t *testing.T) {
 		Value:    true,
 		RawValue: []byte{BoolTrue},
 	}
-	array := BuildBooleanArray([]*ValueBoolean{
+	array, _ := BuildBooleanArray([]*ValueBoolean{
 		BuildBoolean(true), BuildBoolean(false),
 		BuildBoolean(false), testEntry,
 	})
@@ -160,7 +169,7 @@ func TestEntryValueBooleanArrayGetSafe(t *testing.T) {
 }
 
 func TestEntryValueBooleanArrayGetFail(t *testing.T) {
-	array := BuildBooleanArray([]*ValueBoolean{
+	array, _ := BuildBooleanArray([]*ValueBoolean{
 		BuildBoolean(true), BuildBoolean(false),
 		BuildBoolean(false), BuildBoolean(false),
 	})
@@ -191,7 +200,7 @@ func TestDecodeEntryValueDoubleArray(t *testing.T) {
 
 func TestEntryValueDoubleArrayGetSafe(t *testing.T) {
 	var testEntry = BuildDouble(0.3)
-	array := BuildDoubleArray([]*ValueDouble{
+	array, _ := BuildDoubleArray([]*ValueDouble{
 		BuildDouble(0.0), BuildDouble(0.1),
 		BuildDouble(0.2), testEntry,
 	})
@@ -205,7 +214,7 @@ func TestEntryValueDoubleArrayGetSafe(t *testing.T) {
 }
 
 func TestEntryValueDoubleArrayGetFail(t *testing.T) {
-	array := BuildDoubleArray([]*ValueDouble{
+	array, _ := BuildDoubleArray([]*ValueDouble{
 		BuildDouble(0.0), BuildDouble(0.1),
 		BuildDouble(0.2), BuildDouble(0.3),
 	})
@@ -236,7 +245,7 @@ func TestDecodeEntryValueStringArray(t *testing.T) {
 
 func TestEntryValueStringArrayGetSafe(t *testing.T) {
 	var testEntry = BuildString("test3")
-	array := BuildStringArray([]*ValueString{
+	array, _ := BuildStringArray([]*ValueString{
 		BuildString("test0"), BuildString("test1"),
 		BuildString("test2"), testEntry,
 	})
@@ -250,7 +259,7 @@ func TestEntryValueStringArrayGetSafe(t *testing.T) {
 }
 
 func TestEntryValueStringArrayGetFail(t *testing.T) {
-	array := BuildStringArray([]*ValueString{
+	array, _ := BuildStringArray([]*ValueString{
 		BuildString("test0"), BuildString("test1"),
 		BuildString("test2"), BuildString("test3"),
 	})
@@ -343,3 +352,111 @@ func TestDecodeEntryTypeStringArray(t *testing.T) {
 		t.Fatalf("Expected %s but got %s", expected, result)
 	}
 }
+
+func TestBuildDoubleArrayTooLarge(t *testing.T) {
+	_, err := BuildDoubleArrayFrom(make([]float64, MaxArrayLength+1))
+	if err != ErrArrayOutOfSpace {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrArrayOutOfSpace, err)
+	}
+}
+
+func TestEntryValueDoubleArrayAddFull(t *testing.T) {
+	array, err := BuildDoubleArrayFrom(make([]float64, MaxArrayLength))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	err = array.Add(BuildDouble(1))
+	if err != ErrArrayOutOfSpace {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrArrayOutOfSpace, err)
+	}
+	if array.Len() != MaxArrayLength {
+		t.Fatalf("Expected length %d but got %d", MaxArrayLength, array.Len())
+	}
+}
+
+func TestEntryValueDoubleArrayUpdate(t *testing.T) {
+	array, _ := BuildDoubleArrayFrom([]float64{0.1, 0.2, 0.3})
+	err := array.Update(1, BuildDouble(0))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	expected := []float64{0.1, 0, 0.3}
+	if !reflect.DeepEqual(array.Values(), expected) {
+		t.Fatalf("Expected %v but got %v", expected, array.Values())
+	}
+}
+
+func TestEntryValueStringArrayInsertRemove(t *testing.T) {
+	array, _ := BuildStringArrayFrom([]string{"a", "c"})
+	if err := array.Insert(1, BuildString("b")); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := array.Insert(3, BuildString("d")); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := array.Remove(0); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	expected := []string{"b", "c", "d"}
+	if !reflect.DeepEqual(array.Values(), expected) {
+		t.Fatalf("Expected %v but got %v", expected, array.Values())
+	}
+	if err := array.Remove(3); err != ErrArrayIndexOutOfBounds {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrArrayIndexOutOfBounds, err)
+	}
+	raw := array.GetRaw()
+	decoded, err := DecodeStringArray(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Values(), expected) {
+		t.Fatalf("Expected %v but got %v", expected, decoded.Values())
+	}
+}
+
+func TestEntryValueBooleanArrayAll(t *testing.T) {
+	array, _ := BuildBooleanArrayFrom([]bool{true, false, true})
+	count := 0
+	for i, element := range array.All() {
+		if i != uint8(count) {
+			t.Fatalf("Expected index %d but got %d", count, i)
+		}
+		if element.Value != (i != 1) {
+			t.Fatalf("Unexpected value %v at index %d", element.Value, i)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 elements but iterated %d", count)
+	}
+}
+
+func TestDecodeEntryValueRPC(t *testing.T) {
+	// The definition of add(a double = 0) -> sum double, as the
+	// specification encodes it, followed by a Keep Alive.
+	messageBytes := []byte{0x17, // Length
+		0x01,                   // Version
+		0x03, 0x61, 0x64, 0x64, // Name ("add")
+		0x01,             // Parameter count
+		0x01, 0x01, 0x61, // Type and name ("a")
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Default (0)
+		0x01,                         // Output count
+		0x01, 0x03, 0x73, 0x75, 0x6D, // Type and name ("sum")
+		0x00, // Keep Alive
+	}
+	r := bytes.NewReader(messageBytes)
+	result, err := DecodeEntryValue(r, EntryTypeRPCDef)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	rpc := result.(*ValueRPC)
+	if rpc.ProcedureName.Value != "add" || len(rpc.Params) != 1 || rpc.Params[0].Name.Value != "a" || len(rpc.Outputs) != 1 || rpc.Outputs[0].Name.Value != "sum" {
+		t.Fatalf("Expected the definition of add but got %+v", rpc)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("Expected only the Keep Alive to be left but got %d bytes", r.Len())
+	}
+	if raw := rpc.GetRaw(); !bytes.Equal(raw, messageBytes[:len(messageBytes)-1]) {
+		t.Fatalf("Expected %x but got %x", messageBytes[:len(messageBytes)-1], raw)
+	}
+}
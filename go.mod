@@ -0,0 +1,11 @@
+module github.com/HowardStark/ntgo
+
+go 1.24
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/imdario/mergo v0.3.16
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,254 @@
+package ntgo
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistorySample is a value an entry took, and when. Value is nil from the
+// time the entry was deleted.
+type HistorySample struct {
+	Time  time.Time
+	Value EntryValue
+}
+
+// HistoryStats summarizes the numeric values of an entry over a window.
+// Mean is weighted by how long each value was held.
+type HistoryStats struct {
+	Min, Max, Mean float64
+}
+
+// EntryHistory keeps the recent values of the entries under the prefixes it
+// tracks, for looking back in time: what a value was two seconds ago, or
+// how it ranged over the last ten. Each entry gets a ring buffer of its
+// latest changes; once it is full the oldest change is dropped. Nothing is
+// kept for entries that are not tracked.
+type EntryHistory struct {
+	nt       *NetworkTables
+	capacity int
+
+	mu       sync.Mutex
+	prefixes map[string]int
+	entries  map[string]*historyRing
+}
+
+// historyRing holds the latest samples of an entry, oldest first from start.
+type historyRing struct {
+	samples []HistorySample
+	start   int
+}
+
+// NewEntryHistory makes a history of the entries of nt that keeps up to
+// capacity changes per entry. It tracks nothing until Track is called.
+func NewEntryHistory(nt *NetworkTables, capacity int) *EntryHistory {
+	return &EntryHistory{nt: nt, capacity: max(capacity, 1), prefixes: map[string]int{}, entries: map[string]*historyRing{}}
+}
+
+// Track starts keeping the history of every entry whose name starts with
+// prefix, beginning with its current value.
+func (history *EntryHistory) Track(prefix string) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if _, tracked := history.prefixes[prefix]; tracked {
+		return
+	}
+	table := history.nt.Table()
+	history.prefixes[prefix] = table.AddListener(prefix, func(event EntryEvent) {
+		switch event.Kind {
+		case EntryEventDeleted:
+			history.add(event.Entry.Name.Value, event.Time, nil)
+		case EntryEventFlagsUpdated:
+		default:
+			history.add(event.Entry.Name.Value, event.Time, event.Entry.Value)
+		}
+	})
+	now := time.Now()
+	for _, entry := range table.Entries(prefix) {
+		if _, exists := history.entries[entry.Name.Value]; !exists {
+			history.record(entry.Name.Value, now, entry.Value)
+		}
+	}
+}
+
+// Untrack stops keeping the history of the entries under prefix, and
+// forgets what was kept for the ones no other tracked prefix covers.
+func (history *EntryHistory) Untrack(prefix string) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	listener, tracked := history.prefixes[prefix]
+	if !tracked {
+		return
+	}
+	history.nt.Table().RemoveListener(listener)
+	delete(history.prefixes, prefix)
+	for name := range history.entries {
+		if strings.HasPrefix(name, prefix) && !history.tracked(name) {
+			delete(history.entries, name)
+		}
+	}
+}
+
+// Close stops tracking every prefix and forgets the history kept.
+func (history *EntryHistory) Close() {
+	history.mu.Lock()
+	prefixes := make([]string, 0, len(history.prefixes))
+	for prefix := range history.prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	history.mu.Unlock()
+	for _, prefix := range prefixes {
+		history.Untrack(prefix)
+	}
+}
+
+// tracked reports whether any tracked prefix covers name. The caller holds
+// history.mu.
+func (history *EntryHistory) tracked(name string) bool {
+	for prefix := range history.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (history *EntryHistory) add(name string, at time.Time, value EntryValue) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.record(name, at, value)
+}
+
+// record appends a sample to the ring of name. The caller holds history.mu.
+func (history *EntryHistory) record(name string, at time.Time, value EntryValue) {
+	ring, exists := history.entries[name]
+	if !exists {
+		ring = &historyRing{samples: make([]HistorySample, 0, history.capacity)}
+		history.entries[name] = ring
+	}
+	// Listeners may run in a different order than the changes were made;
+	// keep the ring in time order all the same.
+	if latest, ok := ring.latest(); ok && at.Before(latest.Time) {
+		at = latest.Time
+	}
+	sample := HistorySample{Time: at, Value: value}
+	if len(ring.samples) < cap(ring.samples) {
+		ring.samples = append(ring.samples, sample)
+		return
+	}
+	ring.samples[ring.start] = sample
+	ring.start = (ring.start + 1) % len(ring.samples)
+}
+
+func (ring *historyRing) at(i int) HistorySample {
+	return ring.samples[(ring.start+i)%len(ring.samples)]
+}
+
+func (ring *historyRing) latest() (HistorySample, bool) {
+	if len(ring.samples) == 0 {
+		return HistorySample{}, false
+	}
+	return ring.at(len(ring.samples) - 1), true
+}
+
+// after returns the index of the first sample later than t.
+func (ring *historyRing) after(t time.Time) int {
+	return sort.Search(len(ring.samples), func(i int) bool { return ring.at(i).Time.After(t) })
+}
+
+// History returns the changes to an entry from since on, oldest first.
+// The value the entry held at since is not included unless it was set at
+// that very moment; ValueAt returns it.
+func (history *EntryHistory) History(key string, since time.Time) []HistorySample {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	ring, exists := history.entries[key]
+	if !exists {
+		return []HistorySample{}
+	}
+	samples := []HistorySample{}
+	for i := sort.Search(len(ring.samples), func(i int) bool { return !ring.at(i).Time.Before(since) }); i < len(ring.samples); i++ {
+		samples = append(samples, ring.at(i))
+	}
+	return samples
+}
+
+// ValueAt returns the value an entry held at t. It reports false if the
+// entry did not exist then, or if t is older than the oldest change kept.
+func (history *EntryHistory) ValueAt(key string, t time.Time) (EntryValue, bool) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	ring, exists := history.entries[key]
+	if !exists {
+		return nil, false
+	}
+	i := ring.after(t)
+	if i == 0 || ring.at(i-1).Value == nil {
+		return nil, false
+	}
+	return ring.at(i - 1).Value, true
+}
+
+// Stats returns the minimum, maximum and mean of an entry from from to to,
+// both included, counting booleans as 1 and 0. Only the time the entry held
+// a number counts. It reports false if it never did within the window.
+func (history *EntryHistory) Stats(key string, from, to time.Time) (HistoryStats, bool) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	ring, exists := history.entries[key]
+	if !exists || to.Before(from) {
+		return HistoryStats{}, false
+	}
+	// Start from the value held at from, if any.
+	first := max(ring.after(from)-1, 0)
+	last := ring.after(to)
+	stats := HistoryStats{}
+	seen := false
+	var weighted float64
+	var held time.Duration
+	for i := first; i < last; i++ {
+		sample := ring.at(i)
+		number, ok := numericValue(sample.Value)
+		if !ok {
+			continue
+		}
+		start, end := sample.Time, to
+		if start.Before(from) {
+			start = from
+		}
+		if i+1 < last {
+			end = ring.at(i + 1).Time
+		}
+		if !seen {
+			stats.Min, stats.Max, seen = number, number, true
+		}
+		stats.Min, stats.Max = min(stats.Min, number), max(stats.Max, number)
+		weighted += number * float64(end.Sub(start))
+		held += end.Sub(start)
+		stats.Mean = number
+	}
+	if !seen {
+		return HistoryStats{}, false
+	}
+	if held > 0 {
+		// Otherwise the window is a single moment, and the mean is the
+		// value at that moment.
+		stats.Mean = weighted / float64(held)
+	}
+	return stats, true
+}
+
+// numericValue returns a double as it is, and a boolean as 1 or 0.
+func numericValue(value EntryValue) (float64, bool) {
+	switch value := value.(type) {
+	case *ValueDouble:
+		return value.Value, true
+	case *ValueBoolean:
+		if value.Value {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package ntgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntryHistoryLive(t *testing.T) {
+	nt := &NetworkTables{}
+	nt.Double("/drive/kP").Set(0.1)
+	nt.Double("/other").Set(1)
+	history := NewEntryHistory(nt, 10)
+	defer history.Close()
+	before := time.Now()
+	history.Track("/drive")
+	nt.Double("/drive/kP").Set(0.2)
+	nt.Double("/other").Set(2)
+
+	samples := history.History("/drive/kP", before)
+	if len(samples) != 2 || samples[0].Value.(*ValueDouble).Value != 0.1 || samples[1].Value.(*ValueDouble).Value != 0.2 {
+		t.Fatalf("Expected the history 0.1, 0.2 but got %v", samples)
+	}
+	value, ok := history.ValueAt("/drive/kP", samples[1].Time.Add(-time.Nanosecond))
+	if !ok || value.(*ValueDouble).Value != 0.1 {
+		t.Fatalf("Expected 0.1 just before the change but got %v", value)
+	}
+	if _, ok := history.ValueAt("/drive/kP", before.Add(-time.Second)); ok {
+		t.Fatal("Expected no value from before tracking started")
+	}
+	if samples := history.History("/other", before); len(samples) != 0 {
+		t.Fatalf("Expected no history of an untracked entry but got %v", samples)
+	}
+
+	nt.Delete("/drive/kP")
+	if _, ok := history.ValueAt("/drive/kP", time.Now()); ok {
+		t.Fatal("Expected no value after the entry was deleted")
+	}
+	history.Untrack("/drive")
+	nt.Double("/drive/kP").Set(0.3)
+	if samples := history.History("/drive/kP", before); len(samples) != 0 {
+		t.Fatalf("Expected the history to be forgotten after Untrack but got %v", samples)
+	}
+}
+
+func TestEntryHistoryRing(t *testing.T) {
+	history := NewEntryHistory(&NetworkTables{}, 3)
+	start := time.Unix(1700000000, 0)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	for i := 0; i < 5; i++ {
+		history.add("/speed", at(i), BuildDouble(float64(i)))
+	}
+	samples := history.History("/speed", start)
+	if len(samples) != 3 {
+		t.Fatalf("Expected the 3 latest samples but got %v", samples)
+	}
+	for i, sample := range samples {
+		if !sample.Time.Equal(at(i+2)) || sample.Value.(*ValueDouble).Value != float64(i+2) {
+			t.Fatalf("Expected %d at %s but got %v", i+2, at(i+2), sample)
+		}
+	}
+	if samples := history.History("/speed", at(3)); len(samples) != 2 {
+		t.Fatalf("Expected 2 samples from the 4th second on but got %v", samples)
+	}
+	if _, ok := history.ValueAt("/speed", at(1)); ok {
+		t.Fatal("Expected no value from before the oldest sample kept")
+	}
+	if value, ok := history.ValueAt("/speed", at(3).Add(500*time.Millisecond)); !ok || value.(*ValueDouble).Value != 3 {
+		t.Fatalf("Expected 3 between the 4th and 5th changes but got %v", value)
+	}
+}
+
+func TestEntryHistoryStats(t *testing.T) {
+	history := NewEntryHistory(&NetworkTables{}, 10)
+	start := time.Unix(1700000000, 0)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	history.add("/speed", at(0), BuildDouble(10))
+	history.add("/speed", at(2), BuildDouble(20))
+	history.add("/speed", at(3), BuildDouble(-5))
+	history.add("/speed", at(4), nil)
+	history.add("/speed", at(6), BuildDouble(40))
+	cases := []struct {
+		from, to time.Time
+		stats    HistoryStats
+	}{
+		// 10 for 1s, 20 for 1s, -5 for 1s, then deleted until 40 is set
+		// at the very end.
+		{at(1), at(6), HistoryStats{Min: -5, Max: 40, Mean: 25.0 / 3}},
+		{at(1), at(5), HistoryStats{Min: -5, Max: 20, Mean: 25.0 / 3}},
+		{at(0), at(2), HistoryStats{Min: 10, Max: 20, Mean: 10}},
+		{at(7), at(7), HistoryStats{Min: 40, Max: 40, Mean: 40}},
+	}
+	for _, c := range cases {
+		stats, ok := history.Stats("/speed", c.from, c.to)
+		if !ok || stats != c.stats {
+			t.Fatalf("Expected %+v from %s to %s but got %+v", c.stats, c.from, c.to, stats)
+		}
+	}
+	if _, ok := history.Stats("/speed", at(4), at(5)); ok {
+		t.Fatal("Expected no stats while the entry was deleted")
+	}
+	history.add("/enabled", at(0), BuildBoolean(true))
+	history.add("/enabled", at(1), BuildBoolean(false))
+	if stats, ok := history.Stats("/enabled", at(0), at(4)); !ok || stats != (HistoryStats{Min: 0, Max: 1, Mean: 0.25}) {
+		t.Fatalf("Expected booleans to count as 1 and 0 but got %+v", stats)
+	}
+}
@@ -0,0 +1,534 @@
+package ntgo
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrJSONValueMissing = errors.New("json: entry has no value")
+)
+
+var entryTypeNames = map[EntryType]string{
+	EntryTypeBoolean:    "boolean",
+	EntryTypeDouble:     "double",
+	EntryTypeString:     "string",
+	EntryTypeRawData:    "raw",
+	EntryTypeBooleanArr: "boolean[]",
+	EntryTypeDoubleArr:  "double[]",
+	EntryTypeStringArr:  "string[]",
+	EntryTypeRPCDef:     "rpc",
+}
+
+var entryFlagNames = map[EntryFlag]string{
+	EntryFlagTemporary:  "temporary",
+	EntryFlagPersistent: "persistent",
+}
+
+var messageTypeNames = map[MessageType]string{
+	MessageTypeKeepAlive:                "Keep Alive",
+	MessageTypeClientHello:              "Client Hello",
+	MessageTypeProtocVersionUnsupported: "Protocol Version Unsupported",
+	MessageTypeServerHelloComplete:      "Server Hello Complete",
+	MessageTypeServerHello:              "Server Hello",
+	MessageTypeClientHelloComplete:      "Client Hello Complete",
+	MessageTypeEntryAssignment:          "Entry Assignment",
+	MessageTypeEntryUpdate:              "Entry Update",
+	MessageTypeEntryFlagsUpdate:         "Entry Flags Update",
+	MessageTypeEntryDelete:              "Entry Delete",
+	MessageTypeClearAll:                 "Clear All Entries",
+	MessageTypeRPCExecute:               "Execute RPC",
+	MessageTypeRPCResponse:              "RPC Response",
+}
+
+func (entryType EntryType) String() string {
+	name, ok := entryTypeNames[entryType]
+	if !ok {
+		return fmt.Sprintf("unknown(0x%02x)", byte(entryType))
+	}
+	return name
+}
+
+// MarshalText gives the type its name, or a hex byte such as 0x30 for types
+// the specification does not define, so that a listing holding such an
+// entry can still be written out.
+func (entryType EntryType) MarshalText() ([]byte, error) {
+	if _, ok := entryTypeNames[entryType]; !ok {
+		return []byte(fmt.Sprintf("0x%02x", byte(entryType))), nil
+	}
+	return []byte(entryType.String()), nil
+}
+
+func (entryType *EntryType) UnmarshalText(text []byte) error {
+	if hex, ok := strings.CutPrefix(string(text), "0x"); ok {
+		value, parseErr := strconv.ParseUint(hex, 16, 8)
+		if parseErr != nil {
+			return ErrEntryNoSuchType
+		}
+		*entryType = EntryType(value)
+		return nil
+	}
+	parsed, parseErr := ParseEntryType(string(text))
+	if parseErr != nil {
+		return parseErr
+	}
+	*entryType = parsed
+	return nil
+}
+
+// ParseEntryType returns the EntryType with the given name, as returned by
+// EntryType.String.
+func ParseEntryType(name string) (EntryType, error) {
+	for entryType, typeName := range entryTypeNames {
+		if typeName == name {
+			return entryType, nil
+		}
+	}
+	return EntryTypeUndef, ErrEntryNoSuchType
+}
+
+// String returns the name the specification gives the message type.
+func (messageType MessageType) String() string {
+	name, ok := messageTypeNames[messageType]
+	if !ok {
+		return fmt.Sprintf("0x%02x", byte(messageType))
+	}
+	return name
+}
+
+func (flag EntryFlag) String() string {
+	name, ok := entryFlagNames[flag]
+	if !ok {
+		return fmt.Sprintf("0x%02x", byte(flag))
+	}
+	return name
+}
+
+// MarshalText gives the flag its name, or a hex byte such as 0x03 for
+// combinations the specification does not name, so that any entry a peer
+// sends can be written out.
+func (flag EntryFlag) MarshalText() ([]byte, error) {
+	return []byte(flag.String()), nil
+}
+
+func (flag *EntryFlag) UnmarshalText(text []byte) error {
+	for entryFlag, name := range entryFlagNames {
+		if name == string(text) {
+			*flag = entryFlag
+			return nil
+		}
+	}
+	if hex, ok := strings.CutPrefix(string(text), "0x"); ok {
+		value, parseErr := strconv.ParseUint(hex, 16, 8)
+		if parseErr == nil {
+			*flag = EntryFlag(value)
+			return nil
+		}
+	}
+	return ErrEntryFlagNoSuchType
+}
+
+// entryJSON is the canonical JSON form of an Entry.
+type entryJSON struct {
+	Name     string          `json:"name"`
+	Type     EntryType       `json:"type"`
+	ID       uint16          `json:"id"`
+	Sequence uint16          `json:"sequence"`
+	Flags    EntryFlag       `json:"flags"`
+	Value    json.RawMessage `json:"value"`
+}
+
+func (entry Entry) String() string {
+	name := ""
+	if entry.Name != nil {
+		name = entry.Name.Value
+	}
+	value := "<nil>"
+	if entry.Value != nil {
+		value = fmt.Sprint(entry.Value)
+	}
+	return fmt.Sprintf("%s (%s, id %d, seq %d, %s) = %s", name, entry.Type,
+		binary.BigEndian.Uint16(entry.ID[:]), binary.BigEndian.Uint16(entry.Sequence[:]), entry.Flags, value)
+}
+
+func (entry Entry) MarshalJSON() ([]byte, error) {
+	encoded := entryJSON{
+		Type:     entry.Type,
+		ID:       binary.BigEndian.Uint16(entry.ID[:]),
+		Sequence: binary.BigEndian.Uint16(entry.Sequence[:]),
+		Flags:    entry.Flags,
+		Value:    json.RawMessage("null"),
+	}
+	if entry.Name != nil {
+		encoded.Name = entry.Name.Value
+	}
+	if entry.Value != nil {
+		value, valueErr := json.Marshal(entry.Value)
+		if valueErr != nil {
+			return nil, valueErr
+		}
+		encoded.Value = value
+	}
+	return json.Marshal(encoded)
+}
+
+func (entry *Entry) UnmarshalJSON(data []byte) error {
+	decoded := entryJSON{}
+	if decodeErr := json.Unmarshal(data, &decoded); decodeErr != nil {
+		return decodeErr
+	}
+	value, valueErr := ParseEntryValueJSON(decoded.Type, decoded.Value)
+	if valueErr != nil {
+		return valueErr
+	}
+	*entry = Entry{
+		Name:  BuildString(decoded.Name),
+		Type:  decoded.Type,
+		Flags: decoded.Flags,
+		Value: value,
+	}
+	binary.BigEndian.PutUint16(entry.ID[:], decoded.ID)
+	binary.BigEndian.PutUint16(entry.Sequence[:], decoded.Sequence)
+	return nil
+}
+
+// ParseEntryJSON parses the canonical JSON form of an entry.
+func ParseEntryJSON(data []byte) (*Entry, error) {
+	entry := &Entry{}
+	if parseErr := json.Unmarshal(data, entry); parseErr != nil {
+		return nil, parseErr
+	}
+	return entry, nil
+}
+
+// ParseEntryValueJSON parses the JSON form of a value of the given type.
+func ParseEntryValueJSON(entryType EntryType, data json.RawMessage) (EntryValue, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, ErrJSONValueMissing
+	}
+	var value interface {
+		EntryValue
+		json.Unmarshaler
+	}
+	switch entryType {
+	case EntryTypeBoolean:
+		value = &ValueBoolean{}
+	case EntryTypeDouble:
+		value = &ValueDouble{}
+	case EntryTypeString:
+		value = &ValueString{}
+	case EntryTypeRawData:
+		value = &ValueRaw{}
+	case EntryTypeBooleanArr:
+		value = &ValueBooleanArray{}
+	case EntryTypeDoubleArr:
+		value = &ValueDoubleArray{}
+	case EntryTypeStringArr:
+		value = &ValueStringArray{}
+	case EntryTypeRPCDef:
+		value = &ValueRPC{}
+	default:
+		return nil, ErrEntryNoSuchType
+	}
+	if parseErr := value.UnmarshalJSON(data); parseErr != nil {
+		return nil, parseErr
+	}
+	return value, nil
+}
+
+func (entry *ValueBoolean) String() string {
+	return strconv.FormatBool(entry.Value)
+}
+
+func (entry *ValueBoolean) MarshalJSON() ([]byte, error) {
+	return json.Marshal(entry.Value)
+}
+
+func (entry *ValueBoolean) UnmarshalJSON(data []byte) error {
+	var value bool
+	if parseErr := json.Unmarshal(data, &value); parseErr != nil {
+		return parseErr
+	}
+	*entry = *BuildBoolean(value)
+	return nil
+}
+
+func (entry *ValueDouble) String() string {
+	return strconv.FormatFloat(entry.Value, 'g', -1, 64)
+}
+
+// MarshalJSON encodes the double as a JSON number, or as one of the strings
+// "NaN", "+Inf" and "-Inf" since JSON has no representation for those.
+func (entry *ValueDouble) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDouble(entry.Value))
+}
+
+func (entry *ValueDouble) UnmarshalJSON(data []byte) error {
+	value, parseErr := parseJSONDouble(data)
+	if parseErr != nil {
+		return parseErr
+	}
+	*entry = *BuildDouble(value)
+	return nil
+}
+
+func (entry *ValueString) String() string {
+	return entry.Value
+}
+
+func (entry *ValueString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(entry.Value)
+}
+
+func (entry *ValueString) UnmarshalJSON(data []byte) error {
+	var value string
+	if parseErr := json.Unmarshal(data, &value); parseErr != nil {
+		return parseErr
+	}
+	*entry = *BuildString(value)
+	return nil
+}
+
+func (entry *ValueRaw) String() string {
+	return fmt.Sprintf("%x", entry.Value)
+}
+
+// MarshalJSON encodes the raw data as a base64 JSON string.
+func (entry *ValueRaw) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.StdEncoding.EncodeToString(entry.Value))
+}
+
+func (entry *ValueRaw) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if parseErr := json.Unmarshal(data, &encoded); parseErr != nil {
+		return parseErr
+	}
+	value, decodeErr := base64.StdEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	*entry = *BuildRaw(value)
+	return nil
+}
+
+func (array *ValueBooleanArray) String() string {
+	return fmt.Sprint(array.Values())
+}
+
+func (array *ValueBooleanArray) MarshalJSON() ([]byte, error) {
+	return json.Marshal(array.Values())
+}
+
+func (array *ValueBooleanArray) UnmarshalJSON(data []byte) error {
+	values := []bool{}
+	if parseErr := json.Unmarshal(data, &values); parseErr != nil {
+		return parseErr
+	}
+	built, buildErr := BuildBooleanArrayFrom(values)
+	if buildErr != nil {
+		return buildErr
+	}
+	*array = *built
+	return nil
+}
+
+func (array *ValueDoubleArray) String() string {
+	return fmt.Sprint(array.Values())
+}
+
+func (array *ValueDoubleArray) MarshalJSON() ([]byte, error) {
+	values := make([]any, len(array.elements))
+	for i, element := range array.elements {
+		values[i] = jsonDouble(element.Value)
+	}
+	return json.Marshal(values)
+}
+
+func (array *ValueDoubleArray) UnmarshalJSON(data []byte) error {
+	raws := []json.RawMessage{}
+	if parseErr := json.Unmarshal(data, &raws); parseErr != nil {
+		return parseErr
+	}
+	values := make([]float64, len(raws))
+	for i, raw := range raws {
+		value, parseErr := parseJSONDouble(raw)
+		if parseErr != nil {
+			return parseErr
+		}
+		values[i] = value
+	}
+	built, buildErr := BuildDoubleArrayFrom(values)
+	if buildErr != nil {
+		return buildErr
+	}
+	*array = *built
+	return nil
+}
+
+func (array *ValueStringArray) String() string {
+	quoted := make([]string, len(array.elements))
+	for i, element := range array.elements {
+		quoted[i] = strconv.Quote(element.Value)
+	}
+	return "[" + strings.Join(quoted, " ") + "]"
+}
+
+func (array *ValueStringArray) MarshalJSON() ([]byte, error) {
+	return json.Marshal(array.Values())
+}
+
+func (array *ValueStringArray) UnmarshalJSON(data []byte) error {
+	values := []string{}
+	if parseErr := json.Unmarshal(data, &values); parseErr != nil {
+		return parseErr
+	}
+	built, buildErr := BuildStringArrayFrom(values)
+	if buildErr != nil {
+		return buildErr
+	}
+	*array = *built
+	return nil
+}
+
+// rpcJSON is the structured JSON form of an RPC definition.
+type rpcJSON struct {
+	Version byte            `json:"version"`
+	Name    string          `json:"name"`
+	Params  []rpcParamJSON  `json:"params"`
+	Outputs []rpcOutputJSON `json:"outputs"`
+}
+
+type rpcParamJSON struct {
+	Type    EntryType       `json:"type"`
+	Name    string          `json:"name"`
+	Default json.RawMessage `json:"default"`
+}
+
+type rpcOutputJSON struct {
+	Type EntryType `json:"type"`
+	Name string    `json:"name"`
+}
+
+func (rpc *ValueRPC) String() string {
+	params := make([]string, len(rpc.Params))
+	for i, param := range rpc.Params {
+		params[i] = fmt.Sprintf("%s %s = %v", param.Name, param.Type, param.DefaultVal)
+	}
+	outputs := make([]string, len(rpc.Outputs))
+	for i, output := range rpc.Outputs {
+		outputs[i] = fmt.Sprintf("%s %s", output.Name, output.Type)
+	}
+	return fmt.Sprintf("%s(%s) (%s)", rpc.ProcedureName, strings.Join(params, ", "), strings.Join(outputs, ", "))
+}
+
+func (rpc *ValueRPC) MarshalJSON() ([]byte, error) {
+	encoded := rpcJSON{
+		Version: rpc.DefVersion,
+		Params:  make([]rpcParamJSON, len(rpc.Params)),
+		Outputs: make([]rpcOutputJSON, len(rpc.Outputs)),
+	}
+	encoded.Name = stringOrEmpty(rpc.ProcedureName)
+	for i, param := range rpc.Params {
+		defaultVal, defaultErr := json.Marshal(param.DefaultVal)
+		if defaultErr != nil {
+			return nil, defaultErr
+		}
+		encoded.Params[i] = rpcParamJSON{
+			Type:    param.Type,
+			Name:    stringOrEmpty(param.Name),
+			Default: defaultVal,
+		}
+	}
+	for i, output := range rpc.Outputs {
+		encoded.Outputs[i] = rpcOutputJSON{
+			Type: output.Type,
+			Name: stringOrEmpty(output.Name),
+		}
+	}
+	return json.Marshal(encoded)
+}
+
+// stringOrEmpty returns the value of name, or "" for a name never built.
+func stringOrEmpty(name *ValueString) string {
+	if name == nil {
+		return ""
+	}
+	return name.Value
+}
+
+func (rpc *ValueRPC) UnmarshalJSON(data []byte) error {
+	decoded := rpcJSON{}
+	if parseErr := json.Unmarshal(data, &decoded); parseErr != nil {
+		return parseErr
+	}
+	if len(decoded.Params) > math.MaxUint8 || len(decoded.Outputs) > math.MaxUint8 {
+		return ErrArrayOutOfSpace
+	}
+	parsed := ValueRPC{
+		DefVersion:    decoded.Version,
+		ProcedureName: BuildString(decoded.Name),
+		ParamSize:     uint8(len(decoded.Params)),
+		Params:        make([]RPCParam, len(decoded.Params)),
+		OutputSize:    uint8(len(decoded.Outputs)),
+		Outputs:       make([]RPCOutput, len(decoded.Outputs)),
+	}
+	for i, param := range decoded.Params {
+		defaultVal, defaultErr := ParseEntryValueJSON(param.Type, param.Default)
+		if defaultErr != nil {
+			return defaultErr
+		}
+		parsed.Params[i] = RPCParam{
+			Type:       param.Type,
+			Name:       BuildString(param.Name),
+			DefaultVal: defaultVal,
+		}
+	}
+	for i, output := range decoded.Outputs {
+		parsed.Outputs[i] = RPCOutput{
+			Type: output.Type,
+			Name: BuildString(output.Name),
+		}
+	}
+	*rpc = parsed
+	return nil
+}
+
+func jsonDouble(value float64) any {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return value
+	}
+}
+
+func parseJSONDouble(data []byte) (float64, error) {
+	var value float64
+	if json.Unmarshal(data, &value) == nil {
+		return value, nil
+	}
+	var special string
+	if parseErr := json.Unmarshal(data, &special); parseErr != nil {
+		return 0, parseErr
+	}
+	switch special {
+	case "NaN":
+		return math.NaN(), nil
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	default:
+		return 0, ErrEntryDataInvalid
+	}
+}
@@ -0,0 +1,158 @@
+package ntgo
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEntryMarshalJSON(t *testing.T) {
+	entry := Entry{
+		Name:     BuildString("/drive/kP"),
+		Type:     EntryTypeDouble,
+		ID:       [2]byte{0x00, 0x07},
+		Sequence: [2]byte{0x01, 0x00},
+		Flags:    EntryFlagPersistent,
+		Value:    BuildDouble(0.5),
+	}
+	result, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	expected := `{"name":"/drive/kP","type":"double","id":7,"sequence":256,"flags":"persistent","value":0.5}`
+	if string(result) != expected {
+		t.Fatalf("Expected %s but got %s", expected, result)
+	}
+}
+
+func TestEntryJSONRoundTrip(t *testing.T) {
+	array, _ := BuildDoubleArrayFrom([]float64{1.5, math.Inf(-1)})
+	strings, _ := BuildStringArrayFrom([]string{"a", "b"})
+	values := []Entry{
+		{Name: BuildString("b"), Type: EntryTypeBoolean, Value: BuildBoolean(true)},
+		{Name: BuildString("s"), Type: EntryTypeString, Value: BuildString("hello")},
+		{Name: BuildString("r"), Type: EntryTypeRawData, Value: BuildRaw([]byte{0x50, 0x21})},
+		{Name: BuildString("d"), Type: EntryTypeDoubleArr, Value: array},
+		{Name: BuildString("sa"), Type: EntryTypeStringArr, Value: strings},
+		// Flags the specification does not name still round-trip.
+		{Name: BuildString("f"), Type: EntryTypeBoolean, Flags: EntryFlag(0x03), Value: BuildBoolean(false)},
+	}
+	for _, entry := range values {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		parsed, err := ParseEntryJSON(data)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if !reflect.DeepEqual(parsed, &entry) {
+			t.Fatalf("Expected %s but got %s", entry, parsed)
+		}
+	}
+}
+
+func TestEntryTypeText(t *testing.T) {
+	entry := Entry{Name: BuildString("/future"), Type: EntryType(0x30), Value: BuildRaw([]byte{0x01})}
+	data, err := json.Marshal([]Entry{entry})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !strings.Contains(string(data), `"type":"0x30"`) {
+		t.Fatalf("Expected the type to be written as 0x30 but got %s", data)
+	}
+	var entryType EntryType
+	if err := entryType.UnmarshalText([]byte("0x30")); err != nil || entryType != EntryType(0x30) {
+		t.Fatalf("Expected 0x30 to read back but got %s (%v)", entryType, err)
+	}
+	if err := entryType.UnmarshalText([]byte("0x130")); err != ErrEntryNoSuchType {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrEntryNoSuchType, err)
+	}
+}
+
+func TestValueRPCMarshalJSONNoNames(t *testing.T) {
+	rpc := &ValueRPC{
+		ParamSize:  1,
+		Params:     []RPCParam{{Type: EntryTypeDouble, DefaultVal: BuildDouble(0)}},
+		OutputSize: 1,
+		Outputs:    []RPCOutput{{Type: EntryTypeDouble}},
+	}
+	data, err := json.Marshal(rpc)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if expected := `{"version":0,"name":"","params":[{"type":"double","name":"","default":0}],"outputs":[{"type":"double","name":""}]}`; string(data) != expected {
+		t.Fatalf("Expected %s but got %s", expected, data)
+	}
+}
+
+func TestEntryFlagText(t *testing.T) {
+	text, err := EntryFlag(0x82).MarshalText()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if string(text) != "0x82" {
+		t.Fatalf("Expected 0x82 but got %s", text)
+	}
+	var flag EntryFlag
+	for _, invalid := range []string{"sticky", "0x", "0x100"} {
+		if err := flag.UnmarshalText([]byte(invalid)); err != ErrEntryFlagNoSuchType {
+			t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrEntryFlagNoSuchType, err)
+		}
+	}
+}
+
+func TestValueRawMarshalJSON(t *testing.T) {
+	result, err := json.Marshal(BuildRaw([]byte{0x50, 0x21}))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if string(result) != `"UCE="` {
+		t.Fatalf("Expected base64 string but got %s", result)
+	}
+}
+
+func TestValueRPCJSONRoundTrip(t *testing.T) {
+	rpc := &ValueRPC{
+		DefVersion:    RPCDefVersion,
+		ProcedureName: BuildString("drive"),
+		ParamSize:     1,
+		Params: []RPCParam{
+			{Type: EntryTypeDouble, Name: BuildString("speed"), DefaultVal: BuildDouble(0.5)},
+		},
+		OutputSize: 1,
+		Outputs: []RPCOutput{
+			{Type: EntryTypeBoolean, Name: BuildString("ok")},
+		},
+	}
+	data, err := json.Marshal(rpc)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	expected := `{"version":1,"name":"drive","params":[{"type":"double","name":"speed","default":0.5}],"outputs":[{"type":"boolean","name":"ok"}]}`
+	if string(data) != expected {
+		t.Fatalf("Expected %s but got %s", expected, data)
+	}
+	parsed, err := ParseEntryValueJSON(EntryTypeRPCDef, data)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !reflect.DeepEqual(parsed, rpc) {
+		t.Fatalf("Expected %s but got %s", rpc, parsed)
+	}
+}
+
+func TestEntryString(t *testing.T) {
+	entry := Entry{
+		Name:  BuildString("/auto/mode"),
+		Type:  EntryTypeString,
+		ID:    [2]byte{0x00, 0x02},
+		Value: BuildString("left"),
+	}
+	expected := "/auto/mode (string, id 2, seq 0, temporary) = left"
+	if entry.String() != expected {
+		t.Fatalf("Expected %q but got %q", expected, entry.String())
+	}
+}
@@ -39,6 +39,12 @@ var (
 	DangerousMagic = [4]byte{0xD0, 0x6C, 0xB2, 0x7A}
 )
 
+var (
+	// ProtocolRevision3 is the NetworkTables 3.0 revision, the one ntgo
+	// speaks by default. Revision 2.0 is in nt2.go, and 4.0 in package nt4.
+	ProtocolRevision3 = ProtocolRevision{0x03, 0x00}
+)
+
 type ProtocolRevision [2]byte
 
 type Message struct {
@@ -46,6 +52,50 @@ type Message struct {
 	Data MessageData
 }
 
+// BuildMessage wraps data in a Message of the matching type.
+func BuildMessage(data MessageData) *Message {
+	var messageType MessageType
+	switch data.(type) {
+	case *MessageDataKeepAlive:
+		messageType = MessageTypeKeepAlive
+	case *MessageDataClientHello:
+		messageType = MessageTypeClientHello
+	case *MessageDataProtocVersionUnsupported:
+		messageType = MessageTypeProtocVersionUnsupported
+	case *MessageDataServerHelloComplete:
+		messageType = MessageTypeServerHelloComplete
+	case *MessageDataServerHello:
+		messageType = MessageTypeServerHello
+	case *MessageDataClientHelloComplete:
+		messageType = MessageTypeClientHelloComplete
+	case *MessageDataEntryAssignment:
+		messageType = MessageTypeEntryAssignment
+	case *MessageDataEntryUpdate:
+		messageType = MessageTypeEntryUpdate
+	case *MessageDataEntryFlagsUpdate:
+		messageType = MessageTypeEntryFlagsUpdate
+	case *MessageDataEntryDelete:
+		messageType = MessageTypeEntryDelete
+	case *MessageDataClearAll:
+		messageType = MessageTypeClearAll
+	case *MessageDataRPCExecute:
+		messageType = MessageTypeRPCExecute
+	case *MessageDataRPCResponse:
+		messageType = MessageTypeRPCResponse
+	default:
+		messageType = MessageTypeUndef
+	}
+	return &Message{
+		Type: messageType,
+		Data: data,
+	}
+}
+
+// GetRaw returns the message as it is sent on the wire.
+func (message *Message) GetRaw() []byte {
+	return append([]byte{byte(message.Type)}, message.Data.GetRaw()...)
+}
+
 func DecodeMessage(r io.Reader) (*Message, error) {
 	messageType, typeErr := DecodeMessageType(r)
 	if typeErr != nil {
@@ -75,8 +125,14 @@ func DecodeMessage(r io.Reader) (*Message, error) {
 		messageData, dataErr = DecodeDataEntryUpdate(r)
 	case MessageTypeEntryFlagsUpdate:
 		messageData, dataErr = DecodeDataEntryFlagsUpdate(r)
+	case MessageTypeEntryDelete:
+		messageData, dataErr = DecodeDataEntryDelete(r)
 	case MessageTypeClearAll:
 		messageData, dataErr = DecodeDataClearAll(r)
+	case MessageTypeRPCExecute:
+		messageData, dataErr = DecodeDataRPCExecute(r)
+	case MessageTypeRPCResponse:
+		messageData, dataErr = DecodeDataRPCReseponse(r)
 	default:
 		dataErr = ErrMessageNoSuchType
 	}
@@ -88,7 +144,7 @@ type MessageFlag byte
 
 func DecodeMessageFlag(r io.Reader) (MessageFlag, error) {
 	flagRaw := make([]byte, 1)
-	_, flagErr := r.Read(flagRaw)
+	_, flagErr := io.ReadFull(r, flagRaw)
 	if flagErr != nil {
 		return FlagMessageClientReserved, flagErr
 	}
@@ -106,14 +162,18 @@ type MessageType byte
 
 func DecodeMessageType(r io.Reader) (MessageType, error) {
 	typeRaw := make([]byte, 1)
-	_, typeErr := r.Read(typeRaw)
+	_, typeErr := io.ReadFull(r, typeRaw)
 	if typeErr != nil {
 		return EntryTypeUndef, typeErr
 	}
 	return MessageType(typeRaw[0]), nil
 }
 
-type MessageData interface{}
+// MessageData is the payload of a Message. GetRaw returns the payload as it
+// is sent on the wire, without the leading message type.
+type MessageData interface {
+	GetRaw() []byte
+}
 
 type MessageDataKeepAlive struct{}
 
@@ -124,7 +184,7 @@ type MessageDataClientHello struct {
 
 func DecodeDataClientHello(r io.Reader) (*MessageDataClientHello, error) {
 	protocRaw := [2]byte{}
-	_, protocErr := r.Read(protocRaw[:])
+	_, protocErr := io.ReadFull(r, protocRaw[:])
 	if protocErr != nil {
 		return nil, protocErr
 	}
@@ -144,7 +204,7 @@ type MessageDataProtocVersionUnsupported struct {
 
 func DecodeDataProtocVersionUnsupported(r io.Reader) (*MessageDataProtocVersionUnsupported, error) {
 	protocRaw := [2]byte{}
-	_, protocErr := r.Read(protocRaw[:])
+	_, protocErr := io.ReadFull(r, protocRaw[:])
 	if protocErr != nil {
 		return nil, protocErr
 	}
@@ -191,14 +251,14 @@ func DecodeDataEntryAssignment(r io.Reader) (*MessageDataEntryAssignment, error)
 		return nil, entryErr
 	}
 	idRaw := [2]byte{}
-	_, idErr := r.Read(idRaw[:])
+	_, idErr := io.ReadFull(r, idRaw[:])
 	if idErr != nil {
 		return nil, idErr
 	}
 	seqRaw := [2]byte{}
-	_, seqErr := r.Read(seqRaw[:])
+	_, seqErr := io.ReadFull(r, seqRaw[:])
 	if seqErr != nil {
-		return nil, idErr
+		return nil, seqErr
 	}
 	flag, flagErr := DecodeEntryFlag(r)
 	if flagErr != nil {
@@ -226,14 +286,14 @@ type MessageDataEntryUpdate struct {
 
 func DecodeDataEntryUpdate(r io.Reader) (*MessageDataEntryUpdate, error) {
 	idRaw := [2]byte{}
-	_, idErr := r.Read(idRaw[:])
+	_, idErr := io.ReadFull(r, idRaw[:])
 	if idErr != nil {
 		return nil, idErr
 	}
 	seqRaw := [2]byte{}
-	_, seqErr := r.Read(seqRaw[:])
+	_, seqErr := io.ReadFull(r, seqRaw[:])
 	if seqErr != nil {
-		return nil, idErr
+		return nil, seqErr
 	}
 	value, entryType, valueErr := DecodeEntryValueAndType(r)
 	if valueErr != nil {
@@ -255,7 +315,7 @@ type MessageDataEntryFlagsUpdate struct {
 
 func DecodeDataEntryFlagsUpdate(r io.Reader) (*MessageDataEntryFlagsUpdate, error) {
 	idRaw := [2]byte{}
-	_, idErr := r.Read(idRaw[:])
+	_, idErr := io.ReadFull(r, idRaw[:])
 	if idErr != nil {
 		return nil, idErr
 	}
@@ -277,7 +337,7 @@ type MessageDataEntryDelete struct {
 
 func DecodeDataEntryDelete(r io.Reader) (*MessageDataEntryDelete, error) {
 	idRaw := [2]byte{}
-	_, idErr := r.Read(idRaw[:])
+	_, idErr := io.ReadFull(r, idRaw[:])
 	if idErr != nil {
 		return nil, idErr
 	}
@@ -294,7 +354,7 @@ type MessageDataClearAll struct {
 
 func DecodeDataClearAll(r io.Reader) (*MessageDataClearAll, error) {
 	magicRaw := [4]byte{}
-	_, magicErr := r.Read(magicRaw[:])
+	_, magicErr := io.ReadFull(r, magicRaw[:])
 	if magicErr != nil {
 		return nil, magicErr
 	}
@@ -312,12 +372,12 @@ type MessageDataRPCExecute struct {
 
 func DecodeDataRPCExecute(r io.Reader) (*MessageDataRPCExecute, error) {
 	entryIDRaw := [2]byte{}
-	_, entryIDErr := r.Read(entryIDRaw[:])
+	_, entryIDErr := io.ReadFull(r, entryIDRaw[:])
 	if entryIDErr != nil {
 		return nil, entryIDErr
 	}
 	uniqueIDRaw := [2]byte{}
-	_, uniqueIDErr := r.Read(uniqueIDRaw[:])
+	_, uniqueIDErr := io.ReadFull(r, uniqueIDRaw[:])
 	if uniqueIDErr != nil {
 		return nil, uniqueIDErr
 	}
@@ -325,8 +385,7 @@ func DecodeDataRPCExecute(r io.Reader) (*MessageDataRPCExecute, error) {
 	if ulebErr != nil {
 		return nil, ulebErr
 	}
-	params := make([]byte, paramsSize)
-	_, paramsErr := r.Read(params)
+	params, paramsErr := readLength(r, paramsSize)
 	if paramsErr != nil {
 		return nil, paramsErr
 	}
@@ -347,12 +406,12 @@ type MessageDataRPCResponse struct {
 
 func DecodeDataRPCReseponse(r io.Reader) (*MessageDataRPCResponse, error) {
 	entryIDRaw := [2]byte{}
-	_, entryIDErr := r.Read(entryIDRaw[:])
+	_, entryIDErr := io.ReadFull(r, entryIDRaw[:])
 	if entryIDErr != nil {
 		return nil, entryIDErr
 	}
 	uniqueIDRaw := [2]byte{}
-	_, uniqueIDErr := r.Read(uniqueIDRaw[:])
+	_, uniqueIDErr := io.ReadFull(r, uniqueIDRaw[:])
 	if uniqueIDErr != nil {
 		return nil, uniqueIDErr
 	}
@@ -360,8 +419,7 @@ func DecodeDataRPCReseponse(r io.Reader) (*MessageDataRPCResponse, error) {
 	if ulebErr != nil {
 		return nil, ulebErr
 	}
-	results := make([]byte, resultsSize)
-	_, resultsErr := r.Read(results)
+	results, resultsErr := readLength(r, resultsSize)
 	if resultsErr != nil {
 		return nil, resultsErr
 	}
@@ -372,3 +430,67 @@ func DecodeDataRPCReseponse(r io.Reader) (*MessageDataRPCResponse, error) {
 		Results:      results,
 	}, nil
 }
+
+func (data *MessageDataKeepAlive) GetRaw() []byte {
+	return []byte{}
+}
+
+func (data *MessageDataClientHello) GetRaw() []byte {
+	return append(data.ProtocVersion[:], data.Identity.GetRaw()...)
+}
+
+func (data *MessageDataProtocVersionUnsupported) GetRaw() []byte {
+	return data.SupportedProtoc[:]
+}
+
+func (data *MessageDataServerHelloComplete) GetRaw() []byte {
+	return []byte{}
+}
+
+func (data *MessageDataServerHello) GetRaw() []byte {
+	return append([]byte{byte(data.Flags)}, data.Identity.GetRaw()...)
+}
+
+func (data *MessageDataClientHelloComplete) GetRaw() []byte {
+	return []byte{}
+}
+
+func (data *MessageDataEntryAssignment) GetRaw() []byte {
+	raw := append([]byte{}, data.Entry.Name.GetRaw()...)
+	raw = append(raw, byte(data.Entry.Type))
+	raw = append(raw, data.Entry.ID[:]...)
+	raw = append(raw, data.Entry.Sequence[:]...)
+	raw = append(raw, byte(data.Entry.Flags))
+	return append(raw, data.Entry.Value.GetRaw()...)
+}
+
+func (data *MessageDataEntryUpdate) GetRaw() []byte {
+	raw := append([]byte{}, data.Entry.ID[:]...)
+	raw = append(raw, data.Entry.Sequence[:]...)
+	raw = append(raw, byte(data.Entry.Type))
+	return append(raw, data.Entry.Value.GetRaw()...)
+}
+
+func (data *MessageDataEntryFlagsUpdate) GetRaw() []byte {
+	return append(append([]byte{}, data.Entry.ID[:]...), byte(data.Entry.Flags))
+}
+
+func (data *MessageDataEntryDelete) GetRaw() []byte {
+	return append([]byte{}, data.Entry.ID[:]...)
+}
+
+func (data *MessageDataClearAll) GetRaw() []byte {
+	return append([]byte{}, data.PotentialMagic[:]...)
+}
+
+func (data *MessageDataRPCExecute) GetRaw() []byte {
+	raw := append(append([]byte{}, data.EntryID[:]...), data.UniqueID[:]...)
+	raw = append(raw, EncodeULEB128(uint32(len(data.Params)))...)
+	return append(raw, data.Params...)
+}
+
+func (data *MessageDataRPCResponse) GetRaw() []byte {
+	raw := append(append([]byte{}, data.EntryID[:]...), data.UniqueID[:]...)
+	raw = append(raw, EncodeULEB128(uint32(len(data.Results)))...)
+	return append(raw, data.Results...)
+}
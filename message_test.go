@@ -52,7 +52,7 @@ func TestDecodeDataServerHello(t *testing.T) {
 		},
 	}
 	if !reflect.DeepEqual(expected, result) {
-		t.Fatalf("Expected %s but got %s", expected, result)
+		t.Fatalf("Expected %v but got %v", expected, result)
 	}
 }
 
@@ -1,10 +1,31 @@
 package ntgo
 
-import "errors"
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
 
 const (
-	DefaultAddress string = "0.0.0.0"
-	DefaultPort    string = "1735"
+	DefaultAddress  string = "0.0.0.0"
+	DefaultPort     string = "1735"
+	DefaultIdentity string = "ntgo"
+
+	// DefaultUpdateRate is how often buffered changes are written to the
+	// network when NetworkTables.UpdateRate is not set.
+	DefaultUpdateRate = 100 * time.Millisecond
+	// DefaultTimeout bounds connecting and the initial handshake when
+	// NetworkTables.Timeout is not set.
+	DefaultTimeout = 5 * time.Second
+	// DefaultPersistPeriod is how often a server saves persistent entries
+	// when NetworkTables.PersistPeriod is not set.
+	DefaultPersistPeriod = time.Second
 )
 
 const (
@@ -13,7 +34,9 @@ const (
 )
 
 var (
-	ErrUnknownMode error = errors.New("ntgo: unknown or unsupported network table mode. must be client or server")
+	ErrUnknownMode      error = errors.New("ntgo: unknown or unsupported network table mode. must be client or server")
+	ErrNotConnected           = errors.New("ntgo: not connected")
+	ErrRPCParamsInvalid       = errors.New("rpc: parameters do not match the procedure definition")
 
 	DefaultSettings *NetworkTables = &NetworkTables{
 		Address: DefaultAddress,
@@ -28,26 +51,100 @@ type NetworkTables struct {
 	Address string
 	Port    string
 	Mode    mode
+	// Servers lists the addresses a client rotates through, moving on to
+	// the next one whenever connecting fails or the connection drops. An
+	// address may carry its own port; otherwise Port is used. When empty,
+	// the client connects to Address.
+	Servers []string
+	// Backoff is how long a client waits between connection attempts.
+	// DefaultBackoff is used when it is left empty.
+	Backoff Backoff
+	// Team is the FRC team number of the robot to connect to. When set, a
+	// client ignores Address and tries every address Resolver gives for
+	// the team at once, keeping whichever connects first.
+	Team int
+	// Resolver lists the addresses of a team. TeamAddresses is used when
+	// it is nil.
+	Resolver TeamResolver
+	// Identity is the name sent to the remote end in the hello message.
+	Identity string
+	// UpdateRate is how often buffered changes are written to the network.
+	UpdateRate time.Duration
+	// Timeout bounds connecting and the initial handshake.
+	Timeout time.Duration
+
+	// TLS, when set, encrypts every connection. A client checks the server
+	// certificate against it; a server presents its certificate and, if
+	// ClientAuth asks for one, identifies each client by the subject of its
+	// certificate instead of the identity in its hello message.
+	TLS *tls.Config
+	// AllowRevision2 turns on NetworkTables 2.0 compatibility: a server
+	// accepts 2.0 clients, and a client falls back to 2.0 when the server
+	// only speaks 2.0. Revision 2.0 has no entry flags, deletes, raw values
+	// or RPC, so those are not sent to 2.0 peers.
+	AllowRevision2 bool
+	// Dialer opens client connections. A NetDialer is used when it is nil.
+	Dialer Dialer
+	// Listen is the list of addresses a server accepts clients on: TCP
+	// host:port addresses, or Unix domain socket paths after UnixPrefix.
+	Listen []string
+	// Listeners are extra transports a server accepts clients on, such as
+	// a Pipe. The server closes them when it is closed. When both Listen
+	// and Listeners are empty, the server listens on Address and Port.
+	Listeners []Listener
+	// PersistFile is where a server keeps persistent entries between runs.
+	// Persistence is disabled when it is empty.
+	PersistFile string
+	// PersistPeriod is how often a server saves changed persistent entries.
+	PersistPeriod time.Duration
+	// MaxClients limits how many clients a server accepts at once. Zero
+	// means no limit.
+	MaxClients int
+	// MaxEntries limits how many entries clients may create on a server.
+	// Zero means no limit.
+	MaxEntries int
+	// Logger receives connection and persistence messages. Nothing is
+	// logged when it is nil.
+	Logger *log.Logger
+	// Recorder, when set, records every message a client or server sends
+	// or receives. It is not closed with nt.
+	Recorder *Recorder
+
+	// NewOperator, when set, creates the Operator instead of Mode. It lets
+	// protocols implemented outside this package, such as the 4.0 client
+	// and server in package nt4, drive the same table.
+	NewOperator func() Operator
+
 	Operator
+
+	table *Table
+	conns *connections
 }
 
 type Operator interface {
 	CreateEntry(entry Entry) error
 	DeleteEntry(entry Entry) error
 	UpdateEntry(entry Entry) error
+	UpdateEntryFlags(entry Entry) error
 	GetEntry(id [2]byte) error
+	CallRPC(ctx context.Context, id [2]byte, params []byte) ([]byte, error)
 	Initialize(nt NetworkTables) error
+	Close() error
 }
 
 func (nt *NetworkTables) Initialize() error {
 	var operator Operator
-	if nt.Mode == ModeClient {
+	if nt.NewOperator != nil {
+		operator = nt.NewOperator()
+	} else if nt.Mode == ModeClient {
 		operator = &client{}
 	} else if nt.Mode == ModeServer {
-		// TODO: Add server
+		operator = &server{}
 	} else {
 		return ErrUnknownMode
 	}
+	nt.Table()
+	nt.connections()
 	initErr := operator.Initialize(*nt)
 	if initErr != nil {
 		return initErr
@@ -55,3 +152,173 @@ func (nt *NetworkTables) Initialize() error {
 	nt.Operator = operator
 	return nil
 }
+
+var tableInit sync.Mutex
+
+// Table returns the local copy of the entries known to nt.
+func (nt *NetworkTables) Table() *Table {
+	tableInit.Lock()
+	defer tableInit.Unlock()
+	if nt.table == nil {
+		nt.table = NewTable()
+	}
+	return nt.table
+}
+
+// SetValue creates or updates the named entry locally and hands the change
+// to the Operator, if nt has been initialized.
+func (nt *NetworkTables) SetValue(name string, entryType EntryType, value EntryValue) error {
+	entry, kind, setErr := nt.Table().Set(name, entryType, value, true)
+	if setErr != nil {
+		return setErr
+	}
+	if nt.Operator == nil {
+		return nil
+	}
+	if kind == EntryEventCreated {
+		return nt.Operator.CreateEntry(entry)
+	}
+	return nt.Operator.UpdateEntry(entry)
+}
+
+// SetFlags replaces the flags of the named entry locally and hands the change
+// to the Operator, if nt has been initialized.
+func (nt *NetworkTables) SetFlags(name string, flags EntryFlag) error {
+	entry, flagsErr := nt.Table().SetFlags(name, flags, true)
+	if flagsErr != nil {
+		return flagsErr
+	}
+	if nt.Operator == nil {
+		return nil
+	}
+	return nt.Operator.UpdateEntryFlags(entry)
+}
+
+// Delete removes the named entry locally and hands the change to the
+// Operator, if nt has been initialized.
+func (nt *NetworkTables) Delete(name string) error {
+	entry, deleteErr := nt.Table().Delete(name, true)
+	if deleteErr != nil {
+		return deleteErr
+	}
+	if nt.Operator == nil {
+		return nil
+	}
+	return nt.Operator.DeleteEntry(entry)
+}
+
+// CallRPC executes the named remote procedure and returns its results.
+// Parameters that are not given take the default from the definition.
+func (nt *NetworkTables) CallRPC(ctx context.Context, name string, params ...EntryValue) ([]EntryValue, error) {
+	entry, ok := nt.Table().Get(name)
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	definition, ok := entry.Value.(*ValueRPC)
+	if !ok {
+		return nil, ErrEntryCastInvalid
+	}
+	if len(params) > len(definition.Params) {
+		return nil, ErrRPCParamsInvalid
+	}
+	paramsRaw := []byte{}
+	for i, param := range definition.Params {
+		value := param.DefaultVal
+		if i < len(params) {
+			value = params[i]
+		}
+		if EntryValueType(value) != param.Type {
+			return nil, ErrRPCParamsInvalid
+		}
+		paramsRaw = append(paramsRaw, value.GetRaw()...)
+	}
+	if nt.Operator == nil {
+		return nil, ErrNotConnected
+	}
+	resultsRaw, callErr := nt.Operator.CallRPC(ctx, entry.ID, paramsRaw)
+	if callErr != nil {
+		return nil, callErr
+	}
+	reader := bytes.NewReader(resultsRaw)
+	results := make([]EntryValue, len(definition.Outputs))
+	for i, output := range definition.Outputs {
+		result, resultErr := DecodeEntryValue(reader, output.Type)
+		if resultErr != nil {
+			return nil, resultErr
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// Close shuts down the Operator, if nt has been initialized.
+func (nt *NetworkTables) Close() error {
+	if nt.Operator == nil {
+		return nil
+	}
+	return nt.Operator.Close()
+}
+
+// Endpoint returns the address a client is connected to, or an empty string
+// while it is disconnected or when nt is not a client.
+func (nt *NetworkTables) Endpoint() string {
+	cl, ok := nt.Operator.(*client)
+	if !ok {
+		return ""
+	}
+	return cl.Endpoint()
+}
+
+// endpoints returns the addresses, with ports, a client rotates through.
+func (nt *NetworkTables) endpoints() []string {
+	if len(nt.Servers) == 0 {
+		return []string{net.JoinHostPort(nt.Address, nt.Port)}
+	}
+	addresses := []string{}
+	for _, address := range nt.Servers {
+		addresses = append(addresses, withPort(address, nt.Port))
+	}
+	return addresses
+}
+
+func (nt *NetworkTables) dialer() Dialer {
+	if nt.Dialer == nil {
+		return &NetDialer{}
+	}
+	return nt.Dialer
+}
+
+func (nt *NetworkTables) identity() string {
+	if nt.Identity == "" {
+		return DefaultIdentity
+	}
+	return nt.Identity
+}
+
+func (nt *NetworkTables) updateRate() time.Duration {
+	if nt.UpdateRate <= 0 {
+		return DefaultUpdateRate
+	}
+	return nt.UpdateRate
+}
+
+func (nt *NetworkTables) timeout() time.Duration {
+	if nt.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return nt.Timeout
+}
+
+func (nt *NetworkTables) persistPeriod() time.Duration {
+	if nt.PersistPeriod <= 0 {
+		return DefaultPersistPeriod
+	}
+	return nt.PersistPeriod
+}
+
+func (nt *NetworkTables) logger() *log.Logger {
+	if nt.Logger == nil {
+		return log.New(io.Discard, "", 0)
+	}
+	return nt.Logger
+}
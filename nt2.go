@@ -0,0 +1,257 @@
+package ntgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ProtocolRevision2 is the NetworkTables 2.0 revision, spoken only when
+	// NetworkTables.AllowRevision2 is set.
+	ProtocolRevision2 = ProtocolRevision{0x02, 0x00}
+)
+
+var ErrRevision2Unknown = errors.New("message: revision 2.0 update for an unknown entry")
+
+// wire reads and writes messages in one protocol revision. Revision 2.0 has
+// no entry flags, deletes, raw values or RPC, and its Entry Update does not
+// carry the entry type, so typeOf looks it up from the entry ID. Messages
+// revision 2.0 cannot express are dropped when sending. Every message that
+// goes through a wire is handed to its recorder, if any, under its session.
+type wire struct {
+	revision ProtocolRevision
+	typeOf   func(id [2]byte) (EntryType, bool)
+	recorder *Recorder
+	session  int
+}
+
+func (w wire) decode(r io.Reader) (*Message, error) {
+	var message *Message
+	var decodeErr error
+	if w.revision == ProtocolRevision2 {
+		message, decodeErr = w.decode2(r)
+	} else {
+		message, decodeErr = DecodeMessage(r)
+	}
+	if decodeErr == nil {
+		w.recorder.Record(w.session, DirectionReceived, message)
+	}
+	return message, decodeErr
+}
+
+func (w wire) send(conn io.Writer, messages ...*Message) error {
+	if w.revision != ProtocolRevision2 {
+		for _, message := range messages {
+			w.recorder.Record(w.session, DirectionSent, message)
+		}
+		return sendMessages(conn, messages...)
+	}
+	raw := []byte{}
+	for _, message := range messages {
+		encoded := encode2(message)
+		if len(encoded) > 0 {
+			w.recorder.Record(w.session, DirectionSent, message)
+		}
+		raw = append(raw, encoded...)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	_, writeErr := conn.Write(raw)
+	return writeErr
+}
+
+// readClientHello reads a Client Hello. The identity only exists in revision
+// 3.0; for any other revision nothing past the revision is read, so that the
+// server can answer right away.
+func readClientHello(r io.Reader) (*MessageDataClientHello, error) {
+	messageType, typeErr := DecodeMessageType(r)
+	if typeErr != nil {
+		return nil, typeErr
+	}
+	if messageType != MessageTypeClientHello {
+		return nil, ErrHandshakeInvalid
+	}
+	hello := &MessageDataClientHello{Identity: BuildString("")}
+	if _, revisionErr := io.ReadFull(r, hello.ProtocVersion[:]); revisionErr != nil {
+		return nil, revisionErr
+	}
+	if hello.ProtocVersion != ProtocolRevision3 {
+		return hello, nil
+	}
+	identity, identityErr := DecodeString(r)
+	if identityErr != nil {
+		return nil, identityErr
+	}
+	hello.Identity = identity
+	return hello, nil
+}
+
+// revision2Type reports whether entryType exists in revision 2.0.
+func revision2Type(entryType EntryType) bool {
+	switch entryType {
+	case EntryTypeBoolean, EntryTypeDouble, EntryTypeString, EntryTypeBooleanArr, EntryTypeDoubleArr, EntryTypeStringArr:
+		return true
+	}
+	return false
+}
+
+// encode2 returns message in revision 2.0 wire format, or nothing when it
+// has no 2.0 equivalent.
+func encode2(message *Message) []byte {
+	switch data := message.Data.(type) {
+	case *MessageDataKeepAlive, *MessageDataServerHelloComplete:
+		return []byte{byte(message.Type)}
+	case *MessageDataClientHello:
+		return []byte{byte(message.Type), data.ProtocVersion[0], data.ProtocVersion[1]}
+	case *MessageDataProtocVersionUnsupported:
+		return []byte{byte(message.Type), data.SupportedProtoc[0], data.SupportedProtoc[1]}
+	case *MessageDataEntryAssignment:
+		entry := data.Entry
+		if !revision2Type(entry.Type) {
+			return nil
+		}
+		raw := []byte{byte(message.Type)}
+		raw = append(raw, encodeString2(entry.Name.Value)...)
+		raw = append(raw, byte(entry.Type), entry.ID[0], entry.ID[1], entry.Sequence[0], entry.Sequence[1])
+		return append(raw, encodeValue2(entry.Value)...)
+	case *MessageDataEntryUpdate:
+		entry := data.Entry
+		if !revision2Type(entry.Type) {
+			return nil
+		}
+		raw := []byte{byte(message.Type), entry.ID[0], entry.ID[1], entry.Sequence[0], entry.Sequence[1]}
+		return append(raw, encodeValue2(entry.Value)...)
+	}
+	return nil
+}
+
+func (w wire) decode2(r io.Reader) (*Message, error) {
+	messageType, typeErr := DecodeMessageType(r)
+	if typeErr != nil {
+		return nil, typeErr
+	}
+	switch messageType {
+	case MessageTypeKeepAlive:
+		return BuildMessage(&MessageDataKeepAlive{}), nil
+	case MessageTypeServerHelloComplete:
+		return BuildMessage(&MessageDataServerHelloComplete{}), nil
+	case MessageTypeClientHello, MessageTypeProtocVersionUnsupported:
+		revision := ProtocolRevision{}
+		if _, readErr := io.ReadFull(r, revision[:]); readErr != nil {
+			return nil, readErr
+		}
+		if messageType == MessageTypeClientHello {
+			return BuildMessage(&MessageDataClientHello{ProtocVersion: revision, Identity: BuildString("")}), nil
+		}
+		return BuildMessage(&MessageDataProtocVersionUnsupported{SupportedProtoc: revision}), nil
+	case MessageTypeEntryAssignment:
+		name, nameErr := decodeString2(r)
+		if nameErr != nil {
+			return nil, nameErr
+		}
+		entryType, typeErr := DecodeEntryType(r)
+		if typeErr != nil {
+			return nil, typeErr
+		}
+		header := [4]byte{}
+		if _, readErr := io.ReadFull(r, header[:]); readErr != nil {
+			return nil, readErr
+		}
+		value, valueErr := decodeValue2(r, entryType)
+		if valueErr != nil {
+			return nil, valueErr
+		}
+		return BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{
+			Name:     name,
+			Type:     entryType,
+			ID:       [2]byte{header[0], header[1]},
+			Sequence: [2]byte{header[2], header[3]},
+			Flags:    EntryFlagTemporary,
+			Value:    value,
+		}}), nil
+	case MessageTypeEntryUpdate:
+		header := [4]byte{}
+		if _, readErr := io.ReadFull(r, header[:]); readErr != nil {
+			return nil, readErr
+		}
+		id := [2]byte{header[0], header[1]}
+		entryType, ok := w.typeOf(id)
+		if !ok {
+			// Without the type the length of the value is unknown, so
+			// the rest of the stream cannot be read.
+			return nil, ErrRevision2Unknown
+		}
+		value, valueErr := decodeValue2(r, entryType)
+		if valueErr != nil {
+			return nil, valueErr
+		}
+		return BuildMessage(&MessageDataEntryUpdate{Entry: &Entry{
+			Type:     entryType,
+			ID:       id,
+			Sequence: [2]byte{header[2], header[3]},
+			Value:    value,
+		}}), nil
+	}
+	return nil, ErrMessageNoSuchType
+}
+
+// Revision 2.0 strings have a 2 byte big-endian length instead of a ULEB128
+// one. Every other value is encoded the same way as in revision 3.0.
+
+func encodeString2(value string) []byte {
+	length := min(len(value), 0xFFFF)
+	raw := binary.BigEndian.AppendUint16(nil, uint16(length))
+	return append(raw, value[:length]...)
+}
+
+func decodeString2(r io.Reader) (*ValueString, error) {
+	length := [2]byte{}
+	if _, readErr := io.ReadFull(r, length[:]); readErr != nil {
+		return nil, readErr
+	}
+	data := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, readErr := io.ReadFull(r, data); readErr != nil {
+		return nil, readErr
+	}
+	return BuildString(string(data)), nil
+}
+
+func encodeValue2(value EntryValue) []byte {
+	switch value := value.(type) {
+	case *ValueString:
+		return encodeString2(value.Value)
+	case *ValueStringArray:
+		raw := []byte{byte(value.Len())}
+		for _, element := range value.Values() {
+			raw = append(raw, encodeString2(element)...)
+		}
+		return raw
+	}
+	return value.GetRaw()
+}
+
+func decodeValue2(r io.Reader, entryType EntryType) (EntryValue, error) {
+	switch entryType {
+	case EntryTypeString:
+		return decodeString2(r)
+	case EntryTypeStringArr:
+		count := [1]byte{}
+		if _, readErr := io.ReadFull(r, count[:]); readErr != nil {
+			return nil, readErr
+		}
+		elements := []*ValueString{}
+		for range count[0] {
+			element, elementErr := decodeString2(r)
+			if elementErr != nil {
+				return nil, elementErr
+			}
+			elements = append(elements, element)
+		}
+		return BuildStringArray(elements)
+	case EntryTypeRawData, EntryTypeRPCDef:
+		return nil, ErrEntryNoSuchType
+	}
+	return DecodeEntryValue(r, entryType)
+}
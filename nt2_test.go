@@ -0,0 +1,187 @@
+package ntgo
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// rawConn dials srv and speaks to it in revision 2.0 by hand.
+func rawConn(t *testing.T, srv *NetworkTables) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", srv.ListenAddrs()[0].String())
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func TestServerRejectsUnsupportedRevision(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	for _, revision := range []ProtocolRevision{ProtocolRevision2, {0x04, 0x00}} {
+		conn, reader := rawConn(t, srv)
+		conn.Write([]byte{MessageTypeClientHello, revision[0], revision[1]})
+		message, err := DecodeMessage(reader)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		unsupported, ok := message.Data.(*MessageDataProtocVersionUnsupported)
+		if !ok || unsupported.SupportedProtoc != ProtocolRevision3 {
+			t.Fatalf("Expected Protocol Version Unsupported for 3.0 but got %+v", message.Data)
+		}
+	}
+}
+
+func TestRevision2Encoding(t *testing.T) {
+	array, _ := BuildStringArrayFrom([]string{"left", "right"})
+	entry := Entry{Name: BuildString("/auto/modes"), Type: EntryTypeStringArr, ID: [2]byte{0, 7}, Sequence: [2]byte{0, 2}, Value: array}
+	raw := encode2(BuildMessage(&MessageDataEntryAssignment{Entry: &entry}))
+	expected := []byte{
+		0x10, 0x00, 0x0B, '/', 'a', 'u', 't', 'o', '/', 'm', 'o', 'd', 'e', 's',
+		0x12, 0x00, 0x07, 0x00, 0x02,
+		0x02, 0x00, 0x04, 'l', 'e', 'f', 't', 0x00, 0x05, 'r', 'i', 'g', 'h', 't',
+	}
+	if !bytes.Equal(raw, expected) {
+		t.Fatalf("Expected %x but got %x", expected, raw)
+	}
+	w := wire{revision: ProtocolRevision2, typeOf: func(id [2]byte) (EntryType, bool) { return EntryTypeStringArr, true }}
+	message, err := w.decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	decoded := message.Data.(*MessageDataEntryAssignment).Entry
+	if decoded.Name.Value != "/auto/modes" || !slices.Equal(decoded.Value.(*ValueStringArray).Values(), []string{"left", "right"}) {
+		t.Fatalf("Expected the assignment back but got %s", decoded)
+	}
+	if raw := encode2(BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &entry})); len(raw) != 0 {
+		t.Fatalf("Expected flags update to be dropped but got %x", raw)
+	}
+}
+
+func TestServerRevision2Client(t *testing.T) {
+	srv := startServer(t, &NetworkTables{AllowRevision2: true})
+	srv.String("/robot/mode").Set("auto")
+	srv.Raw("/robot/blob").Set([]byte{1, 2, 3})
+	conn, reader := rawConn(t, srv)
+	w := wire{revision: ProtocolRevision2, typeOf: func(id [2]byte) (EntryType, bool) {
+		entry, ok := srv.Table().GetByID(id)
+		return entry.Type, ok
+	}}
+	w.send(conn, BuildMessage(&MessageDataClientHello{ProtocVersion: ProtocolRevision2}))
+	announced := []string{}
+	for {
+		message, err := w.decode(reader)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if message.Type == MessageTypeServerHelloComplete {
+			break
+		}
+		announced = append(announced, message.Data.(*MessageDataEntryAssignment).Entry.Name.Value)
+	}
+	if !slices.Equal(announced, []string{"/robot/mode"}) {
+		t.Fatalf("Expected only the string entry to be announced but got %v", announced)
+	}
+
+	w.send(conn, BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{
+		Name:  BuildString("/dashboard/choice"),
+		Type:  EntryTypeString,
+		ID:    EntryIDUnassigned,
+		Value: BuildString("left"),
+	}}))
+	waitFor(t, "2.0 assignment to reach the server", func() bool {
+		return srv.String("/dashboard/choice").GetOr("") == "left"
+	})
+	srv.String("/robot/mode").Set("teleop")
+	for {
+		message, err := w.decode(reader)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if update, ok := message.Data.(*MessageDataEntryUpdate); ok {
+			if update.Entry.Value.(*ValueString).Value != "teleop" {
+				t.Fatalf("Expected teleop but got %s", update.Entry.Value)
+			}
+			break
+		}
+	}
+}
+
+func TestClientFallsBackToRevision2(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer listener.Close()
+	received := make(chan *Entry, 1)
+	go func() {
+		// A 2.0 server refuses the 3.0 hello, then serves the retry.
+		w := wire{revision: ProtocolRevision2, typeOf: func(id [2]byte) (EntryType, bool) { return EntryTypeDouble, true }}
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			hello, helloErr := readClientHello(reader)
+			if helloErr != nil {
+				conn.Close()
+				continue
+			}
+			if hello.ProtocVersion != ProtocolRevision2 {
+				w.send(conn, BuildMessage(&MessageDataProtocVersionUnsupported{SupportedProtoc: ProtocolRevision2}))
+				conn.Close()
+				continue
+			}
+			w.send(conn,
+				BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{
+					Name:  BuildString("/robot/speed"),
+					Type:  EntryTypeDouble,
+					ID:    [2]byte{0, 1},
+					Value: BuildDouble(1.5),
+				}}),
+				BuildMessage(&MessageDataServerHelloComplete{}),
+			)
+			for {
+				message, decodeErr := w.decode(reader)
+				if decodeErr != nil {
+					conn.Close()
+					return
+				}
+				if assignment, ok := message.Data.(*MessageDataEntryAssignment); ok {
+					received <- assignment.Entry
+				}
+			}
+		}
+	}()
+
+	cl := &NetworkTables{
+		Mode:           ModeClient,
+		Servers:        []string{listener.Addr().String()},
+		AllowRevision2: true,
+		UpdateRate:     5 * time.Millisecond,
+	}
+	cl.Double("/dashboard/value").Set(4)
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if cl.Double("/robot/speed").GetOr(0) != 1.5 {
+		t.Fatal("Expected the 2.0 server entry to be announced")
+	}
+	if revision := cl.Connections()[0].ProtocolRevision; revision != ProtocolRevision2 {
+		t.Fatalf("Expected revision 2.0 but got %x", revision)
+	}
+	select {
+	case entry := <-received:
+		if entry.Name.Value != "/dashboard/value" {
+			t.Fatalf("Expected /dashboard/value but got %s", entry.Name.Value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the client entry")
+	}
+}
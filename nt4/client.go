@@ -0,0 +1,546 @@
+package nt4
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+	"github.com/gorilla/websocket"
+)
+
+// Topic describes a topic the server announced.
+type Topic struct {
+	Name       string
+	ID         int64
+	Type       string
+	Properties map[string]any
+}
+
+// Client is a NetworkTables 4.0 client. It mirrors the topics under
+// Prefixes into the table and publishes every entry set locally, keeping
+// both across reconnects.
+type Client struct {
+	// Prefixes are the topic name prefixes mirrored into the table. Every
+	// topic is mirrored when it is empty.
+	Prefixes []string
+	// Options are the options of the subscription to Prefixes. Prefix is
+	// always set.
+	Options SubscribeOptions
+
+	table    *ntgo.Table
+	identity string
+	servers  []string
+	dialer   websocket.Dialer
+	scheme   string
+	backoff  ntgo.Backoff
+	log      *log.Logger
+
+	mu            sync.Mutex
+	conn          *websocket.Conn
+	topics        map[int64]*Topic
+	byName        map[string]int64
+	publications  map[string]*publication
+	nextPubUID    int64
+	subscriptions map[int64]subscribeParams
+	nextSubUID    int64
+	timestamps    map[string]int64
+	offset        int64
+	synced        bool
+
+	writeMu   sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type publication struct {
+	pubuid   int64
+	typeName string
+	code     int
+}
+
+// NewClient returns a Client mirroring every topic, for use as
+// NetworkTables.NewOperator.
+func NewClient() ntgo.Operator {
+	return &Client{}
+}
+
+// Initialize connects to the first of nt.Servers, or nt.Address, that
+// answers. The port defaults to DefaultPort, and nt.TLS switches to secure
+// WebSockets.
+func (cl *Client) Initialize(nt ntgo.NetworkTables) error {
+	cl.table = nt.Table()
+	cl.identity = nt.Identity
+	if cl.identity == "" {
+		cl.identity = ntgo.DefaultIdentity
+	}
+	port := nt.Port
+	if port == "" {
+		port = DefaultPort
+	}
+	servers := nt.Servers
+	if len(servers) == 0 {
+		servers = []string{nt.Address}
+	}
+	cl.servers = []string{}
+	for _, server := range servers {
+		if _, _, splitErr := net.SplitHostPort(server); splitErr != nil {
+			server = net.JoinHostPort(server, port)
+		}
+		cl.servers = append(cl.servers, server)
+	}
+	timeout := nt.Timeout
+	if timeout <= 0 {
+		timeout = ntgo.DefaultTimeout
+	}
+	cl.dialer = websocket.Dialer{
+		HandshakeTimeout: timeout,
+		Subprotocols:     []string{Subprotocol, SubprotocolV40},
+		TLSClientConfig:  nt.TLS,
+	}
+	cl.scheme = "ws"
+	if nt.TLS != nil {
+		cl.scheme = "wss"
+	}
+	cl.backoff = nt.Backoff
+	cl.log = nt.Logger
+	if cl.log == nil {
+		cl.log = log.New(io.Discard, "", 0)
+	}
+	cl.topics = map[int64]*Topic{}
+	cl.byName = map[string]int64{}
+	cl.publications = map[string]*publication{}
+	cl.subscriptions = map[int64]subscribeParams{}
+	cl.timestamps = map[string]int64{}
+	cl.done = make(chan struct{})
+
+	prefixes := cl.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	options := cl.Options
+	options.Prefix = true
+	cl.nextSubUID++
+	cl.subscriptions[cl.nextSubUID] = subscribeParams{Topics: prefixes, SubUID: cl.nextSubUID, Options: options}
+
+	conn, connectErr := cl.connect()
+	if connectErr != nil {
+		return connectErr
+	}
+	go cl.run(conn)
+	return nil
+}
+
+// connect dials the servers in order and restores the subscriptions and
+// publications on the first that answers.
+func (cl *Client) connect() (*websocket.Conn, error) {
+	var dialErr error
+	for _, server := range cl.servers {
+		address := url.URL{Scheme: cl.scheme, Host: server, Path: "/nt/" + url.PathEscape(cl.identity)}
+		var conn *websocket.Conn
+		conn, _, dialErr = cl.dialer.Dial(address.String(), nil)
+		if dialErr != nil {
+			continue
+		}
+		conn.SetReadLimit(readLimit)
+		cl.log.Printf("nt4: connected to %s", server)
+		cl.mu.Lock()
+		cl.conn = conn
+		cl.synced = false
+		cl.mu.Unlock()
+		if restoreErr := cl.restore(); restoreErr != nil {
+			conn.Close()
+			return nil, restoreErr
+		}
+		return conn, nil
+	}
+	return nil, dialErr
+}
+
+// restore subscribes and publishes again on a new connection, sending the
+// current value of every published entry, and asks for the server time.
+func (cl *Client) restore() error {
+	cl.mu.Lock()
+	subscriptions := []subscribeParams{}
+	for _, params := range cl.subscriptions {
+		subscriptions = append(subscriptions, params)
+	}
+	publications := map[string]publication{}
+	for name, pub := range cl.publications {
+		publications[name] = *pub
+	}
+	cl.mu.Unlock()
+	for _, params := range subscriptions {
+		if sendErr := cl.sendText("subscribe", params); sendErr != nil {
+			return sendErr
+		}
+	}
+	for name, pub := range publications {
+		entry, ok := cl.table.Get(name)
+		if !ok {
+			continue
+		}
+		if sendErr := cl.sendPublish(name, pub, entry.Flags); sendErr != nil {
+			return sendErr
+		}
+		if sendErr := cl.sendValue(pub, entry.Value); sendErr != nil {
+			return sendErr
+		}
+	}
+	return cl.sendTimeSync()
+}
+
+func (cl *Client) run(conn *websocket.Conn) {
+	for {
+		cl.readLoop(conn)
+		conn.Close()
+		cl.mu.Lock()
+		cl.conn = nil
+		clear(cl.topics)
+		clear(cl.byName)
+		cl.mu.Unlock()
+		select {
+		case <-cl.done:
+			return
+		default:
+		}
+		cl.log.Printf("nt4: lost connection")
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-cl.done:
+				return
+			case <-time.After(cl.backoff.Delay(attempt)):
+			}
+			var connectErr error
+			conn, connectErr = cl.connect()
+			if connectErr == nil {
+				break
+			}
+		}
+	}
+}
+
+func (cl *Client) readLoop(conn *websocket.Conn) {
+	for {
+		frameType, data, readErr := conn.ReadMessage()
+		if readErr != nil {
+			return
+		}
+		if frameType == websocket.TextMessage {
+			messages := []textMessage{}
+			if jsonErr := json.Unmarshal(data, &messages); jsonErr != nil {
+				cl.log.Printf("nt4: dropping text frame: %s", jsonErr)
+				continue
+			}
+			for _, message := range messages {
+				cl.handleText(message)
+			}
+			continue
+		}
+		messages, decodeErr := decodeFrame(data)
+		if decodeErr != nil {
+			cl.log.Printf("nt4: dropping binary frame: %s", decodeErr)
+			continue
+		}
+		for _, message := range messages {
+			cl.handleValue(message)
+		}
+	}
+}
+
+func (cl *Client) handleText(message textMessage) {
+	switch message.Method {
+	case "announce":
+		params := announceParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		if params.Properties == nil {
+			params.Properties = map[string]any{}
+		}
+		cl.mu.Lock()
+		cl.topics[params.ID] = &Topic{Name: params.Name, ID: params.ID, Type: params.Type, Properties: params.Properties}
+		cl.byName[params.Name] = params.ID
+		cl.mu.Unlock()
+		setFlags(cl.table, params.Name, params.Properties)
+	case "unannounce":
+		params := unannounceParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		cl.mu.Lock()
+		delete(cl.topics, params.ID)
+		delete(cl.byName, params.Name)
+		delete(cl.timestamps, params.Name)
+		delete(cl.publications, params.Name)
+		cl.mu.Unlock()
+		cl.table.Delete(params.Name, false)
+	case "properties":
+		params := propertiesParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		cl.mu.Lock()
+		if id, ok := cl.byName[params.Name]; ok {
+			mergeProperties(cl.topics[id].Properties, params.Update)
+		}
+		cl.mu.Unlock()
+		setFlags(cl.table, params.Name, params.Update)
+	}
+}
+
+func (cl *Client) handleValue(message valueMessage) {
+	if message.id == timeSyncID {
+		// The server time was taken halfway through the round trip.
+		now := localTime()
+		cl.mu.Lock()
+		cl.offset = message.timestamp + (now-message.clientTime)/2 - now
+		cl.synced = true
+		cl.mu.Unlock()
+		return
+	}
+	cl.mu.Lock()
+	topic, ok := cl.topics[message.id]
+	if !ok {
+		cl.mu.Unlock()
+		return
+	}
+	name, properties := topic.Name, maps.Clone(topic.Properties)
+	cl.timestamps[name] = message.timestamp
+	cl.mu.Unlock()
+	storeValue(cl.table, name, message.value, properties)
+}
+
+// mergeProperties applies a property update: null deletes a property.
+func mergeProperties(properties map[string]any, update map[string]any) {
+	for key, value := range update {
+		if value == nil {
+			delete(properties, key)
+		} else {
+			properties[key] = value
+		}
+	}
+}
+
+func (cl *Client) sendText(method string, params any) error {
+	frame, encodeErr := encodeText(method, params)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return cl.send(websocket.TextMessage, frame)
+}
+
+func (cl *Client) sendValue(pub publication, value ntgo.EntryValue) error {
+	frame, encodeErr := encodeFrame(valueMessage{id: pub.pubuid, timestamp: cl.ServerTime(), code: pub.code, value: value})
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return cl.send(websocket.BinaryMessage, frame)
+}
+
+func (cl *Client) sendTimeSync() error {
+	frame, encodeErr := encodeFrame(valueMessage{id: timeSyncID, code: TypeInt, clientTime: localTime()})
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return cl.send(websocket.BinaryMessage, frame)
+}
+
+func (cl *Client) sendPublish(name string, pub publication, flags ntgo.EntryFlag) error {
+	return cl.sendText("publish", publishParams{
+		Name:       name,
+		PubUID:     pub.pubuid,
+		Type:       pub.typeName,
+		Properties: flagsProperties(flags),
+	})
+}
+
+// send writes one frame, or nothing while disconnected: whatever is
+// published is sent again on the next connection.
+func (cl *Client) send(frameType int, frame []byte) error {
+	cl.mu.Lock()
+	conn := cl.conn
+	cl.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+	if writeErr := conn.WriteMessage(frameType, frame); writeErr != nil {
+		conn.Close()
+		return writeErr
+	}
+	return nil
+}
+
+// localTime is the client clock in microseconds.
+func localTime() int64 {
+	return time.Now().UnixMicro()
+}
+
+// ServerTime returns the server clock in microseconds, as estimated when
+// connecting, or zero before the server has answered. Zero timestamps are
+// filled in by the server.
+func (cl *Client) ServerTime() int64 {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if !cl.synced {
+		return 0
+	}
+	return localTime() + cl.offset
+}
+
+// Timestamp returns the server timestamp of the last value received for the
+// named topic.
+func (cl *Client) Timestamp(name string) (int64, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	timestamp, ok := cl.timestamps[name]
+	return timestamp, ok
+}
+
+// Topic returns the named topic, if the server announced it.
+func (cl *Client) Topic(name string) (Topic, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	id, ok := cl.byName[name]
+	if !ok {
+		return Topic{}, false
+	}
+	topic := *cl.topics[id]
+	topic.Properties = maps.Clone(topic.Properties)
+	return topic, true
+}
+
+// Publish announces the named topic with an explicit type, such as "int"
+// or "json", which the table cannot express. The entry then holds the
+// table type of typeName, and later values are sent as typeName.
+func (cl *Client) Publish(name string, typeName string, properties map[string]any) error {
+	code, ok := typeCode(typeName)
+	if !ok {
+		return ErrTypeUnsupported
+	}
+	cl.mu.Lock()
+	pub, exists := cl.publications[name]
+	if !exists {
+		cl.nextPubUID++
+		pub = &publication{pubuid: cl.nextPubUID}
+		cl.publications[name] = pub
+	}
+	pub.typeName, pub.code = typeName, code
+	published := *pub
+	cl.mu.Unlock()
+	return cl.sendText("publish", publishParams{Name: name, PubUID: published.pubuid, Type: typeName, Properties: properties})
+}
+
+// Subscribe asks for the given topics, on top of Prefixes, and returns the
+// ID to unsubscribe with.
+func (cl *Client) Subscribe(topics []string, options SubscribeOptions) (int64, error) {
+	cl.mu.Lock()
+	cl.nextSubUID++
+	params := subscribeParams{Topics: topics, SubUID: cl.nextSubUID, Options: options}
+	cl.subscriptions[params.SubUID] = params
+	cl.mu.Unlock()
+	return params.SubUID, cl.sendText("subscribe", params)
+}
+
+func (cl *Client) Unsubscribe(subuid int64) error {
+	cl.mu.Lock()
+	delete(cl.subscriptions, subuid)
+	cl.mu.Unlock()
+	return cl.sendText("unsubscribe", unsubscribeParams{SubUID: subuid})
+}
+
+// publication returns the publication of entry, publishing it first if
+// needed.
+func (cl *Client) publication(entry ntgo.Entry) (publication, error) {
+	name := entry.Name.Value
+	cl.mu.Lock()
+	pub, exists := cl.publications[name]
+	if exists {
+		if existing, _ := entryType(pub.typeName); existing == entry.Type {
+			published := *pub
+			cl.mu.Unlock()
+			return published, nil
+		}
+	}
+	typeName, ok := typeName(entry.Type)
+	if !ok {
+		cl.mu.Unlock()
+		return publication{}, ErrTypeUnsupported
+	}
+	code, _ := typeCode(typeName)
+	if !exists {
+		cl.nextPubUID++
+		pub = &publication{pubuid: cl.nextPubUID}
+		cl.publications[name] = pub
+	}
+	pub.typeName, pub.code = typeName, code
+	published := *pub
+	cl.mu.Unlock()
+	return published, cl.sendPublish(name, published, entry.Flags)
+}
+
+func (cl *Client) CreateEntry(entry ntgo.Entry) error {
+	return cl.UpdateEntry(entry)
+}
+
+func (cl *Client) UpdateEntry(entry ntgo.Entry) error {
+	pub, pubErr := cl.publication(entry)
+	if pubErr != nil {
+		return pubErr
+	}
+	return cl.sendValue(pub, entry.Value)
+}
+
+func (cl *Client) UpdateEntryFlags(entry ntgo.Entry) error {
+	return cl.sendText("setproperties", setPropertiesParams{Name: entry.Name.Value, Update: flagsProperties(entry.Flags)})
+}
+
+// DeleteEntry stops publishing the entry. NT4 cannot delete topics, but a
+// server drops a topic that is neither published nor persistent, so the
+// persistent property is cleared too.
+func (cl *Client) DeleteEntry(entry ntgo.Entry) error {
+	name := entry.Name.Value
+	cl.mu.Lock()
+	pub, exists := cl.publications[name]
+	delete(cl.publications, name)
+	cl.mu.Unlock()
+	if entry.Flags&ntgo.EntryFlagPersistent != 0 {
+		if sendErr := cl.sendText("setproperties", setPropertiesParams{Name: name, Update: map[string]any{"persistent": false}}); sendErr != nil {
+			return sendErr
+		}
+	}
+	if !exists {
+		return nil
+	}
+	return cl.sendText("unpublish", unpublishParams{PubUID: pub.pubuid})
+}
+
+func (cl *Client) GetEntry(id [2]byte) error { return nil }
+
+func (cl *Client) CallRPC(ctx context.Context, id [2]byte, params []byte) ([]byte, error) {
+	return nil, ErrRPCUnsupported
+}
+
+func (cl *Client) Close() error {
+	cl.closeOnce.Do(func() {
+		close(cl.done)
+		cl.mu.Lock()
+		conn := cl.conn
+		cl.mu.Unlock()
+		if conn != nil {
+			cl.writeMu.Lock()
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			cl.writeMu.Unlock()
+			conn.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,431 @@
+// Package nt4 speaks NetworkTables 4.0, the WebSocket based protocol of
+// current WPILib releases, behind the same table API as the 3.0
+// implementation in package ntgo. Plug it in through NewOperator:
+//
+//	nt := &ntgo.NetworkTables{Address: "10.12.34.2", NewOperator: nt4.NewClient}
+//	if err := nt.Initialize(); err != nil {
+//		return err
+//	}
+//	speed := nt.Double("/SmartDashboard/speed").GetOr(0)
+//
+// Control messages travel as JSON in text frames and values as MessagePack
+// in binary frames. NT4 topics become table entries: integer and float
+// topics are read as doubles, json topics as strings, and raw, msgpack and
+// protobuf topics as raw values. The persistent property maps onto
+// ntgo.EntryFlagPersistent. NT4 has no remote procedure calls.
+package nt4
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/HowardStark/ntgo"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	DefaultPort = "5810"
+
+	// Subprotocol is the WebSocket subprotocol of revision 4.1.
+	// SubprotocolV40 is accepted for older peers.
+	Subprotocol    = "v4.1.networktables.first.wpi.edu"
+	SubprotocolV40 = "networktables.first.wpi.edu"
+)
+
+// Type codes of values in binary frames.
+const (
+	TypeBoolean      = 0
+	TypeDouble       = 1
+	TypeInt          = 2
+	TypeFloat        = 3
+	TypeString       = 4
+	TypeRaw          = 5
+	TypeBooleanArray = 16
+	TypeDoubleArray  = 17
+	TypeIntArray     = 18
+	TypeFloatArray   = 19
+	TypeStringArray  = 20
+)
+
+// readLimit is the largest frame read from a peer, as the WebSocket library
+// reads whole frames into memory with no limit of its own.
+const readLimit = 16 << 20
+
+// timeSyncID is the topic ID of the messages clients use to estimate the
+// server clock.
+const timeSyncID = -1
+
+var (
+	ErrTypeUnsupported = errors.New("nt4: unsupported topic type")
+	ErrRPCUnsupported  = errors.New("nt4: remote procedure calls do not exist in NetworkTables 4.0")
+	ErrNotConnected    = errors.New("nt4: not connected")
+)
+
+// SubscribeOptions are the options of a subscription.
+type SubscribeOptions struct {
+	// Periodic is how often, in seconds, the server sends changes.
+	Periodic float64 `json:"periodic,omitempty"`
+	// All asks for every value instead of only the latest one per period.
+	All bool `json:"all,omitempty"`
+	// TopicsOnly asks for announcements without values.
+	TopicsOnly bool `json:"topicsonly,omitempty"`
+	// Prefix matches topics whose names start with the subscribed names.
+	Prefix bool `json:"prefix,omitempty"`
+}
+
+type textMessage struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type publishParams struct {
+	Name       string         `json:"name"`
+	PubUID     int64          `json:"pubuid"`
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+}
+
+type unpublishParams struct {
+	PubUID int64 `json:"pubuid"`
+}
+
+type setPropertiesParams struct {
+	Name   string         `json:"name"`
+	Update map[string]any `json:"update"`
+}
+
+type subscribeParams struct {
+	Topics  []string         `json:"topics"`
+	SubUID  int64            `json:"subuid"`
+	Options SubscribeOptions `json:"options"`
+}
+
+type unsubscribeParams struct {
+	SubUID int64 `json:"subuid"`
+}
+
+type announceParams struct {
+	Name       string         `json:"name"`
+	ID         int64          `json:"id"`
+	Type       string         `json:"type"`
+	PubUID     *int64         `json:"pubuid,omitempty"`
+	Properties map[string]any `json:"properties"`
+}
+
+type unannounceParams struct {
+	Name string `json:"name"`
+	ID   int64  `json:"id"`
+}
+
+type propertiesParams struct {
+	Name   string         `json:"name"`
+	Ack    bool           `json:"ack,omitempty"`
+	Update map[string]any `json:"update"`
+}
+
+// encodeText returns a text frame holding one message.
+func encodeText(method string, params any) ([]byte, error) {
+	raw, marshalErr := json.Marshal(params)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return json.Marshal([]textMessage{{Method: method, Params: raw}})
+}
+
+// matches reports whether a subscription to topics covers name.
+func (params subscribeParams) matches(name string) bool {
+	for _, topic := range params.Topics {
+		if name == topic || params.Options.Prefix && len(name) >= len(topic) && name[:len(topic)] == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// valueMessage is one value in a binary frame. Time sync messages carry
+// clientTime instead of value.
+type valueMessage struct {
+	id         int64
+	timestamp  int64
+	code       int
+	value      ntgo.EntryValue
+	clientTime int64
+}
+
+func encodeFrame(messages ...valueMessage) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := msgpack.NewEncoder(&buffer)
+	for _, message := range messages {
+		encoder.EncodeArrayLen(4)
+		encoder.EncodeInt(message.id)
+		encoder.EncodeInt(message.timestamp)
+		encoder.EncodeInt(int64(message.code))
+		var valueErr error
+		if message.id == timeSyncID {
+			valueErr = encoder.EncodeInt(message.clientTime)
+		} else {
+			valueErr = encodeValue(encoder, message.code, message.value)
+		}
+		if valueErr != nil {
+			return nil, valueErr
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func decodeFrame(data []byte) ([]valueMessage, error) {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	messages := []valueMessage{}
+	for {
+		length, lengthErr := decoder.DecodeArrayLen()
+		if lengthErr == io.EOF {
+			return messages, nil
+		}
+		if lengthErr != nil {
+			return nil, lengthErr
+		}
+		if length != 4 {
+			return nil, ErrTypeUnsupported
+		}
+		message := valueMessage{}
+		var headerErr error
+		if message.id, headerErr = decoder.DecodeInt64(); headerErr != nil {
+			return nil, headerErr
+		}
+		if message.timestamp, headerErr = decoder.DecodeInt64(); headerErr != nil {
+			return nil, headerErr
+		}
+		if message.code, headerErr = decoder.DecodeInt(); headerErr != nil {
+			return nil, headerErr
+		}
+		var valueErr error
+		if message.id == timeSyncID {
+			message.clientTime, valueErr = decoder.DecodeInt64()
+		} else {
+			message.value, valueErr = decodeValue(decoder, message.code)
+		}
+		if valueErr != nil {
+			return nil, valueErr
+		}
+		messages = append(messages, message)
+	}
+}
+
+func encodeValue(encoder *msgpack.Encoder, code int, value ntgo.EntryValue) error {
+	switch value := value.(type) {
+	case *ntgo.ValueBoolean:
+		return encoder.EncodeBool(value.Value)
+	case *ntgo.ValueDouble:
+		switch code {
+		case TypeInt:
+			return encoder.EncodeInt(int64(value.Value))
+		case TypeFloat:
+			return encoder.EncodeFloat32(float32(value.Value))
+		}
+		return encoder.EncodeFloat64(value.Value)
+	case *ntgo.ValueString:
+		return encoder.EncodeString(value.Value)
+	case *ntgo.ValueRaw:
+		return encoder.EncodeBytes(value.Value)
+	case *ntgo.ValueBooleanArray:
+		elements := value.Values()
+		encoder.EncodeArrayLen(len(elements))
+		for _, element := range elements {
+			encoder.EncodeBool(element)
+		}
+		return nil
+	case *ntgo.ValueDoubleArray:
+		elements := value.Values()
+		encoder.EncodeArrayLen(len(elements))
+		for _, element := range elements {
+			switch code {
+			case TypeIntArray:
+				encoder.EncodeInt(int64(element))
+			case TypeFloatArray:
+				encoder.EncodeFloat32(float32(element))
+			default:
+				encoder.EncodeFloat64(element)
+			}
+		}
+		return nil
+	case *ntgo.ValueStringArray:
+		elements := value.Values()
+		encoder.EncodeArrayLen(len(elements))
+		for _, element := range elements {
+			encoder.EncodeString(element)
+		}
+		return nil
+	}
+	return ErrTypeUnsupported
+}
+
+func decodeValue(decoder *msgpack.Decoder, code int) (ntgo.EntryValue, error) {
+	switch code {
+	case TypeBoolean:
+		value, decodeErr := decoder.DecodeBool()
+		return ntgo.BuildBoolean(value), decodeErr
+	case TypeDouble, TypeInt, TypeFloat:
+		value, decodeErr := decoder.DecodeFloat64()
+		return ntgo.BuildDouble(value), decodeErr
+	case TypeString:
+		value, decodeErr := decoder.DecodeString()
+		return ntgo.BuildString(value), decodeErr
+	case TypeRaw:
+		value, decodeErr := decoder.DecodeBytes()
+		return ntgo.BuildRaw(value), decodeErr
+	case TypeBooleanArray, TypeDoubleArray, TypeIntArray, TypeFloatArray, TypeStringArray:
+		length, lengthErr := decoder.DecodeArrayLen()
+		if lengthErr != nil {
+			return nil, lengthErr
+		}
+		return decodeArray(decoder, code, max(length, 0))
+	}
+	return nil, ErrTypeUnsupported
+}
+
+func decodeArray(decoder *msgpack.Decoder, code int, length int) (ntgo.EntryValue, error) {
+	if length > ntgo.MaxArrayLength {
+		// Refused before the length, which the peer picks, is allocated.
+		return nil, ntgo.ErrArrayOutOfSpace
+	}
+	switch code {
+	case TypeBooleanArray:
+		values := make([]bool, length)
+		for i := range values {
+			value, decodeErr := decoder.DecodeBool()
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			values[i] = value
+		}
+		return ntgo.BuildBooleanArrayFrom(values)
+	case TypeStringArray:
+		values := make([]string, length)
+		for i := range values {
+			value, decodeErr := decoder.DecodeString()
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			values[i] = value
+		}
+		return ntgo.BuildStringArrayFrom(values)
+	}
+	values := make([]float64, length)
+	for i := range values {
+		value, decodeErr := decoder.DecodeFloat64()
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		values[i] = value
+	}
+	return ntgo.BuildDoubleArrayFrom(values)
+}
+
+// typeCode returns the binary type code of a topic type name.
+func typeCode(typeName string) (int, bool) {
+	switch typeName {
+	case "boolean":
+		return TypeBoolean, true
+	case "double":
+		return TypeDouble, true
+	case "int":
+		return TypeInt, true
+	case "float":
+		return TypeFloat, true
+	case "string", "json":
+		return TypeString, true
+	case "raw", "rpc", "msgpack", "protobuf":
+		return TypeRaw, true
+	case "boolean[]":
+		return TypeBooleanArray, true
+	case "double[]":
+		return TypeDoubleArray, true
+	case "int[]":
+		return TypeIntArray, true
+	case "float[]":
+		return TypeFloatArray, true
+	case "string[]":
+		return TypeStringArray, true
+	}
+	return 0, false
+}
+
+// entryType returns the table entry type a topic type is stored as.
+func entryType(typeName string) (ntgo.EntryType, bool) {
+	code, ok := typeCode(typeName)
+	if !ok {
+		return ntgo.EntryTypeUndef, false
+	}
+	switch code {
+	case TypeBoolean:
+		return ntgo.EntryTypeBoolean, true
+	case TypeDouble, TypeInt, TypeFloat:
+		return ntgo.EntryTypeDouble, true
+	case TypeString:
+		return ntgo.EntryTypeString, true
+	case TypeRaw:
+		return ntgo.EntryTypeRawData, true
+	case TypeBooleanArray:
+		return ntgo.EntryTypeBooleanArr, true
+	case TypeDoubleArray, TypeIntArray, TypeFloatArray:
+		return ntgo.EntryTypeDoubleArr, true
+	}
+	return ntgo.EntryTypeStringArr, true
+}
+
+// typeName returns the topic type a table entry type is published as.
+func typeName(entryType ntgo.EntryType) (string, bool) {
+	switch entryType {
+	case ntgo.EntryTypeRPCDef, ntgo.EntryTypeUndef:
+		return "", false
+	}
+	return entryType.String(), true
+}
+
+// persistent reads the persistent property out of properties.
+func persistent(properties map[string]any) (bool, bool) {
+	value, ok := properties["persistent"]
+	if !ok {
+		return false, false
+	}
+	flag, _ := value.(bool)
+	return flag, true
+}
+
+// flagsProperties returns the properties matching entry flags.
+func flagsProperties(flags ntgo.EntryFlag) map[string]any {
+	return map[string]any{"persistent": flags&ntgo.EntryFlagPersistent != 0}
+}
+
+// setFlags brings the persistent flag of a table entry in line with
+// properties, if they mention it.
+func setFlags(table *ntgo.Table, name string, properties map[string]any) {
+	flag, ok := persistent(properties)
+	if !ok {
+		return
+	}
+	entry, exists := table.Get(name)
+	if !exists {
+		return
+	}
+	flags := entry.Flags &^ ntgo.EntryFlagPersistent
+	if flag {
+		flags |= ntgo.EntryFlagPersistent
+	}
+	if flags != entry.Flags {
+		table.SetFlags(name, flags, false)
+	}
+}
+
+// storeValue puts a value received for a topic into table. A value of a
+// different type replaces the entry.
+func storeValue(table *ntgo.Table, name string, value ntgo.EntryValue, properties map[string]any) {
+	valueType := ntgo.EntryValueType(value)
+	if _, _, setErr := table.Set(name, valueType, value, false); setErr == ntgo.ErrEntryTypeMismatch {
+		table.Delete(name, false)
+		table.Set(name, valueType, value, false)
+	}
+	setFlags(table, name, properties)
+}
@@ -0,0 +1,353 @@
+package nt4
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+	"github.com/gorilla/websocket"
+)
+
+func startServer(t *testing.T) *ntgo.NetworkTables {
+	nt := &ntgo.NetworkTables{
+		NewOperator: NewServer,
+		Listen:      []string{"127.0.0.1:0"},
+		UpdateRate:  5 * time.Millisecond,
+	}
+	if err := nt.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { nt.Close() })
+	return nt
+}
+
+func connectClient(t *testing.T, address string, client *Client) *ntgo.NetworkTables {
+	host, port, _ := net.SplitHostPort(address)
+	nt := &ntgo.NetworkTables{
+		Address:     host,
+		Port:        port,
+		Identity:    "test",
+		NewOperator: func() ntgo.Operator { return client },
+	}
+	if err := nt.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { nt.Close() })
+	return nt
+}
+
+func serverAddress(srv *ntgo.NetworkTables) string {
+	return srv.Operator.(*Server).ListenAddrs()[0].String()
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	doubles, _ := ntgo.BuildDoubleArrayFrom([]float64{1.5, -2})
+	ints, _ := ntgo.BuildDoubleArrayFrom([]float64{3, 4})
+	booleans, _ := ntgo.BuildBooleanArrayFrom([]bool{true, false})
+	strings, _ := ntgo.BuildStringArrayFrom([]string{"a", "b"})
+	messages := []valueMessage{
+		{id: 1, timestamp: 10, code: TypeBoolean, value: ntgo.BuildBoolean(true)},
+		{id: 2, timestamp: 20, code: TypeDouble, value: ntgo.BuildDouble(0.25)},
+		{id: 3, timestamp: 30, code: TypeInt, value: ntgo.BuildDouble(42)},
+		{id: 4, timestamp: 40, code: TypeFloat, value: ntgo.BuildDouble(0.5)},
+		{id: 5, timestamp: 50, code: TypeString, value: ntgo.BuildString("hello")},
+		{id: 6, timestamp: 60, code: TypeRaw, value: ntgo.BuildRaw([]byte{0x00, 0xFF})},
+		{id: 7, timestamp: 70, code: TypeDoubleArray, value: doubles},
+		{id: 8, timestamp: 80, code: TypeIntArray, value: ints},
+		{id: 9, timestamp: 90, code: TypeBooleanArray, value: booleans},
+		{id: 10, timestamp: 100, code: TypeStringArray, value: strings},
+		{id: timeSyncID, timestamp: 110, code: TypeInt, clientTime: 12345},
+	}
+	frame, err := encodeFrame(messages...)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	decoded, err := decodeFrame(frame)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if len(decoded) != len(messages) {
+		t.Fatalf("Expected %d messages but got %d", len(messages), len(decoded))
+	}
+	for i, message := range messages {
+		if !reflect.DeepEqual(decoded[i], message) {
+			t.Fatalf("Expected %+v but got %+v", message, decoded[i])
+		}
+	}
+}
+
+func TestFrameHugeArray(t *testing.T) {
+	// A double array claiming 2^28 and then 2^32-1 elements, with none of
+	// them sent; neither may be allocated.
+	frames := [][]byte{
+		{0x94, 0x01, 0x00, 0x11, 0xdd, 0x10, 0x00, 0x00, 0x00},
+		{0x94, 0x01, 0x00, 0x11, 0xdd, 0xff, 0xff, 0xff, 0xff},
+	}
+	for _, frame := range frames {
+		if _, err := decodeFrame(frame); err != ntgo.ErrArrayOutOfSpace {
+			t.Fatalf("Expected error \"%s\" but received \"%s\"", ntgo.ErrArrayOutOfSpace, err)
+		}
+	}
+}
+
+func TestTypeNames(t *testing.T) {
+	cases := map[string]ntgo.EntryType{
+		"int":       ntgo.EntryTypeDouble,
+		"float[]":   ntgo.EntryTypeDoubleArr,
+		"json":      ntgo.EntryTypeString,
+		"msgpack":   ntgo.EntryTypeRawData,
+		"boolean[]": ntgo.EntryTypeBooleanArr,
+	}
+	for name, expected := range cases {
+		if got, ok := entryType(name); !ok || got != expected {
+			t.Fatalf("Expected %s to be stored as %s but got %s", name, expected, got)
+		}
+	}
+	if _, ok := entryType("struct:Pose2d"); ok {
+		t.Fatal("Expected unknown topic type to be rejected")
+	}
+	if _, ok := typeName(ntgo.EntryTypeRPCDef); ok {
+		t.Fatal("Expected RPC entries not to be published")
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	srv := startServer(t)
+	srv.Double("/server/value").Set(1)
+	cl := connectClient(t, serverAddress(srv), &Client{})
+	waitFor(t, "server value to reach the client", func() bool {
+		return cl.Double("/server/value").GetOr(0) == 1
+	})
+	client := cl.Operator.(*Client)
+	if timestamp, ok := client.Timestamp("/server/value"); !ok || timestamp <= 0 {
+		t.Fatalf("Expected a server timestamp but got %d", timestamp)
+	}
+	waitFor(t, "time sync", func() bool { return client.ServerTime() > 0 })
+
+	cl.String("/client/name").Set("robot")
+	waitFor(t, "client value to reach the server", func() bool {
+		return srv.String("/client/name").GetOr("") == "robot"
+	})
+	srv.Double("/server/value").Set(2)
+	waitFor(t, "server update to reach the client", func() bool {
+		return cl.Double("/server/value").GetOr(0) == 2
+	})
+
+	if err := cl.SetFlags("/client/name", ntgo.EntryFlagPersistent); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "persistent flag to reach the server", func() bool {
+		entry, _ := srv.Table().Get("/client/name")
+		return entry.Flags&ntgo.EntryFlagPersistent != 0
+	})
+
+	if _, err := cl.CallRPC(t.Context(), "/missing"); err != ntgo.ErrEntryNotFound {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ntgo.ErrEntryNotFound, err)
+	}
+	if _, err := client.CallRPC(t.Context(), [2]byte{}, nil); err != ErrRPCUnsupported {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrRPCUnsupported, err)
+	}
+}
+
+func TestClientsRelay(t *testing.T) {
+	srv := startServer(t)
+	first := connectClient(t, serverAddress(srv), &Client{})
+	second := connectClient(t, serverAddress(srv), &Client{Prefixes: []string{"/shared/"}})
+	first.Boolean("/shared/ready").Set(true)
+	first.Boolean("/private/ready").Set(true)
+	waitFor(t, "value to be relayed", func() bool {
+		return second.Boolean("/shared/ready").GetOr(false)
+	})
+	waitFor(t, "value to reach the server", func() bool {
+		return srv.Boolean("/private/ready").GetOr(false)
+	})
+	if _, ok := second.Table().Get("/private/ready"); ok {
+		t.Fatal("Expected topic outside the prefix not to be mirrored")
+	}
+
+	// Unpublished temporary topics disappear everywhere.
+	if err := first.Delete("/shared/ready"); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "topic to be dropped", func() bool {
+		_, onServer := srv.Table().Get("/shared/ready")
+		_, onClient := second.Table().Get("/shared/ready")
+		return !onServer && !onClient
+	})
+}
+
+func TestPublishExplicitType(t *testing.T) {
+	srv := startServer(t)
+	publisher := &Client{}
+	connectClient(t, serverAddress(srv), publisher)
+	if err := publisher.Publish("/counts/cycles", "int", nil); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := publisher.Publish("/counts/bad", "struct:Pose2d", nil); err != ErrTypeUnsupported {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrTypeUnsupported, err)
+	}
+	publisher.UpdateEntry(ntgo.Entry{Name: ntgo.BuildString("/counts/cycles"), Type: ntgo.EntryTypeDouble, Value: ntgo.BuildDouble(7)})
+	watcher := &Client{}
+	connectClient(t, serverAddress(srv), watcher)
+	waitFor(t, "int topic to be announced", func() bool {
+		topic, ok := watcher.Topic("/counts/cycles")
+		return ok && topic.Type == "int"
+	})
+	waitFor(t, "int value to be read as a double", func() bool {
+		return srv.Double("/counts/cycles").GetOr(0) == 7
+	})
+}
+
+func TestTopicsOnly(t *testing.T) {
+	srv := startServer(t)
+	srv.Double("/sensors/range").Set(3)
+	watcher := &Client{Options: SubscribeOptions{TopicsOnly: true}}
+	cl := connectClient(t, serverAddress(srv), watcher)
+	waitFor(t, "topic to be announced", func() bool {
+		_, ok := watcher.Topic("/sensors/range")
+		return ok
+	})
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := cl.Table().Get("/sensors/range"); ok {
+		t.Fatal("Expected no value for a topics only subscription")
+	}
+	subuid, err := watcher.Subscribe([]string{"/sensors/range"}, SubscribeOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	srv.Double("/sensors/range").Set(4)
+	waitFor(t, "subscribed value", func() bool {
+		return cl.Double("/sensors/range").GetOr(0) == 4
+	})
+	if err := watcher.Unsubscribe(subuid); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+}
+
+func TestMountedHandler(t *testing.T) {
+	srv := &ntgo.NetworkTables{NewOperator: func() ntgo.Operator { return &Server{Detached: true} }, UpdateRate: 5 * time.Millisecond}
+	if err := srv.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer srv.Close()
+	srv.String("/mode").Set("teleop")
+	httpServer := httptest.NewServer(srv.Operator.(*Server))
+	defer httpServer.Close()
+	address, _ := url.Parse(httpServer.URL)
+	cl := connectClient(t, address.Host, &Client{})
+	waitFor(t, "value through the mounted handler", func() bool {
+		return cl.String("/mode").GetOr("") == "teleop"
+	})
+}
+
+func TestClientReconnects(t *testing.T) {
+	srv := startServer(t)
+	address := serverAddress(srv)
+	cl := connectClient(t, address, &Client{})
+	cl.Double("/client/value").Set(5)
+	waitFor(t, "value to reach the server", func() bool {
+		return srv.Double("/client/value").GetOr(0) == 5
+	})
+	srv.Close()
+	waitFor(t, "client topic to be dropped", func() bool {
+		_, ok := srv.Table().Get("/client/value")
+		return !ok
+	})
+
+	restarted := &ntgo.NetworkTables{NewOperator: NewServer, Listen: []string{address}, UpdateRate: 5 * time.Millisecond}
+	if err := restarted.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer restarted.Close()
+	waitFor(t, "client to republish", func() bool {
+		return restarted.Double("/client/value").GetOr(0) == 5
+	})
+}
+
+func TestBridge(t *testing.T) {
+	nt3 := &ntgo.NetworkTables{Mode: ntgo.ModeServer, Listen: []string{"127.0.0.1:0"}, UpdateRate: 5 * time.Millisecond}
+	if err := nt3.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer nt3.Close()
+	srv := startServer(t)
+	bridge := ntgo.NewBridge(nt3, srv)
+	defer bridge.Close()
+	host, port, _ := net.SplitHostPort(nt3.ListenAddrs()[0].String())
+	robot := &ntgo.NetworkTables{Mode: ntgo.ModeClient, Address: host, Port: port, UpdateRate: 5 * time.Millisecond}
+	if err := robot.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer robot.Close()
+	dashboard := connectClient(t, serverAddress(srv), &Client{})
+
+	robot.Double("/robot/speed").Set(2)
+	waitFor(t, "3.0 value to reach the 4.0 client", func() bool {
+		return dashboard.Double("/robot/speed").GetOr(0) == 2
+	})
+	dashboard.String("/dashboard/auto").Set("left")
+	waitFor(t, "4.0 value to reach the 3.0 client", func() bool {
+		return robot.String("/dashboard/auto").GetOr("") == "left"
+	})
+	if err := dashboard.SetFlags("/dashboard/auto", ntgo.EntryFlagPersistent); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "persistence to reach the 3.0 client", func() bool {
+		entry, _ := robot.Table().Get("/dashboard/auto")
+		return entry.Flags == ntgo.EntryFlagPersistent
+	})
+	if err := robot.Delete("/robot/speed"); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "delete to reach the 4.0 client", func() bool {
+		_, exists := dashboard.Table().Get("/robot/speed")
+		return !exists
+	})
+}
+
+func TestStalledClient(t *testing.T) {
+	srv := startServer(t)
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol}}
+	conn, _, err := dialer.Dial("ws://"+serverAddress(srv)+"/nt/stalled", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+	subscribe := `[{"method": "subscribe", "params": {"topics": [""], "subuid": 1, "options": {"prefix": true}}}]`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(subscribe)); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	// The client never reads, so its socket fills up. Setting values and
+	// announcing topics must carry on regardless.
+	big := strings.Repeat("x", 1<<20)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 64; i++ {
+			srv.String(fmt.Sprintf("/big/%d", i)).Set(big)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected setting values not to wait for a stalled client")
+	}
+}
@@ -0,0 +1,721 @@
+package nt4
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HowardStark/ntgo"
+	"github.com/gorilla/websocket"
+)
+
+// minPeriod bounds how often values are sent to any subscriber.
+const minPeriod = 5 * time.Millisecond
+
+// Server is a NetworkTables 4.0 server. Every entry set locally is
+// published to the clients, and every value a client publishes is stored in
+// the table. A topic lives while someone publishes it or while it is
+// persistent.
+type Server struct {
+	// Detached, when set, stops Initialize from listening; the Server is
+	// then only reached by mounting it as an http.Handler.
+	Detached bool
+
+	table   *ntgo.Table
+	log     *log.Logger
+	start   time.Time
+	period  time.Duration
+	timeout time.Duration
+
+	mu        sync.Mutex
+	topics    map[string]*serverTopic
+	nextID    int64
+	clients   map[*serverClient]bool
+	names     map[string]bool
+	listeners []net.Listener
+	http      *http.Server
+	upgrader  websocket.Upgrader
+	closed    bool
+}
+
+type serverTopic struct {
+	id         int64
+	name       string
+	typeName   string
+	code       int
+	properties map[string]any
+	publishers map[*serverClient]int64
+	local      bool
+	value      ntgo.EntryValue
+	timestamp  int64
+}
+
+type serverClient struct {
+	server *Server
+	name   string
+	conn   *websocket.Conn
+
+	// Frames are queued for writeLoop, so that a slow client never holds
+	// up the server while server.mu is held.
+	writeMu  sync.Mutex
+	frames   []outgoingFrame
+	wake     chan struct{}
+	writeErr bool
+
+	// The fields below are guarded by server.mu.
+	publications  map[int64]*serverTopic
+	subscriptions map[int64]subscribeParams
+	announced     map[int64]bool
+	pending       []valueMessage
+	latest        map[int64]int
+}
+
+type outgoingFrame struct {
+	frameType int
+	data      []byte
+}
+
+// NewServer returns a Server, for use as NetworkTables.NewOperator.
+func NewServer() ntgo.Operator {
+	return &Server{}
+}
+
+// Initialize publishes the entries already in the table and listens on
+// nt.Listen, or on nt.Address and nt.Port, DefaultPort when empty, unless
+// the Server is Detached. nt.TLS switches to secure WebSockets.
+func (srv *Server) Initialize(nt ntgo.NetworkTables) error {
+	srv.table = nt.Table()
+	srv.log = nt.Logger
+	if srv.log == nil {
+		srv.log = log.New(io.Discard, "", 0)
+	}
+	srv.start = time.Now()
+	srv.period = nt.UpdateRate
+	if srv.period <= 0 {
+		srv.period = ntgo.DefaultUpdateRate
+	}
+	srv.timeout = nt.Timeout
+	if srv.timeout <= 0 {
+		srv.timeout = ntgo.DefaultTimeout
+	}
+	srv.topics = map[string]*serverTopic{}
+	srv.clients = map[*serverClient]bool{}
+	srv.names = map[string]bool{}
+	srv.upgrader = websocket.Upgrader{
+		Subprotocols: []string{Subprotocol, SubprotocolV40},
+		CheckOrigin:  func(*http.Request) bool { return true },
+	}
+	for _, entry := range srv.table.Entries("") {
+		srv.UpdateEntry(entry)
+	}
+	if srv.Detached {
+		return nil
+	}
+	addresses := nt.Listen
+	if len(addresses) == 0 {
+		port := nt.Port
+		if port == "" {
+			port = DefaultPort
+		}
+		addresses = []string{net.JoinHostPort(nt.Address, port)}
+	}
+	for _, address := range addresses {
+		listener, listenErr := net.Listen("tcp", address)
+		if listenErr != nil {
+			for _, opened := range srv.listeners {
+				opened.Close()
+			}
+			return listenErr
+		}
+		if nt.TLS != nil {
+			listener = tls.NewListener(listener, nt.TLS)
+		}
+		srv.listeners = append(srv.listeners, listener)
+	}
+	srv.http = &http.Server{Handler: srv}
+	for _, listener := range srv.listeners {
+		go srv.http.Serve(listener)
+	}
+	return nil
+}
+
+// ListenAddrs returns the addresses the Server listens on.
+func (srv *Server) ListenAddrs() []net.Addr {
+	addrs := []net.Addr{}
+	for _, listener := range srv.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+// now is the server clock: microseconds since Initialize.
+func (srv *Server) now() int64 {
+	return time.Since(srv.start).Microseconds()
+}
+
+// ServeHTTP accepts clients on /nt/<name>.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	name, ok := strings.CutPrefix(request.URL.Path, "/nt/")
+	if !ok {
+		http.NotFound(w, request)
+		return
+	}
+	conn, upgradeErr := srv.upgrader.Upgrade(w, request, nil)
+	if upgradeErr != nil {
+		return
+	}
+	conn.SetReadLimit(readLimit)
+	if conn.Subprotocol() == "" {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported subprotocol"), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	sc := &serverClient{
+		server:        srv,
+		conn:          conn,
+		publications:  map[int64]*serverTopic{},
+		subscriptions: map[int64]subscribeParams{},
+		announced:     map[int64]bool{},
+		latest:        map[int64]int{},
+		wake:          make(chan struct{}, 1),
+	}
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		conn.Close()
+		return
+	}
+	// Names are unique; a second client with the same name gets a suffix.
+	sc.name = name
+	for i := 1; srv.names[sc.name]; i++ {
+		sc.name = fmt.Sprintf("%s@%d", name, i)
+	}
+	srv.names[sc.name] = true
+	srv.clients[sc] = true
+	srv.mu.Unlock()
+	srv.log.Printf("nt4: %s connected from %s", sc.name, request.RemoteAddr)
+
+	done := make(chan struct{})
+	go sc.flushLoop(done)
+	go sc.writeLoop(done)
+	sc.readLoop()
+	close(done)
+	conn.Close()
+	srv.drop(sc)
+	srv.log.Printf("nt4: %s disconnected", sc.name)
+}
+
+// drop forgets a client and everything it published.
+func (srv *Server) drop(sc *serverClient) {
+	srv.mu.Lock()
+	delete(srv.clients, sc)
+	delete(srv.names, sc.name)
+	removed := []string{}
+	for pubuid := range sc.publications {
+		if name, ok := srv.unpublish(sc, pubuid); ok {
+			removed = append(removed, name)
+		}
+	}
+	srv.mu.Unlock()
+	for _, name := range removed {
+		srv.table.Delete(name, false)
+	}
+}
+
+func (sc *serverClient) readLoop() {
+	for {
+		frameType, data, readErr := sc.conn.ReadMessage()
+		if readErr != nil {
+			return
+		}
+		if frameType == websocket.TextMessage {
+			messages := []textMessage{}
+			if jsonErr := json.Unmarshal(data, &messages); jsonErr != nil {
+				sc.server.log.Printf("nt4: dropping text frame from %s: %s", sc.name, jsonErr)
+				continue
+			}
+			for _, message := range messages {
+				sc.handleText(message)
+			}
+			continue
+		}
+		messages, decodeErr := decodeFrame(data)
+		if decodeErr != nil {
+			sc.server.log.Printf("nt4: dropping binary frame from %s: %s", sc.name, decodeErr)
+			continue
+		}
+		for _, message := range messages {
+			sc.handleValue(message)
+		}
+	}
+}
+
+func (sc *serverClient) handleText(message textMessage) {
+	srv := sc.server
+	switch message.Method {
+	case "publish":
+		params := publishParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		if _, ok := typeCode(params.Type); !ok {
+			srv.log.Printf("nt4: %s published %s with unsupported type %q", sc.name, params.Name, params.Type)
+			return
+		}
+		srv.mu.Lock()
+		topic := srv.topic(params.Name, params.Type, params.Properties)
+		if topic.typeName != params.Type {
+			srv.log.Printf("nt4: %s published %s as %s, but it is %s", sc.name, params.Name, params.Type, topic.typeName)
+		}
+		topic.publishers[sc] = params.PubUID
+		sc.publications[params.PubUID] = topic
+		srv.announceAll(topic)
+		sc.announce(topic, &params.PubUID)
+		properties := maps.Clone(topic.properties)
+		srv.mu.Unlock()
+		setFlags(srv.table, params.Name, properties)
+	case "unpublish":
+		params := unpublishParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		srv.mu.Lock()
+		name, removed := srv.unpublish(sc, params.PubUID)
+		srv.mu.Unlock()
+		if removed {
+			srv.table.Delete(name, false)
+		}
+	case "setproperties":
+		params := setPropertiesParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		srv.setProperties(params.Name, params.Update)
+	case "subscribe":
+		params := subscribeParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		srv.mu.Lock()
+		sc.subscriptions[params.SubUID] = params
+		for _, topic := range srv.topics {
+			if !params.matches(topic.name) {
+				continue
+			}
+			sc.announce(topic, nil)
+			if !params.Options.TopicsOnly && topic.value != nil {
+				sc.queue(topic, valueMessage{id: topic.id, timestamp: topic.timestamp, code: topic.code, value: topic.value})
+			}
+		}
+		srv.mu.Unlock()
+	case "unsubscribe":
+		params := unsubscribeParams{}
+		if json.Unmarshal(message.Params, &params) != nil {
+			return
+		}
+		srv.mu.Lock()
+		delete(sc.subscriptions, params.SubUID)
+		srv.mu.Unlock()
+	}
+}
+
+func (sc *serverClient) handleValue(message valueMessage) {
+	srv := sc.server
+	if message.id == timeSyncID {
+		frame, encodeErr := encodeFrame(valueMessage{id: timeSyncID, timestamp: srv.now(), code: TypeInt, clientTime: message.clientTime})
+		if encodeErr == nil {
+			sc.write(websocket.BinaryMessage, frame)
+		}
+		return
+	}
+	srv.mu.Lock()
+	topic, ok := sc.publications[message.id]
+	if !ok || topic.code != message.code {
+		srv.mu.Unlock()
+		return
+	}
+	if message.timestamp == 0 {
+		message.timestamp = srv.now()
+	}
+	srv.setValue(topic, message.value, message.timestamp, sc)
+	name, properties := topic.name, maps.Clone(topic.properties)
+	srv.mu.Unlock()
+	storeValue(srv.table, name, message.value, properties)
+}
+
+// topic returns the named topic, creating it if needed. The caller holds
+// srv.mu.
+func (srv *Server) topic(name string, typeName string, properties map[string]any) *serverTopic {
+	if topic, ok := srv.topics[name]; ok {
+		return topic
+	}
+	code, _ := typeCode(typeName)
+	srv.nextID++
+	topic := &serverTopic{
+		id:         srv.nextID,
+		name:       name,
+		typeName:   typeName,
+		code:       code,
+		properties: map[string]any{},
+		publishers: map[*serverClient]int64{},
+	}
+	mergeProperties(topic.properties, properties)
+	srv.topics[name] = topic
+	return topic
+}
+
+// setValue stores a value in topic and queues it for every subscriber
+// except from. The caller holds srv.mu.
+func (srv *Server) setValue(topic *serverTopic, value ntgo.EntryValue, timestamp int64, from *serverClient) {
+	topic.value, topic.timestamp = value, timestamp
+	message := valueMessage{id: topic.id, timestamp: timestamp, code: topic.code, value: value}
+	for sc := range srv.clients {
+		if sc != from && sc.wantsValues(topic.name) {
+			sc.announce(topic, nil)
+			sc.queue(topic, message)
+		}
+	}
+}
+
+// unpublish removes a publisher, dropping the topic if it was the last one.
+// It returns the name of a dropped topic, which the caller deletes from the
+// table once it no longer holds srv.mu.
+func (srv *Server) unpublish(sc *serverClient, pubuid int64) (string, bool) {
+	topic, ok := sc.publications[pubuid]
+	if !ok {
+		return "", false
+	}
+	delete(sc.publications, pubuid)
+	delete(topic.publishers, sc)
+	if len(topic.publishers) > 0 || topic.local {
+		return "", false
+	}
+	if flag, _ := persistent(topic.properties); flag {
+		return "", false
+	}
+	srv.remove(topic)
+	return topic.name, true
+}
+
+// remove drops topic and tells the clients that knew it. The caller holds
+// srv.mu.
+func (srv *Server) remove(topic *serverTopic) {
+	delete(srv.topics, topic.name)
+	for sc := range srv.clients {
+		if !sc.announced[topic.id] {
+			continue
+		}
+		delete(sc.announced, topic.id)
+		sc.unqueue(topic.id)
+		sc.writeText("unannounce", unannounceParams{Name: topic.name, ID: topic.id})
+	}
+}
+
+// announceAll announces topic to every client subscribed to it. The caller
+// holds srv.mu.
+func (srv *Server) announceAll(topic *serverTopic) {
+	for sc := range srv.clients {
+		if sc.subscribed(topic.name) {
+			sc.announce(topic, nil)
+		}
+	}
+}
+
+func (srv *Server) setProperties(name string, update map[string]any) {
+	srv.mu.Lock()
+	topic, ok := srv.topics[name]
+	if !ok {
+		srv.mu.Unlock()
+		return
+	}
+	mergeProperties(topic.properties, update)
+	for sc := range srv.clients {
+		if sc.announced[topic.id] {
+			sc.writeText("properties", propertiesParams{Name: name, Update: update})
+		}
+	}
+	flag, _ := persistent(topic.properties)
+	removed := !flag && len(topic.publishers) == 0 && !topic.local
+	if removed {
+		srv.remove(topic)
+	}
+	srv.mu.Unlock()
+	if removed {
+		srv.table.Delete(name, false)
+		return
+	}
+	setFlags(srv.table, name, update)
+}
+
+// announce tells the client about topic once. pubuid is set when the client
+// is the publisher, which always gets an announcement. The caller holds
+// server.mu.
+func (sc *serverClient) announce(topic *serverTopic, pubuid *int64) {
+	if sc.announced[topic.id] && pubuid == nil {
+		return
+	}
+	sc.announced[topic.id] = true
+	sc.writeText("announce", announceParams{
+		Name:       topic.name,
+		ID:         topic.id,
+		Type:       topic.typeName,
+		PubUID:     pubuid,
+		Properties: topic.properties,
+	})
+}
+
+// subscribed reports whether any subscription covers name.
+func (sc *serverClient) subscribed(name string) bool {
+	for _, params := range sc.subscriptions {
+		if params.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// wantsValues reports whether a subscription asks for the values of name.
+func (sc *serverClient) wantsValues(name string) bool {
+	for _, params := range sc.subscriptions {
+		if !params.Options.TopicsOnly && params.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendsAll reports whether a subscription asks for every value of name
+// rather than the latest one.
+func (sc *serverClient) sendsAll(name string) bool {
+	for _, params := range sc.subscriptions {
+		if params.Options.All && params.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// queue adds a value to the next frame, replacing an older value of the same
+// topic unless every value was asked for. The caller holds server.mu.
+func (sc *serverClient) queue(topic *serverTopic, message valueMessage) {
+	if i, ok := sc.latest[topic.id]; ok && !sc.sendsAll(topic.name) {
+		sc.pending[i] = message
+		return
+	}
+	sc.latest[topic.id] = len(sc.pending)
+	sc.pending = append(sc.pending, message)
+}
+
+// unqueue drops the pending values of a topic. The caller holds server.mu.
+func (sc *serverClient) unqueue(id int64) {
+	if _, ok := sc.latest[id]; !ok {
+		return
+	}
+	pending := sc.pending[:0]
+	clear(sc.latest)
+	for _, message := range sc.pending {
+		if message.id != id {
+			sc.latest[message.id] = len(pending)
+			pending = append(pending, message)
+		}
+	}
+	sc.pending = pending
+}
+
+// period returns the shortest period the client asked for, or the update
+// rate of the server if shorter. The caller holds server.mu.
+func (sc *serverClient) period() time.Duration {
+	period := sc.server.period
+	for _, params := range sc.subscriptions {
+		if params.Options.Periodic > 0 {
+			period = min(period, time.Duration(params.Options.Periodic*float64(time.Second)))
+		}
+	}
+	return max(period, minPeriod)
+}
+
+func (sc *serverClient) flushLoop(done chan struct{}) {
+	for {
+		sc.server.mu.Lock()
+		period := sc.period()
+		sc.server.mu.Unlock()
+		select {
+		case <-done:
+			return
+		case <-time.After(period):
+		}
+		sc.server.mu.Lock()
+		pending := sc.pending
+		sc.pending = nil
+		clear(sc.latest)
+		sc.server.mu.Unlock()
+		if len(pending) == 0 {
+			continue
+		}
+		frame, encodeErr := encodeFrame(pending...)
+		if encodeErr != nil {
+			sc.server.log.Printf("nt4: dropping values for %s: %s", sc.name, encodeErr)
+			continue
+		}
+		sc.write(websocket.BinaryMessage, frame)
+	}
+}
+
+func (sc *serverClient) writeText(method string, params any) {
+	frame, encodeErr := encodeText(method, params)
+	if encodeErr != nil {
+		return
+	}
+	sc.write(websocket.TextMessage, frame)
+}
+
+// write queues a frame for writeLoop. It never blocks, so it is safe to call
+// with server.mu held.
+func (sc *serverClient) write(frameType int, frame []byte) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	if sc.writeErr {
+		return
+	}
+	sc.frames = append(sc.frames, outgoingFrame{frameType, frame})
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeLoop writes queued frames in order. A client that cannot take a frame
+// within the timeout is disconnected, which ends its readLoop.
+func (sc *serverClient) writeLoop(done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-sc.wake:
+		}
+		sc.writeMu.Lock()
+		frames := sc.frames
+		sc.frames = nil
+		sc.writeMu.Unlock()
+		for _, frame := range frames {
+			sc.conn.SetWriteDeadline(time.Now().Add(sc.server.timeout))
+			if writeErr := sc.conn.WriteMessage(frame.frameType, frame.data); writeErr != nil {
+				sc.writeMu.Lock()
+				sc.writeErr, sc.frames = true, nil
+				sc.writeMu.Unlock()
+				sc.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// localTopic returns the topic a local entry is published as, replacing
+// a topic of another type. The caller holds srv.mu.
+func (srv *Server) localTopic(entry ntgo.Entry) (*serverTopic, error) {
+	name := entry.Name.Value
+	if topic, ok := srv.topics[name]; ok {
+		if existing, _ := entryType(topic.typeName); existing == entry.Type {
+			topic.local = true
+			return topic, nil
+		}
+		srv.remove(topic)
+		for sc, pubuid := range topic.publishers {
+			delete(sc.publications, pubuid)
+		}
+	}
+	typeName, ok := typeName(entry.Type)
+	if !ok {
+		return nil, ErrTypeUnsupported
+	}
+	topic := srv.topic(name, typeName, flagsProperties(entry.Flags))
+	topic.local = true
+	srv.announceAll(topic)
+	return topic, nil
+}
+
+func (srv *Server) CreateEntry(entry ntgo.Entry) error {
+	return srv.UpdateEntry(entry)
+}
+
+func (srv *Server) UpdateEntry(entry ntgo.Entry) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	topic, topicErr := srv.localTopic(entry)
+	if topicErr != nil {
+		return topicErr
+	}
+	srv.setValue(topic, entry.Value, srv.now(), nil)
+	return nil
+}
+
+func (srv *Server) UpdateEntryFlags(entry ntgo.Entry) error {
+	srv.mu.Lock()
+	topic, ok := srv.topics[entry.Name.Value]
+	if !ok {
+		srv.mu.Unlock()
+		return nil
+	}
+	update := flagsProperties(entry.Flags)
+	mergeProperties(topic.properties, update)
+	for sc := range srv.clients {
+		if sc.announced[topic.id] {
+			sc.writeText("properties", propertiesParams{Name: topic.name, Update: update})
+		}
+	}
+	srv.mu.Unlock()
+	return nil
+}
+
+// DeleteEntry drops the topic for every client, whoever publishes it.
+func (srv *Server) DeleteEntry(entry ntgo.Entry) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	topic, ok := srv.topics[entry.Name.Value]
+	if !ok {
+		return nil
+	}
+	srv.remove(topic)
+	for sc, pubuid := range topic.publishers {
+		delete(sc.publications, pubuid)
+	}
+	return nil
+}
+
+func (srv *Server) GetEntry(id [2]byte) error { return nil }
+
+func (srv *Server) CallRPC(ctx context.Context, id [2]byte, params []byte) ([]byte, error) {
+	return nil, ErrRPCUnsupported
+}
+
+// Close stops listening and disconnects every client.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	srv.closed = true
+	clients := []*serverClient{}
+	for sc := range srv.clients {
+		clients = append(clients, sc)
+	}
+	srv.mu.Unlock()
+	var closeErr error
+	if srv.http != nil {
+		closeErr = srv.http.Close()
+		if errors.Is(closeErr, http.ErrServerClosed) {
+			closeErr = nil
+		}
+	}
+	for _, sc := range clients {
+		sc.conn.Close()
+	}
+	return closeErr
+}
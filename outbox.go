@@ -0,0 +1,57 @@
+package ntgo
+
+import (
+	"io"
+	"sync"
+)
+
+// outbox buffers outgoing messages between flushes so they can be written in
+// one batch. An Entry Update for an entry that already has an update waiting
+// replaces the waiting one, so only the latest value is ever sent.
+type outbox struct {
+	mu       sync.Mutex
+	messages []*Message
+	updates  map[[2]byte]int
+}
+
+func newOutbox() *outbox {
+	return &outbox{
+		updates: map[[2]byte]int{},
+	}
+}
+
+func (box *outbox) push(message *Message) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	if update, ok := message.Data.(*MessageDataEntryUpdate); ok {
+		if index, pending := box.updates[update.Entry.ID]; pending {
+			box.messages[index] = message
+			return
+		}
+		box.updates[update.Entry.ID] = len(box.messages)
+	} else if deleted, ok := message.Data.(*MessageDataEntryDelete); ok {
+		delete(box.updates, deleted.Entry.ID)
+	}
+	box.messages = append(box.messages, message)
+}
+
+// drain returns every waiting message in the order it was pushed and empties
+// the outbox.
+func (box *outbox) drain() []*Message {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	messages := box.messages
+	box.messages = nil
+	box.updates = map[[2]byte]int{}
+	return messages
+}
+
+// sendMessages writes messages to w in a single write.
+func sendMessages(w io.Writer, messages ...*Message) error {
+	raw := []byte{}
+	for _, message := range messages {
+		raw = append(raw, message.GetRaw()...)
+	}
+	_, writeErr := w.Write(raw)
+	return writeErr
+}
@@ -0,0 +1,290 @@
+package ntgo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PersistHeader is the first line of a persistent storage file, in the same
+// format ntcore uses, so files can be shared with other implementations.
+const PersistHeader = "[NetworkTables Storage 3.0]"
+
+var (
+	ErrPersistHeaderInvalid = errors.New("persist: missing storage header")
+	ErrPersistLineInvalid   = errors.New("persist: malformed line")
+)
+
+// SavePersistent writes every persistent entry to w, one per line.
+func SavePersistent(w io.Writer, entries []Entry) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, PersistHeader)
+	for _, entry := range entries {
+		if entry.Flags&EntryFlagPersistent == 0 {
+			continue
+		}
+		line, lineErr := persistLine(entry)
+		if lineErr != nil {
+			continue
+		}
+		fmt.Fprintln(writer, line)
+	}
+	return writer.Flush()
+}
+
+// SavePersistentFile writes the persistent entries to path, replacing the
+// file atomically so a crash never leaves it half written.
+func SavePersistentFile(path string, entries []Entry) error {
+	temp, tempErr := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if tempErr != nil {
+		return tempErr
+	}
+	saveErr := SavePersistent(temp, entries)
+	closeErr := temp.Close()
+	if saveErr == nil {
+		saveErr = closeErr
+	}
+	if saveErr != nil {
+		os.Remove(temp.Name())
+		return saveErr
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// LoadPersistent reads entries written by SavePersistent. The entries are
+// marked persistent and have no ID assigned.
+func LoadPersistent(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != PersistHeader {
+		return nil, ErrPersistHeaderInvalid
+	}
+	entries := []Entry{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, parseErr := parsePersistLine(line)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// LoadPersistentFile reads entries from path. A missing file holds no
+// entries.
+func LoadPersistentFile(path string) ([]Entry, error) {
+	file, openErr := os.Open(path)
+	if os.IsNotExist(openErr) {
+		return []Entry{}, nil
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+	return LoadPersistent(file)
+}
+
+func persistLine(entry Entry) (string, error) {
+	name := quotePersist(entry.Name.Value)
+	switch value := entry.Value.(type) {
+	case *ValueBoolean:
+		return fmt.Sprintf("boolean %s=%t", name, value.Value), nil
+	case *ValueDouble:
+		return fmt.Sprintf("double %s=%s", name, strconv.FormatFloat(value.Value, 'g', -1, 64)), nil
+	case *ValueString:
+		return fmt.Sprintf("string %s=%s", name, quotePersist(value.Value)), nil
+	case *ValueRaw:
+		return fmt.Sprintf("raw %s=%s", name, base64.StdEncoding.EncodeToString(value.Value)), nil
+	case *ValueBooleanArray:
+		elements := []string{}
+		for _, element := range value.Values() {
+			elements = append(elements, strconv.FormatBool(element))
+		}
+		return fmt.Sprintf("array boolean %s=%s", name, strings.Join(elements, ",")), nil
+	case *ValueDoubleArray:
+		elements := []string{}
+		for _, element := range value.Values() {
+			elements = append(elements, strconv.FormatFloat(element, 'g', -1, 64))
+		}
+		return fmt.Sprintf("array double %s=%s", name, strings.Join(elements, ",")), nil
+	case *ValueStringArray:
+		elements := []string{}
+		for _, element := range value.Values() {
+			elements = append(elements, quotePersist(element))
+		}
+		return fmt.Sprintf("array string %s=%s", name, strings.Join(elements, ",")), nil
+	default:
+		return "", ErrEntryNoSuchType
+	}
+}
+
+func parsePersistLine(line string) (Entry, error) {
+	typeName, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Entry{}, ErrPersistLineInvalid
+	}
+	if typeName == "array" {
+		elementType, arrayRest, ok := strings.Cut(rest, " ")
+		if !ok {
+			return Entry{}, ErrPersistLineInvalid
+		}
+		typeName, rest = elementType+"[]", arrayRest
+	}
+	name, rest, nameErr := unquotePersist(rest)
+	if nameErr != nil || !strings.HasPrefix(rest, "=") {
+		return Entry{}, ErrPersistLineInvalid
+	}
+	raw := rest[1:]
+	entryType, typeErr := ParseEntryType(typeName)
+	if typeErr != nil {
+		return Entry{}, typeErr
+	}
+	var value EntryValue
+	var valueErr error
+	switch entryType {
+	case EntryTypeBoolean:
+		var parsed bool
+		parsed, valueErr = strconv.ParseBool(raw)
+		value = BuildBoolean(parsed)
+	case EntryTypeDouble:
+		var parsed float64
+		parsed, valueErr = strconv.ParseFloat(raw, 64)
+		value = BuildDouble(parsed)
+	case EntryTypeString:
+		var parsed, remaining string
+		parsed, remaining, valueErr = unquotePersist(raw)
+		if valueErr == nil && remaining != "" {
+			valueErr = ErrPersistLineInvalid
+		}
+		value = BuildString(parsed)
+	case EntryTypeRawData:
+		var parsed []byte
+		parsed, valueErr = base64.StdEncoding.DecodeString(raw)
+		value = BuildRaw(parsed)
+	case EntryTypeBooleanArr:
+		parsed := []bool{}
+		for _, element := range splitPersistArray(raw) {
+			boolean, parseErr := strconv.ParseBool(element)
+			if parseErr != nil {
+				return Entry{}, parseErr
+			}
+			parsed = append(parsed, boolean)
+		}
+		value, valueErr = BuildBooleanArrayFrom(parsed)
+	case EntryTypeDoubleArr:
+		parsed := []float64{}
+		for _, element := range splitPersistArray(raw) {
+			double, parseErr := strconv.ParseFloat(element, 64)
+			if parseErr != nil {
+				return Entry{}, parseErr
+			}
+			parsed = append(parsed, double)
+		}
+		value, valueErr = BuildDoubleArrayFrom(parsed)
+	case EntryTypeStringArr:
+		parsed := []string{}
+		for raw != "" {
+			element, remaining, parseErr := unquotePersist(raw)
+			if parseErr != nil {
+				return Entry{}, parseErr
+			}
+			parsed = append(parsed, element)
+			raw = strings.TrimPrefix(remaining, ",")
+		}
+		value, valueErr = BuildStringArrayFrom(parsed)
+	default:
+		return Entry{}, ErrEntryNoSuchType
+	}
+	if valueErr != nil {
+		return Entry{}, valueErr
+	}
+	return Entry{
+		Name:  BuildString(name),
+		Type:  entryType,
+		ID:    EntryIDUnassigned,
+		Flags: EntryFlagPersistent,
+		Value: value,
+	}, nil
+}
+
+func splitPersistArray(raw string) []string {
+	if raw == "" {
+		return []string{}
+	}
+	return strings.Split(raw, ",")
+}
+
+// quotePersist quotes s the way ntcore does: backslash, double quote,
+// newline and tab are escaped and other control bytes become \xHH.
+func quotePersist(s string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			builder.WriteString(`\\`)
+		case c == '"':
+			builder.WriteString(`\"`)
+		case c == '\n':
+			builder.WriteString(`\n`)
+		case c == '\t':
+			builder.WriteString(`\t`)
+		case c < 0x20 || c == 0x7F:
+			fmt.Fprintf(&builder, `\x%02X`, c)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// unquotePersist reads a quoted string from the start of s and returns it
+// along with whatever follows the closing quote.
+func unquotePersist(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", ErrPersistLineInvalid
+	}
+	var builder strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return builder.String(), s[i+1:], nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			case 'x':
+				if i+2 >= len(s) {
+					return "", "", ErrPersistLineInvalid
+				}
+				code, hexErr := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if hexErr != nil {
+					return "", "", ErrPersistLineInvalid
+				}
+				builder.WriteByte(byte(code))
+				i += 2
+			default:
+				builder.WriteByte(s[i])
+			}
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return "", "", ErrPersistLineInvalid
+}
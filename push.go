@@ -0,0 +1,301 @@
+package ntgo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrPushOpInvalid = errors.New("push: unknown op")
+)
+
+// PushHandler is an http.Handler that streams the entries of a
+// NetworkTables instance to browsers over a WebSocket, and takes changes
+// back. A dashboard connects with the prefixes it wants as query
+// parameters, as in ws://robot:5800/push?prefix=/SmartDashboard&prefix=/Vision;
+// with none it gets every entry.
+//
+// Each text frame the handler sends is a JSON array of events, oldest
+// first:
+//
+//	[{"event": "updated", "entry": {"name": "/SmartDashboard/speed", ...}}]
+//
+// event is created, updated, flags or deleted, and entry is the entry in
+// the canonical JSON form Entry.MarshalJSON gives it, as it was before the
+// delete for deleted. The first frame holds a created event for every entry
+// that already exists. After that, changes are batched and sent once per
+// update rate of nt, and only the latest change to an entry is sent, so a
+// busy entry costs a slow browser one event per batch rather than one per
+// change.
+//
+// The browser may send commands, one JSON object per text frame:
+//
+//	{"op": "set", "name": "/SmartDashboard/speed", "type": "double", "value": 1.5, "flags": "persistent"}
+//	{"op": "flags", "name": "/SmartDashboard/speed", "flags": "temporary"}
+//	{"op": "delete", "name": "/SmartDashboard/speed"}
+//
+// flags may be left out of a set. A command that fails is answered with
+// {"error": "...", "name": ...}; one that succeeds is answered by the events
+// it causes, if they fall under the prefixes of the socket.
+type PushHandler struct {
+	// AllowedOrigins are the origins, such as "http://localhost:3000", of
+	// pages that may connect besides those served by the same host. "*"
+	// allows every page.
+	AllowedOrigins []string
+
+	nt       *NetworkTables
+	upgrader websocket.Upgrader
+}
+
+// NewPushHandler serves the entries of nt. As the socket can change
+// entries, browsers may only connect from pages of the host serving it or
+// of one of AllowedOrigins.
+func NewPushHandler(nt *NetworkTables) *PushHandler {
+	handler := &PushHandler{nt: nt}
+	handler.upgrader.CheckOrigin = handler.checkOrigin
+	return handler
+}
+
+// checkOrigin refuses pages of other sites, which could otherwise use any
+// browser that reaches the robot to write to its table.
+func (handler *PushHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser, so not a page acting for someone else.
+		return true
+	}
+	for _, allowed := range handler.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	parsed, parseErr := url.Parse(origin)
+	return parseErr == nil && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// pushEvent is an event as it is sent to the browser.
+type pushEvent struct {
+	Event string `json:"event"`
+	Entry Entry  `json:"entry"`
+}
+
+// pushCommand is a command from the browser.
+type pushCommand struct {
+	Op string `json:"op"`
+	restValueJSON
+}
+
+var pushEventNames = map[EntryEventKind]string{
+	EntryEventCreated:      "created",
+	EntryEventUpdated:      "updated",
+	EntryEventFlagsUpdated: "flags",
+	EntryEventDeleted:      "deleted",
+}
+
+// pushSocket is a connected browser.
+type pushSocket struct {
+	nt       *NetworkTables
+	conn     *websocket.Conn
+	prefixes []string
+	outbox   *pushOutbox
+	writeMu  sync.Mutex
+}
+
+func (handler *PushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, upgradeErr := handler.upgrader.Upgrade(w, r, nil)
+	if upgradeErr != nil {
+		return
+	}
+	socket := &pushSocket{nt: handler.nt, conn: conn, prefixes: r.URL.Query()["prefix"], outbox: newPushOutbox()}
+	if len(socket.prefixes) == 0 {
+		socket.prefixes = []string{""}
+	}
+	table := handler.nt.Table()
+	// The listener is added before the snapshot is taken so that no change
+	// falls between the two; one that lands in both is coalesced away.
+	listener := table.AddListener("", func(event EntryEvent) {
+		if socket.wants(event.Entry.Name.Value) {
+			socket.outbox.push(event.Kind, event.Entry)
+		}
+	})
+	defer table.RemoveListener(listener)
+	for _, entry := range table.Entries("") {
+		if socket.wants(entry.Name.Value) {
+			socket.outbox.push(EntryEventCreated, entry)
+		}
+	}
+
+	done := make(chan struct{})
+	go socket.flushLoop(done)
+	socket.readLoop()
+	close(done)
+	conn.Close()
+}
+
+// wants reports whether name is under one of the prefixes of the socket.
+func (socket *pushSocket) wants(name string) bool {
+	for _, prefix := range socket.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (socket *pushSocket) flushLoop(done chan struct{}) {
+	ticker := time.NewTicker(socket.nt.updateRate())
+	defer ticker.Stop()
+	for {
+		if events := socket.outbox.drain(); len(events) > 0 {
+			if socket.write(events) != nil {
+				return
+			}
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (socket *pushSocket) readLoop() {
+	for {
+		frameType, data, readErr := socket.conn.ReadMessage()
+		if readErr != nil {
+			return
+		}
+		if frameType != websocket.TextMessage {
+			continue
+		}
+		command := pushCommand{}
+		if jsonErr := json.Unmarshal(data, &command); jsonErr != nil {
+			socket.writeError("", jsonErr)
+			continue
+		}
+		if commandErr := socket.handle(command); commandErr != nil {
+			socket.writeError(command.Name, commandErr)
+		}
+	}
+}
+
+func (socket *pushSocket) handle(command pushCommand) error {
+	switch command.Op {
+	case "set":
+		if command.Type == nil {
+			return ErrRESTTypeMissing
+		}
+		value, valueErr := ParseEntryValueJSON(*command.Type, command.Value)
+		if valueErr != nil {
+			return valueErr
+		}
+		if setErr := socket.nt.SetValue(command.Name, *command.Type, value); setErr != nil {
+			return setErr
+		}
+		if command.Flags != nil {
+			return socket.nt.SetFlags(command.Name, *command.Flags)
+		}
+		return nil
+	case "flags":
+		if command.Flags == nil {
+			return ErrRESTFlagsMissing
+		}
+		return socket.nt.SetFlags(command.Name, *command.Flags)
+	case "delete":
+		return socket.nt.Delete(command.Name)
+	}
+	return ErrPushOpInvalid
+}
+
+func (socket *pushSocket) writeError(name string, err error) {
+	socket.write(struct {
+		Error string `json:"error"`
+		Name  string `json:"name"`
+	}{err.Error(), name})
+}
+
+// write sends body as a text frame. A browser that cannot take it within
+// the timeout of nt is disconnected.
+func (socket *pushSocket) write(body any) error {
+	frame, encodeErr := json.Marshal(body)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	socket.writeMu.Lock()
+	defer socket.writeMu.Unlock()
+	socket.conn.SetWriteDeadline(time.Now().Add(socket.nt.timeout()))
+	if writeErr := socket.conn.WriteMessage(websocket.TextMessage, frame); writeErr != nil {
+		socket.conn.Close()
+		return writeErr
+	}
+	return nil
+}
+
+// pushOutbox buffers the events for a socket between flushes, as outbox
+// does for a connection. Entries are told apart by name rather than ID, as
+// entries made locally have no ID until the server assigns one. A change to
+// an entry that already has a change waiting is folded into it; a delete
+// drops the waiting change, and cancels out a create that was never sent.
+type pushOutbox struct {
+	mu      sync.Mutex
+	events  []*pushEvent
+	pending map[string]int
+}
+
+func newPushOutbox() *pushOutbox {
+	return &pushOutbox{pending: map[string]int{}}
+}
+
+func (box *pushOutbox) push(kind EntryEventKind, entry Entry) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	name := entry.Name.Value
+	index, waiting := box.pending[name]
+	if kind == EntryEventDeleted {
+		if waiting {
+			created := box.events[index].Event == pushEventNames[EntryEventCreated]
+			box.events[index] = nil
+			delete(box.pending, name)
+			if created {
+				return
+			}
+		}
+		box.events = append(box.events, &pushEvent{Event: pushEventNames[kind], Entry: entry})
+		return
+	}
+	if !waiting {
+		box.pending[name] = len(box.events)
+		box.events = append(box.events, &pushEvent{Event: pushEventNames[kind], Entry: entry})
+		return
+	}
+	event := box.events[index]
+	// The entry is a snapshot, so the latest one carries every change; the
+	// event keeps the most telling kind.
+	event.Entry = entry
+	if kind == EntryEventUpdated && event.Event == pushEventNames[EntryEventFlagsUpdated] {
+		event.Event = pushEventNames[kind]
+	}
+}
+
+// drain returns every waiting event in the order it was pushed and empties
+// the outbox.
+func (box *pushOutbox) drain() []*pushEvent {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	events := make([]*pushEvent, 0, len(box.events))
+	for _, event := range box.events {
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+	box.events = nil
+	box.pending = map[string]int{}
+	return events
+}
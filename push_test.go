@@ -0,0 +1,169 @@
+package ntgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func readPushFrame(t *testing.T, conn *websocket.Conn, into any) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := json.Unmarshal(frame, into); err != nil {
+		t.Fatalf("Unexpected error! %s: %s", err, frame)
+	}
+}
+
+func TestPushHandler(t *testing.T) {
+	nt := &NetworkTables{UpdateRate: 20 * time.Millisecond}
+	nt.Double("/SmartDashboard/speed").Set(1)
+	nt.Double("/other").Set(1)
+	server := httptest.NewServer(NewPushHandler(nt))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?prefix=/SmartDashboard", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+
+	events := []pushEvent{}
+	readPushFrame(t, conn, &events)
+	if len(events) != 1 || events[0].Event != "created" || events[0].Entry.Name.Value != "/SmartDashboard/speed" {
+		t.Fatalf("Expected a created event for /SmartDashboard/speed but got %v", events)
+	}
+
+	for i := 0; i < 100; i++ {
+		nt.Double("/SmartDashboard/speed").Set(float64(i))
+		nt.Double("/other").Set(float64(i))
+	}
+	nt.Double("/SmartDashboard/gone").Set(1)
+	nt.Delete("/SmartDashboard/gone")
+	// A flush may fall in the middle of the changes, so read until the last
+	// one arrives.
+	received := []pushEvent{}
+	for len(received) == 0 || received[len(received)-1].Entry.Value.(*ValueDouble).Value != 99 {
+		events = []pushEvent{}
+		readPushFrame(t, conn, &events)
+		received = append(received, events...)
+	}
+	if len(received) > 10 {
+		t.Fatalf("Expected the updates of /SmartDashboard/speed to be coalesced but got %d events", len(received))
+	}
+	for _, event := range received {
+		if event.Event != "updated" || event.Entry.Name.Value != "/SmartDashboard/speed" {
+			t.Fatalf("Expected only updates of /SmartDashboard/speed but got %v", event)
+		}
+	}
+
+	command := `{"op": "set", "name": "/SmartDashboard/mode", "type": "string", "value": "auto", "flags": "persistent"}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(command)); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	events = []pushEvent{}
+	readPushFrame(t, conn, &events)
+	if len(events) != 1 || events[0].Event != "created" || events[0].Entry.Flags != EntryFlagPersistent {
+		t.Fatalf("Expected a persistent /SmartDashboard/mode to be created but got %v", events)
+	}
+	if entry, exists := nt.Table().Get("/SmartDashboard/mode"); !exists || entry.Value.(*ValueString).Value != "auto" {
+		t.Fatal("Expected /SmartDashboard/mode to be set to auto")
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"op": "delete", "name": "/SmartDashboard/mode"}`)); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	events = []pushEvent{}
+	readPushFrame(t, conn, &events)
+	if len(events) != 1 || events[0].Event != "deleted" || events[0].Entry.Name.Value != "/SmartDashboard/mode" {
+		t.Fatalf("Expected /SmartDashboard/mode to be deleted but got %v", events)
+	}
+
+	cases := []struct {
+		command string
+		err     error
+	}{
+		{`{"op": "set", "name": "/SmartDashboard/speed", "type": "string", "value": "fast"}`, ErrEntryTypeMismatch},
+		{`{"op": "set", "name": "/SmartDashboard/speed", "value": 1}`, ErrRESTTypeMissing},
+		{`{"op": "flags", "name": "/SmartDashboard/missing", "flags": "persistent"}`, ErrEntryNotFound},
+		{`{"op": "rename", "name": "/SmartDashboard/speed"}`, ErrPushOpInvalid},
+	}
+	for _, c := range cases {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(c.command)); err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		reply := struct{ Error, Name string }{}
+		readPushFrame(t, conn, &reply)
+		if reply.Error != c.err.Error() {
+			t.Fatalf("Expected error \"%s\" but received \"%s\"", c.err, reply.Error)
+		}
+	}
+}
+
+func TestPushOutbox(t *testing.T) {
+	box := newPushOutbox()
+	entry := func(name string, value float64) Entry {
+		return Entry{Name: BuildString(name), Type: EntryTypeDouble, Value: BuildDouble(value)}
+	}
+	box.push(EntryEventUpdated, entry("/a", 1))
+	box.push(EntryEventFlagsUpdated, entry("/b", 1))
+	box.push(EntryEventCreated, entry("/c", 1))
+	box.push(EntryEventUpdated, entry("/a", 2))
+	box.push(EntryEventUpdated, entry("/b", 2))
+	box.push(EntryEventDeleted, entry("/c", 1))
+	box.push(EntryEventDeleted, entry("/a", 2))
+	box.push(EntryEventCreated, entry("/a", 3))
+
+	expected := []string{"updated /b 2", "deleted /a 2", "created /a 3"}
+	events := box.drain()
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events but got %d", len(expected), len(events))
+	}
+	for i, event := range events {
+		got := event.Event + " " + event.Entry.Name.Value + " " + event.Entry.Value.(*ValueDouble).String()
+		if got != expected[i] {
+			t.Fatalf("Expected event %d to be %q but got %q", i, expected[i], got)
+		}
+	}
+	if len(box.drain()) != 0 {
+		t.Fatal("Expected the outbox to be empty after draining")
+	}
+}
+
+func TestPushOrigin(t *testing.T) {
+	nt := &NetworkTables{}
+	handler := NewPushHandler(nt)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	address := "ws" + strings.TrimPrefix(server.URL, "http")
+	cases := []struct {
+		origin  string
+		allowed []string
+		ok      bool
+	}{
+		{"", nil, true},
+		{server.URL, nil, true},
+		{"http://evil.example", nil, false},
+		{"http://localhost:3000", []string{"http://localhost:3000"}, true},
+		{"http://evil.example", []string{"*"}, true},
+	}
+	for _, c := range cases {
+		handler.AllowedOrigins = c.allowed
+		header := http.Header{}
+		if c.origin != "" {
+			header.Set("Origin", c.origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(address, header)
+		if (err == nil) != c.ok {
+			t.Fatalf("Expected a page from %q allowed by %v to connect %t but got %v", c.origin, c.allowed, c.ok, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
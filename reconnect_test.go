@@ -0,0 +1,231 @@
+package ntgo
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// These tests pin down what happens when a client reconnects.
+//
+// A server remembers the identity of every client it has seen since it
+// started and says so in the Server Hello flags. A returning client
+// (FlagMessageClientSeen) trusts the server: announced values replace its
+// own and entries the server no longer has are dropped, except for changes
+// the client made while it was disconnected, which are sent to the server.
+// A new client (FlagMessageClientNew), typically after the server restarted,
+// publishes all of its entries again and its values win.
+
+// dropClients cuts the connection of every client of srv with identity.
+func dropClients(srv *NetworkTables, identity string) {
+	operator := srv.Operator.(*server)
+	operator.mu.Lock()
+	conns := []*serverConn{}
+	for sc := range operator.conns {
+		if sc.identity == identity {
+			conns = append(conns, sc)
+		}
+	}
+	operator.mu.Unlock()
+	for _, sc := range conns {
+		sc.close()
+	}
+}
+
+func helloFlags(t *testing.T, address, identity string) MessageFlag {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+	sendMessages(conn, BuildMessage(&MessageDataClientHello{
+		ProtocVersion: ProtocolRevision3,
+		Identity:      BuildString(identity),
+	}))
+	message, err := readMessage(conn, bufio.NewReader(conn))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	hello, ok := message.Data.(*MessageDataServerHello)
+	if !ok {
+		t.Fatalf("Expected a Server Hello but got message type %d", message.Type)
+	}
+	return hello.Flags
+}
+
+func TestServerHelloSeenFlag(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	address := srv.ListenAddrs()[0].String()
+	if flags := helloFlags(t, address, "driver"); flags != FlagMessageClientNew {
+		t.Fatalf("Expected first connection to be new but got flags %d", flags)
+	}
+	if flags := helloFlags(t, address, "driver"); flags != FlagMessageClientSeen {
+		t.Fatalf("Expected second connection to be seen but got flags %d", flags)
+	}
+	if flags := helloFlags(t, address, "coach"); flags != FlagMessageClientNew {
+		t.Fatalf("Expected other identity to be new but got flags %d", flags)
+	}
+}
+
+func TestReconnectReturningClientDefersToServer(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	returning := &NetworkTables{
+		Mode:       ModeClient,
+		Servers:    []string{srv.ListenAddrs()[0].String()},
+		Identity:   "returning",
+		UpdateRate: 5 * time.Millisecond,
+		Backoff:    Backoff{Initial: 300 * time.Millisecond},
+	}
+	if err := returning.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer returning.Close()
+	other := connectClient(t, srv, "other")
+
+	returning.Double("/shared").Set(1)
+	returning.Double("/mine").Set(1)
+	returning.Double("/doomed").Set(1)
+	returning.Double("/gone").Set(1)
+	waitFor(t, "entries to reach the server", func() bool {
+		return srv.Table().Len() == 4
+	})
+
+	dropClients(srv, "returning")
+	waitFor(t, "client to notice the disconnect", func() bool {
+		return returning.Endpoint() == ""
+	})
+	other.Double("/shared").Set(2)
+	other.Delete("/doomed")
+	returning.Double("/mine").Set(5)
+	returning.Boolean("/offline").Set(true)
+	returning.Delete("/gone")
+	waitFor(t, "other client's changes to reach the server", func() bool {
+		_, doomed := srv.Table().Get("/doomed")
+		return srv.Double("/shared").GetOr(0) == 2 && !doomed
+	})
+
+	waitFor(t, "client to reconnect", func() bool {
+		return returning.Endpoint() != ""
+	})
+	waitFor(t, "offline changes to reach the server", func() bool {
+		_, gone := srv.Table().Get("/gone")
+		return srv.Double("/mine").GetOr(0) == 5 && srv.Boolean("/offline").GetOr(false) && !gone
+	})
+	if value := returning.Double("/shared").GetOr(0); value != 2 {
+		t.Fatalf("Expected the server value 2 to win but got %g", value)
+	}
+	if _, ok := returning.Table().Get("/doomed"); ok {
+		t.Fatal("Expected the entry deleted on the server to be dropped")
+	}
+	if value := srv.Double("/shared").GetOr(0); value != 2 {
+		t.Fatalf("Expected the server to keep 2 but got %g", value)
+	}
+}
+
+func TestReconnectNewClientRepublishes(t *testing.T) {
+	address := UnixPrefix + filepath.Join(t.TempDir(), "ntgo.sock")
+	first := &NetworkTables{Mode: ModeServer, Listen: []string{address}, UpdateRate: 5 * time.Millisecond}
+	if err := first.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cl := &NetworkTables{
+		Mode:       ModeClient,
+		Servers:    []string{address},
+		Identity:   "dashboard",
+		UpdateRate: 5 * time.Millisecond,
+		Backoff:    Backoff{Initial: 20 * time.Millisecond, Max: 50 * time.Millisecond},
+	}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	cl.Double("/dashboard/setpoint").Set(1)
+	cl.Double("/shared").Set(1)
+	waitFor(t, "entries to reach the first server", func() bool {
+		return first.Table().Len() == 2
+	})
+	first.Close()
+
+	restarted := &NetworkTables{Mode: ModeServer, Listen: []string{address}, UpdateRate: 5 * time.Millisecond}
+	restarted.Double("/shared").Set(9)
+	restarted.Double("/server/only").Set(3)
+	if err := restarted.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer restarted.Close()
+	waitFor(t, "client entries to be republished", func() bool {
+		return restarted.Double("/dashboard/setpoint").GetOr(0) == 1 && restarted.Double("/shared").GetOr(0) == 1
+	})
+	if cl.Double("/server/only").GetOr(0) != 3 {
+		t.Fatal("Expected the client to take in entries only the server has")
+	}
+}
+
+// gatedDialer dials normally the first time and waits for release on every
+// later attempt. It remembers the last connection it made.
+type gatedDialer struct {
+	NetDialer
+	dials   int
+	dialing chan struct{}
+	release chan struct{}
+	last    atomic.Pointer[trackedConn]
+}
+
+type trackedConn struct {
+	io.ReadWriteCloser
+	closed atomic.Bool
+}
+
+func (conn *trackedConn) Close() error {
+	conn.closed.Store(true)
+	return conn.ReadWriteCloser.Close()
+}
+
+func (dialer *gatedDialer) Dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	dialer.dials++
+	if dialer.dials > 1 {
+		dialer.dialing <- struct{}{}
+		<-dialer.release
+	}
+	conn, err := dialer.NetDialer.Dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	tracked := &trackedConn{ReadWriteCloser: conn}
+	dialer.last.Store(tracked)
+	return tracked, nil
+}
+
+func TestReconnectCloseWhileConnecting(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	host, port, _ := net.SplitHostPort(srv.ListenAddrs()[0].String())
+	dialer := &gatedDialer{dialing: make(chan struct{}), release: make(chan struct{})}
+	cl := &NetworkTables{
+		Address:    host,
+		Port:       port,
+		Mode:       ModeClient,
+		Identity:   "robot",
+		UpdateRate: 5 * time.Millisecond,
+		Backoff:    Backoff{Initial: time.Millisecond},
+		Dialer:     dialer,
+	}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	waitFor(t, "the client to connect", func() bool { return len(srv.Connections()) == 1 })
+	first := dialer.last.Load()
+	dropClients(srv, "robot")
+	<-dialer.dialing
+	cl.Close()
+	close(dialer.release)
+	// The connection made after Close must not be kept open.
+	waitFor(t, "the new connection to be closed", func() bool {
+		last := dialer.last.Load()
+		return first.closed.Load() && last != first && last.closed.Load()
+	})
+}
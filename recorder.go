@@ -0,0 +1,202 @@
+package ntgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionReceived Direction = iota
+	DirectionSent
+)
+
+// recordingMagic starts every recording, followed by the format version.
+var recordingMagic = []byte("NTLOG\x01")
+
+// recordMaxLength bounds the message a record may hold, so that a corrupt
+// length cannot make the reader allocate without limit.
+const recordMaxLength = 16 << 20
+
+var (
+	ErrRecordingInvalid = errors.New("recording: not a recording or unsupported version")
+)
+
+// Direction says whether a recorded message was received or sent.
+type Direction byte
+
+func (direction Direction) String() string {
+	if direction == DirectionSent {
+		return "sent"
+	}
+	return "received"
+}
+
+// Record is one message in a recording. Time is measured from the start of
+// the recording on a monotonic clock. Session tells the connections of the
+// recording apart: every connection a client makes, and every client a
+// server accepts, gets a new one.
+type Record struct {
+	Time      time.Duration
+	Direction Direction
+	Session   int
+	Message   *Message
+}
+
+// Recorder writes every message a client or server sends or receives to a
+// compact binary log. Set NetworkTables.Recorder to use it. Messages are
+// stored as revision 3.0 encodes them, whatever revision the connection
+// spoke.
+//
+// A recording is a header, the magic "NTLOG", a version byte and the start
+// time as big-endian Unix nanoseconds, followed by records: the time since
+// the start in nanoseconds, the direction byte, the session and the message
+// length as unsigned varints, then the message.
+type Recorder struct {
+	mu          sync.Mutex
+	w           io.Writer
+	closer      io.Closer
+	start       time.Time
+	nextSession int
+	writeErr    error
+}
+
+// NewRecorder starts a recording on w.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	rec := &Recorder{w: w, start: time.Now()}
+	if _, writeErr := w.Write(appendRecordingHeader(nil, rec.start)); writeErr != nil {
+		return nil, writeErr
+	}
+	return rec, nil
+}
+
+// CreateRecording starts a recording in a new file at path.
+func CreateRecording(path string) (*Recorder, error) {
+	file, createErr := os.Create(path)
+	if createErr != nil {
+		return nil, createErr
+	}
+	rec, recErr := NewRecorder(file)
+	if recErr != nil {
+		file.Close()
+		return nil, recErr
+	}
+	rec.closer = file
+	return rec, nil
+}
+
+// session returns a new session number. A nil Recorder returns zero.
+func (rec *Recorder) session() int {
+	if rec == nil {
+		return 0
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.nextSession++
+	return rec.nextSession
+}
+
+// Record appends a message to the recording. A nil Recorder records
+// nothing. After a write fails, every later call returns the same error.
+func (rec *Recorder) Record(session int, direction Direction, message *Message) error {
+	if rec == nil {
+		return nil
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.writeErr != nil {
+		return rec.writeErr
+	}
+	_, rec.writeErr = rec.w.Write(appendRecord(nil, Record{
+		Time:      time.Since(rec.start),
+		Direction: direction,
+		Session:   session,
+		Message:   message,
+	}))
+	return rec.writeErr
+}
+
+func appendRecordingHeader(raw []byte, start time.Time) []byte {
+	raw = append(raw, recordingMagic...)
+	return binary.BigEndian.AppendUint64(raw, uint64(start.UnixNano()))
+}
+
+func appendRecord(raw []byte, record Record) []byte {
+	message := record.Message.GetRaw()
+	raw = binary.AppendUvarint(raw, uint64(record.Time))
+	raw = append(raw, byte(record.Direction))
+	raw = binary.AppendUvarint(raw, uint64(record.Session))
+	raw = binary.AppendUvarint(raw, uint64(len(message)))
+	return append(raw, message...)
+}
+
+// Close closes the file of a recording made with CreateRecording.
+func (rec *Recorder) Close() error {
+	if rec.closer == nil {
+		return nil
+	}
+	return rec.closer.Close()
+}
+
+// RecordingReader reads the records of a recording in order.
+type RecordingReader struct {
+	// Start is the wall clock time the recording started.
+	Start time.Time
+
+	r *bufio.Reader
+}
+
+// NewRecordingReader reads the header of a recording.
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	reader := &RecordingReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(recordingMagic)+8)
+	if _, readErr := io.ReadFull(reader.r, header); readErr != nil || !bytes.Equal(header[:len(recordingMagic)], recordingMagic) {
+		return nil, ErrRecordingInvalid
+	}
+	reader.Start = time.Unix(0, int64(binary.BigEndian.Uint64(header[len(recordingMagic):])))
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the recording. A
+// recording cut short, by a crash for instance, ends with
+// io.ErrUnexpectedEOF.
+func (reader *RecordingReader) Next() (Record, error) {
+	elapsed, timeErr := binary.ReadUvarint(reader.r)
+	if timeErr != nil {
+		return Record{}, timeErr
+	}
+	direction, directionErr := reader.r.ReadByte()
+	if directionErr != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	session, sessionErr := binary.ReadUvarint(reader.r)
+	if sessionErr != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	length, lengthErr := binary.ReadUvarint(reader.r)
+	if lengthErr != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	if length > recordMaxLength {
+		return Record{}, ErrRecordingInvalid
+	}
+	raw := make([]byte, length)
+	if _, readErr := io.ReadFull(reader.r, raw); readErr != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	message, decodeErr := DecodeMessage(bytes.NewReader(raw))
+	if decodeErr != nil {
+		return Record{}, decodeErr
+	}
+	return Record{
+		Time:      time.Duration(elapsed),
+		Direction: Direction(direction),
+		Session:   int(session),
+		Message:   message,
+	}, nil
+}
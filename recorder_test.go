@@ -0,0 +1,143 @@
+package ntgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	rec, err := NewRecorder(&buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	entry := &Entry{Name: BuildString("/speed"), Type: EntryTypeDouble, ID: [2]byte{0x00, 0x01}, Value: BuildDouble(2.5)}
+	messages := []*Message{
+		BuildMessage(&MessageDataKeepAlive{}),
+		BuildMessage(&MessageDataEntryAssignment{Entry: entry}),
+		BuildMessage(&MessageDataEntryUpdate{Entry: entry}),
+	}
+	for i, message := range messages {
+		if err := rec.Record(i+1, Direction(i%2), message); err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+	}
+	reader, err := NewRecordingReader(&buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !reader.Start.Equal(rec.start) {
+		t.Fatalf("Expected start %s but got %s", rec.start, reader.Start)
+	}
+	var last Record
+	for i, message := range messages {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if record.Session != i+1 || record.Direction != Direction(i%2) || record.Time < last.Time {
+			t.Fatalf("Unexpected record %+v", record)
+		}
+		if !bytes.Equal(record.Message.GetRaw(), message.GetRaw()) {
+			t.Fatalf("Expected message %x but got %x", message.GetRaw(), record.Message.GetRaw())
+		}
+		last = record
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", io.EOF, err)
+	}
+}
+
+func TestRecordingInvalid(t *testing.T) {
+	if _, err := NewRecordingReader(bytes.NewReader([]byte("NTLOG\x09garbage!"))); err != ErrRecordingInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrRecordingInvalid, err)
+	}
+	var buffer bytes.Buffer
+	rec, _ := NewRecorder(&buffer)
+	rec.Record(1, DirectionSent, BuildMessage(&MessageDataKeepAlive{}))
+	reader, err := NewRecordingReader(bytes.NewReader(buffer.Bytes()[:buffer.Len()-1]))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if _, err := reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", io.ErrUnexpectedEOF, err)
+	}
+	// A record claiming an enormous message: time, direction, session and
+	// a length near 2^64.
+	corrupt := append(buffer.Bytes()[:len(recordingMagic)+8:len(recordingMagic)+8], 0x00, 0x00, 0x01)
+	corrupt = binary.AppendUvarint(corrupt, 1<<63)
+	reader, err = NewRecordingReader(bytes.NewReader(corrupt))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if _, err := reader.Next(); err != ErrRecordingInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrRecordingInvalid, err)
+	}
+}
+
+func TestRecordingConnections(t *testing.T) {
+	// The client may still be reading when it is closed.
+	var serverLog, clientLog lockedBuffer
+	serverRec, _ := NewRecorder(&serverLog)
+	clientRec, _ := NewRecorder(&clientLog)
+	srv := startServer(t, &NetworkTables{Recorder: serverRec})
+	srv.Double("/robot/speed").Set(1)
+	host, port, _ := net.SplitHostPort(srv.ListenAddrs()[0].String())
+	cl := &NetworkTables{Address: host, Port: port, Mode: ModeClient, Identity: "recorded", Recorder: clientRec}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cl.Boolean("/driver/ready").Set(true)
+	waitFor(t, "client value to reach the server", func() bool {
+		return srv.Boolean("/driver/ready").GetOr(false)
+	})
+	cl.Close()
+	srv.Close()
+
+	serverRecords := readRecords(t, strings.NewReader(serverLog.String()))
+	clientRecords := readRecords(t, strings.NewReader(clientLog.String()))
+	if first := serverRecords[0]; first.Direction != DirectionReceived || first.Message.Type != MessageTypeClientHello {
+		t.Fatalf("Expected the server to record the Client Hello first but got %+v", first)
+	}
+	if first := clientRecords[0]; first.Direction != DirectionSent || first.Message.Type != MessageTypeClientHello {
+		t.Fatalf("Expected the client to record the Client Hello first but got %+v", first)
+	}
+	if !hasAssignment(serverRecords, DirectionReceived, "/driver/ready") || !hasAssignment(clientRecords, DirectionSent, "/driver/ready") {
+		t.Fatal("Expected both sides to record the client assignment")
+	}
+	if !hasAssignment(serverRecords, DirectionSent, "/robot/speed") || !hasAssignment(clientRecords, DirectionReceived, "/robot/speed") {
+		t.Fatal("Expected both sides to record the server assignment")
+	}
+}
+
+func readRecords(t *testing.T, r io.Reader) []Record {
+	reader, err := NewRecordingReader(r)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	records := []Record{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func hasAssignment(records []Record, direction Direction, name string) bool {
+	for _, record := range records {
+		assignment, ok := record.Message.Data.(*MessageDataEntryAssignment)
+		if ok && record.Direction == direction && assignment.Entry.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package ntgo
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// replayEvent is one change to an entry in a recording. For changes other
+// than deletes, entry is the whole entry as it stood after the change.
+type replayEvent struct {
+	time    time.Duration
+	deleted bool
+	entry   Entry
+}
+
+// Player replays the entries of a recording into a NetworkTables instance,
+// client or server, with the original timing. The recording is reduced to
+// the changes it made to entries: values, flags and deletes, whichever
+// connection and direction they were recorded on.
+type Player struct {
+	// Speed multiplies the playback rate: 2 plays twice as fast. Zero means
+	// 1.
+	Speed float64
+	// Loop starts over from the beginning at the end of the recording.
+	Loop bool
+
+	nt     *NetworkTables
+	events []replayEvent
+
+	mu       sync.Mutex
+	next     int
+	position time.Duration
+	touched  map[string]bool
+	// seeks counts calls to seek, so that Play notices when playback
+	// moved.
+	seeks  int
+	seeked chan struct{}
+}
+
+// NewPlayer reads a whole recording to replay into nt.
+func NewPlayer(r io.Reader, nt *NetworkTables) (*Player, error) {
+	events, readErr := readReplayEvents(r)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return &Player{nt: nt, events: events, touched: map[string]bool{}, seeked: make(chan struct{}, 1)}, nil
+}
+
+// readReplayEvents reduces a recording to the changes it made to entries.
+// Entry IDs are only meaningful within the session they were assigned in.
+func readReplayEvents(r io.Reader) ([]replayEvent, error) {
+	reader, readerErr := NewRecordingReader(r)
+	if readerErr != nil {
+		return nil, readerErr
+	}
+	type sessionID struct {
+		session int
+		id      [2]byte
+	}
+	names := map[sessionID]string{}
+	entries := map[string]Entry{}
+	events := []replayEvent{}
+	for {
+		record, nextErr := reader.Next()
+		if nextErr == io.EOF {
+			return events, nil
+		}
+		if nextErr != nil {
+			return nil, nextErr
+		}
+		changed := []replayEvent{}
+		switch data := record.Message.Data.(type) {
+		case *MessageDataEntryAssignment:
+			entry := *data.Entry
+			if entry.ID != EntryIDUnassigned {
+				names[sessionID{record.Session, entry.ID}] = entry.Name.Value
+			}
+			changed = append(changed, replayEvent{entry: entry})
+		case *MessageDataEntryUpdate:
+			name, ok := names[sessionID{record.Session, data.Entry.ID}]
+			entry, exists := entries[name]
+			if !ok || !exists {
+				continue
+			}
+			entry.Type, entry.Value = data.Entry.Type, data.Entry.Value
+			changed = append(changed, replayEvent{entry: entry})
+		case *MessageDataEntryFlagsUpdate:
+			name, ok := names[sessionID{record.Session, data.Entry.ID}]
+			entry, exists := entries[name]
+			if !ok || !exists {
+				continue
+			}
+			entry.Flags = data.Entry.Flags
+			changed = append(changed, replayEvent{entry: entry})
+		case *MessageDataEntryDelete:
+			name, ok := names[sessionID{record.Session, data.Entry.ID}]
+			if !ok {
+				continue
+			}
+			changed = append(changed, replayEvent{deleted: true, entry: Entry{Name: BuildString(name)}})
+		case *MessageDataClearAll:
+			for name := range entries {
+				changed = append(changed, replayEvent{deleted: true, entry: Entry{Name: BuildString(name)}})
+			}
+		}
+		for _, event := range changed {
+			event.time = record.Time
+			if event.deleted {
+				delete(entries, event.entry.Name.Value)
+			} else {
+				entries[event.entry.Name.Value] = event.entry
+			}
+			events = append(events, event)
+		}
+	}
+}
+
+// Duration returns the time of the last change in the recording.
+func (player *Player) Duration() time.Duration {
+	if len(player.events) == 0 {
+		return 0
+	}
+	return player.events[len(player.events)-1].time
+}
+
+// Position returns how far into the recording playback is.
+func (player *Player) Position() time.Duration {
+	player.mu.Lock()
+	defer player.mu.Unlock()
+	return player.position
+}
+
+// Seek jumps to position: every entry the recording has touched is set to
+// how it stood at that moment, and Play carries on from there. It may be
+// called while Play runs.
+func (player *Player) Seek(position time.Duration) {
+	player.mu.Lock()
+	player.seek(position)
+	player.mu.Unlock()
+	select {
+	case player.seeked <- struct{}{}:
+	default:
+	}
+}
+
+// seek is Seek without the notification. The caller holds player.mu.
+func (player *Player) seek(position time.Duration) {
+	next := sort.Search(len(player.events), func(i int) bool {
+		return player.events[i].time > position
+	})
+	state := map[string]Entry{}
+	for _, event := range player.events[:next] {
+		if event.deleted {
+			delete(state, event.entry.Name.Value)
+		} else {
+			state[event.entry.Name.Value] = event.entry
+		}
+	}
+	for name := range player.touched {
+		if _, exists := state[name]; !exists {
+			player.nt.Delete(name)
+			delete(player.touched, name)
+		}
+	}
+	for name, entry := range state {
+		applyEntry(entry, player.nt)
+		player.touched[name] = true
+	}
+	player.next, player.position = next, position
+	player.seeks++
+}
+
+// Play replays the recording from the current position until its end, or
+// over and over if Loop is set, until ctx is done. A recording that changes
+// no entries returns at once, Loop or not.
+func (player *Player) Play(ctx context.Context) error {
+	speed := player.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	var anchor time.Time
+	var anchorPosition time.Duration
+	seeks := -1
+	if len(player.events) == 0 {
+		// Nothing to play, and nothing to loop over.
+		return nil
+	}
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		for {
+			player.mu.Lock()
+			if player.seeks != seeks {
+				// Time is measured from the last seek.
+				anchor, anchorPosition, seeks = time.Now(), player.position, player.seeks
+			}
+			if player.next >= len(player.events) {
+				player.mu.Unlock()
+				break
+			}
+			event := player.events[player.next]
+			player.mu.Unlock()
+			wait := time.Duration(float64(event.time-anchorPosition)/speed) - time.Since(anchor)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-player.seeked:
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+			player.mu.Lock()
+			// A Seek may have moved playback while the timer ran out.
+			if player.seeks == seeks {
+				player.apply(event)
+				player.next++
+				player.position = event.time
+			}
+			player.mu.Unlock()
+		}
+		if !player.Loop {
+			return nil
+		}
+		player.mu.Lock()
+		player.seek(0)
+		player.mu.Unlock()
+	}
+}
+
+// apply makes one change. The caller holds player.mu.
+func (player *Player) apply(event replayEvent) {
+	name := event.entry.Name.Value
+	if event.deleted {
+		if player.touched[name] {
+			player.nt.Delete(name)
+			delete(player.touched, name)
+		}
+		return
+	}
+	applyEntry(event.entry, player.nt)
+	player.touched[name] = true
+}
@@ -0,0 +1,171 @@
+package ntgo
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRecording is a 300ms recording of one entry: assigned, updated,
+// made persistent and deleted, 100ms apart. The update and the delete are
+// by ID only.
+func testRecording(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	if _, err := NewRecorder(&buffer); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	id := [2]byte{0x00, 0x07}
+	records := []Record{
+		{Time: 0, Message: BuildMessage(&MessageDataEntryAssignment{Entry: &Entry{Name: BuildString("/speed"), Type: EntryTypeDouble, ID: id, Flags: EntryFlagTemporary, Value: BuildDouble(1)}})},
+		{Time: 100 * time.Millisecond, Message: BuildMessage(&MessageDataEntryUpdate{Entry: &Entry{Type: EntryTypeDouble, ID: id, Value: BuildDouble(2)}})},
+		{Time: 200 * time.Millisecond, Message: BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &Entry{ID: id, Flags: EntryFlagPersistent}})},
+		{Time: 300 * time.Millisecond, Message: BuildMessage(&MessageDataEntryDelete{Entry: &Entry{ID: id}})},
+	}
+	for _, record := range records {
+		record.Session = 1
+		buffer.Write(appendRecord(nil, record))
+	}
+	return &buffer
+}
+
+func TestPlayerTiming(t *testing.T) {
+	nt := &NetworkTables{}
+	var mu sync.Mutex
+	values := []float64{}
+	nt.Table().AddListener("/speed", func(event EntryEvent) {
+		if event.Kind == EntryEventCreated || event.Kind == EntryEventUpdated {
+			mu.Lock()
+			values = append(values, event.Entry.Value.(*ValueDouble).Value)
+			mu.Unlock()
+		}
+	})
+	player, err := NewPlayer(testRecording(t), nt)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if player.Duration() != 300*time.Millisecond {
+		t.Fatalf("Expected duration 300ms but got %s", player.Duration())
+	}
+	player.Speed = 2
+	start := time.Now()
+	if err := player.Play(context.Background()); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Expected playback to take about 150ms but it took %s", elapsed)
+	}
+	if _, exists := nt.Table().Get("/speed"); exists {
+		t.Fatal("Expected the recorded delete to be replayed")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(values) != 2 || values[0] != 1 || values[1] != 2 {
+		t.Fatalf("Expected values [1 2] but got %v", values)
+	}
+}
+
+func TestPlayerSeek(t *testing.T) {
+	nt := &NetworkTables{}
+	nt.Boolean("/untouched").Set(true)
+	player, err := NewPlayer(testRecording(t), nt)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cases := []struct {
+		position time.Duration
+		exists   bool
+		value    float64
+		flags    EntryFlag
+	}{
+		{150 * time.Millisecond, true, 2, EntryFlagTemporary},
+		{250 * time.Millisecond, true, 2, EntryFlagPersistent},
+		{0, true, 1, EntryFlagTemporary},
+		{time.Second, false, 0, 0},
+		{100 * time.Millisecond, true, 2, EntryFlagTemporary},
+	}
+	for _, c := range cases {
+		player.Seek(c.position)
+		entry, exists := nt.Table().Get("/speed")
+		if exists != c.exists {
+			t.Fatalf("Expected entry to exist %t at %s", c.exists, c.position)
+		}
+		if exists && (entry.Value.(*ValueDouble).Value != c.value || entry.Flags != c.flags) {
+			t.Fatalf("Expected %v (%s) at %s but got %v (%s)", c.value, c.flags, c.position, entry.Value, entry.Flags)
+		}
+		if player.Position() != c.position {
+			t.Fatalf("Expected position %s but got %s", c.position, player.Position())
+		}
+	}
+	if !nt.Boolean("/untouched").GetOr(false) {
+		t.Fatal("Expected entries outside the recording to be left alone")
+	}
+}
+
+func TestPlayerLoop(t *testing.T) {
+	nt := &NetworkTables{}
+	var mu sync.Mutex
+	created := 0
+	nt.Table().AddListener("/speed", func(event EntryEvent) {
+		if event.Kind == EntryEventCreated {
+			mu.Lock()
+			created++
+			mu.Unlock()
+		}
+	})
+	player, err := NewPlayer(testRecording(t), nt)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	player.Speed = 10
+	player.Loop = true
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := player.Play(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", context.DeadlineExceeded, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if created < 3 {
+		t.Fatalf("Expected the recording to loop at least 3 times but it played %d", created)
+	}
+}
+
+func TestPlayerLoopEmpty(t *testing.T) {
+	var buffer bytes.Buffer
+	if _, err := NewRecorder(&buffer); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	buffer.Write(appendRecord(nil, Record{Session: 1, Message: BuildMessage(&MessageDataKeepAlive{})}))
+	player, err := NewPlayer(&buffer, &NetworkTables{})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	player.Loop = true
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- player.Play(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected looping a recording without changes to return")
+	}
+}
+
+func TestPlayerIntoServer(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	cl := connectClient(t, srv, "dashboard")
+	player, err := NewPlayer(testRecording(t), srv)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	player.Seek(150 * time.Millisecond)
+	waitFor(t, "replayed value to reach the client", func() bool {
+		return cl.Double("/speed").GetOr(0) == 2
+	})
+}
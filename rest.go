@@ -0,0 +1,268 @@
+package ntgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+)
+
+var (
+	ErrRESTTypeMissing  = errors.New("rest: type missing")
+	ErrRESTFlagsMissing = errors.New("rest: flags missing")
+	ErrRESTContentType  = errors.New("rest: body must be application/json")
+)
+
+// restMaxBody is the largest request body read.
+const restMaxBody = 1 << 20
+
+// RESTHandler is an http.Handler that reads and writes the entries of a
+// NetworkTables instance:
+//
+//	GET    /entries?prefix=/SmartDashboard   list entries, sorted by name
+//	GET    /entries/{key}                    read an entry
+//	PUT    /entries/{key}                    create or update an entry
+//	PATCH  /entries/{key}                    change the flags of an entry
+//	DELETE /entries/{key}                    delete an entry
+//	POST   /rpc/{key}                        call a remote procedure
+//
+// The key is the rest of the path, so /entries/SmartDashboard/speed is the
+// entry /SmartDashboard/speed. Names that do not start with a slash or that
+// hold empty segments are given as a key parameter instead, as in
+// /entries/?key=speed. Entries are written in their canonical JSON
+// form, as Entry.MarshalJSON gives them. Bodies name types explicitly:
+//
+//	PUT   {"type": "double[]", "value": [1, 2], "flags": "persistent"}
+//	PATCH {"flags": "temporary"}
+//	POST  {"params": {"distance": 1.5}}
+//
+// Bodies must be sent as application/json, which browsers do not send to
+// another site without asking it first. flags may be left out of a PUT,
+// leaving them as they are. Parameters of a
+// call are named after the definition, and any left out take their default;
+// the response is {"results": [{"name": ..., "type": ..., "value": ...}]}.
+// Errors are {"error": "..."} with a matching status code.
+//
+// Mount it under a prefix with http.StripPrefix.
+type RESTHandler struct {
+	nt  *NetworkTables
+	mux *http.ServeMux
+}
+
+// restValueJSON is a typed value, as PUT takes it and RPC results give it.
+// Type is a pointer so that a body without one can be refused.
+type restValueJSON struct {
+	Name  string          `json:"name,omitempty"`
+	Type  *EntryType      `json:"type"`
+	Value json.RawMessage `json:"value"`
+	Flags *EntryFlag      `json:"flags,omitempty"`
+}
+
+// NewRESTHandler serves the entries of nt.
+func NewRESTHandler(nt *NetworkTables) *RESTHandler {
+	handler := &RESTHandler{nt: nt, mux: http.NewServeMux()}
+	handler.mux.HandleFunc("GET /entries", handler.list)
+	handler.mux.HandleFunc("GET /entries/{key...}", handler.get)
+	handler.mux.HandleFunc("PUT /entries/{key...}", handler.put)
+	handler.mux.HandleFunc("PATCH /entries/{key...}", handler.patch)
+	handler.mux.HandleFunc("DELETE /entries/{key...}", handler.delete)
+	handler.mux.HandleFunc("POST /rpc/{key...}", handler.call)
+	return handler
+}
+
+func (handler *RESTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		// A page of another site can send a plain text body without the
+		// browser asking first, but not a JSON one.
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			writeRESTJSON(w, http.StatusUnsupportedMediaType, restError(ErrRESTContentType))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, restMaxBody)
+	}
+	handler.mux.ServeHTTP(w, r)
+}
+
+func (handler *RESTHandler) list(w http.ResponseWriter, r *http.Request) {
+	entries := handler.nt.Table().Entries(r.URL.Query().Get("prefix"))
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name.Value < entries[j].Name.Value })
+	writeRESTJSON(w, http.StatusOK, entries)
+}
+
+func (handler *RESTHandler) get(w http.ResponseWriter, r *http.Request) {
+	entry, exists := handler.nt.Table().Get(restKey(r))
+	if !exists {
+		writeRESTError(w, ErrEntryNotFound)
+		return
+	}
+	writeRESTJSON(w, http.StatusOK, entry)
+}
+
+func (handler *RESTHandler) put(w http.ResponseWriter, r *http.Request) {
+	key := restKey(r)
+	body := restValueJSON{}
+	if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(decodeErr))
+		return
+	}
+	if body.Type == nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(ErrRESTTypeMissing))
+		return
+	}
+	value, valueErr := ParseEntryValueJSON(*body.Type, body.Value)
+	if valueErr != nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(valueErr))
+		return
+	}
+	_, existed := handler.nt.Table().Get(key)
+	if setErr := handler.nt.SetValue(key, *body.Type, value); setErr != nil {
+		writeRESTError(w, setErr)
+		return
+	}
+	if body.Flags != nil {
+		if flagsErr := handler.nt.SetFlags(key, *body.Flags); flagsErr != nil {
+			writeRESTError(w, flagsErr)
+			return
+		}
+	}
+	entry, _ := handler.nt.Table().Get(key)
+	status := http.StatusOK
+	if !existed {
+		status = http.StatusCreated
+	}
+	writeRESTJSON(w, status, entry)
+}
+
+func (handler *RESTHandler) patch(w http.ResponseWriter, r *http.Request) {
+	key := restKey(r)
+	body := struct {
+		Flags *EntryFlag `json:"flags"`
+	}{}
+	if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(decodeErr))
+		return
+	}
+	if body.Flags == nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(ErrRESTFlagsMissing))
+		return
+	}
+	if flagsErr := handler.nt.SetFlags(key, *body.Flags); flagsErr != nil {
+		writeRESTError(w, flagsErr)
+		return
+	}
+	entry, _ := handler.nt.Table().Get(key)
+	writeRESTJSON(w, http.StatusOK, entry)
+}
+
+func (handler *RESTHandler) delete(w http.ResponseWriter, r *http.Request) {
+	if deleteErr := handler.nt.Delete(restKey(r)); deleteErr != nil {
+		writeRESTError(w, deleteErr)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *RESTHandler) call(w http.ResponseWriter, r *http.Request) {
+	key := restKey(r)
+	entry, exists := handler.nt.Table().Get(key)
+	if !exists {
+		writeRESTError(w, ErrEntryNotFound)
+		return
+	}
+	definition, ok := entry.Value.(*ValueRPC)
+	if !ok {
+		writeRESTError(w, ErrEntryCastInvalid)
+		return
+	}
+	body := struct {
+		Params map[string]json.RawMessage `json:"params"`
+	}{}
+	if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+		writeRESTJSON(w, http.StatusBadRequest, restError(decodeErr))
+		return
+	}
+	params := make([]EntryValue, len(definition.Params))
+	for i, param := range definition.Params {
+		params[i] = param.DefaultVal
+		raw, given := body.Params[param.Name.Value]
+		if !given {
+			continue
+		}
+		delete(body.Params, param.Name.Value)
+		value, valueErr := ParseEntryValueJSON(param.Type, raw)
+		if valueErr != nil {
+			writeRESTJSON(w, http.StatusBadRequest, restError(valueErr))
+			return
+		}
+		params[i] = value
+	}
+	if len(body.Params) > 0 {
+		writeRESTError(w, ErrRPCParamsInvalid)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), handler.nt.timeout())
+	defer cancel()
+	results, callErr := handler.nt.CallRPC(ctx, key, params...)
+	if callErr != nil {
+		writeRESTError(w, callErr)
+		return
+	}
+	encoded := make([]restValueJSON, len(results))
+	for i, result := range results {
+		value, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			writeRESTError(w, marshalErr)
+			return
+		}
+		output := definition.Outputs[i]
+		encoded[i] = restValueJSON{Name: output.Name.Value, Type: &output.Type, Value: value}
+	}
+	writeRESTJSON(w, http.StatusOK, struct {
+		Results []restValueJSON `json:"results"`
+	}{encoded})
+}
+
+// restKey returns the entry a request is for: the key parameter, which can
+// name any entry, or else the rest of the path.
+func restKey(r *http.Request) string {
+	if key, given := r.URL.Query()["key"]; given {
+		return key[0]
+	}
+	return "/" + r.PathValue("key")
+}
+
+func restError(err error) any {
+	return struct {
+		Error string `json:"error"`
+	}{err.Error()}
+}
+
+// writeRESTError answers with err and the status code it calls for.
+func writeRESTError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrEntryTypeMismatch):
+		status = http.StatusConflict
+	case errors.Is(err, ErrNotConnected):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, ErrEntryCastInvalid), errors.Is(err, ErrRPCParamsInvalid),
+		errors.Is(err, ErrArrayOutOfSpace), errors.Is(err, ErrEntryNoSuchType),
+		errors.Is(err, ErrEntryDataInvalid), errors.Is(err, ErrEntryFlagNoSuchType):
+		status = http.StatusBadRequest
+	}
+	writeRESTJSON(w, status, restError(err))
+}
+
+func writeRESTJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,235 @@
+package ntgo
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func restRequest(t *testing.T, server *httptest.Server, method, path, body string) (int, string) {
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer response.Body.Close()
+	raw, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	return response.StatusCode, string(raw)
+}
+
+func TestRESTEntries(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	cl := connectClient(t, srv, "pit")
+	server := httptest.NewServer(NewRESTHandler(cl))
+	defer server.Close()
+
+	status, body := restRequest(t, server, http.MethodPut, "/entries/SmartDashboard/pose", `{"type": "double[]", "value": [1, 2.5], "flags": "persistent"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status %d but got %d: %s", http.StatusCreated, status, body)
+	}
+	entry, err := ParseEntryJSON([]byte(body))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if entry.Name.Value != "/SmartDashboard/pose" || entry.Type != EntryTypeDoubleArr || entry.Flags != EntryFlagPersistent {
+		t.Fatalf("Expected a persistent double[] /SmartDashboard/pose but got %s", entry)
+	}
+	waitFor(t, "the write to reach the server", func() bool {
+		entry, exists := srv.Table().Get("/SmartDashboard/pose")
+		return exists && entry.Flags == EntryFlagPersistent
+	})
+	restRequest(t, server, http.MethodPut, "/entries/SmartDashboard/speed", `{"type": "double", "value": 3}`)
+	restRequest(t, server, http.MethodPut, "/entries/other", `{"type": "string", "value": "x"}`)
+
+	status, body = restRequest(t, server, http.MethodGet, "/entries?prefix=/SmartDashboard", "")
+	entries := []Entry{}
+	if err := json.Unmarshal([]byte(body), &entries); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if status != http.StatusOK || len(entries) != 2 || entries[0].Name.Value != "/SmartDashboard/pose" || entries[1].Name.Value != "/SmartDashboard/speed" {
+		t.Fatalf("Expected the two /SmartDashboard entries in order but got %d: %s", status, body)
+	}
+
+	status, body = restRequest(t, server, http.MethodPut, "/entries/SmartDashboard/speed", `{"type": "double", "value": 4}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d: %s", http.StatusOK, status, body)
+	}
+	status, body = restRequest(t, server, http.MethodGet, "/entries/SmartDashboard/speed", "")
+	if entry, err := ParseEntryJSON([]byte(body)); status != http.StatusOK || err != nil || entry.Value.(*ValueDouble).Value != 4 {
+		t.Fatalf("Expected /SmartDashboard/speed to be 4 but got %d: %s", status, body)
+	}
+	status, body = restRequest(t, server, http.MethodPatch, "/entries/SmartDashboard/pose", `{"flags": "temporary"}`)
+	if entry, err := ParseEntryJSON([]byte(body)); status != http.StatusOK || err != nil || entry.Flags != EntryFlagTemporary {
+		t.Fatalf("Expected /SmartDashboard/pose to be temporary but got %d: %s", status, body)
+	}
+	if status, body := restRequest(t, server, http.MethodDelete, "/entries/SmartDashboard/pose", ""); status != http.StatusNoContent {
+		t.Fatalf("Expected status %d but got %d: %s", http.StatusNoContent, status, body)
+	}
+	if _, exists := cl.Table().Get("/SmartDashboard/pose"); exists {
+		t.Fatal("Expected /SmartDashboard/pose to be deleted")
+	}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/entries/SmartDashboard/pose", "", http.StatusNotFound},
+		{http.MethodDelete, "/entries/SmartDashboard/pose", "", http.StatusNotFound},
+		{http.MethodPatch, "/entries/SmartDashboard/pose", `{"flags": "persistent"}`, http.StatusNotFound},
+		{http.MethodPut, "/entries/SmartDashboard/speed", `{"type": "string", "value": "fast"}`, http.StatusConflict},
+		{http.MethodPut, "/entries/SmartDashboard/speed", `{"value": 5}`, http.StatusBadRequest},
+		{http.MethodPut, "/entries/SmartDashboard/speed", `{"type": "double", "value": "fast"}`, http.StatusBadRequest},
+		{http.MethodPut, "/entries/SmartDashboard/speed", `{"type": "double", "value": 5, "flags": "sticky"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/entries/SmartDashboard/speed", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/entries/SmartDashboard/speed", `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		status, body := restRequest(t, server, c.method, c.path, c.body)
+		if status != c.status {
+			t.Fatalf("Expected status %d for %s %s but got %d: %s", c.status, c.method, c.path, status, body)
+		}
+		if status != http.StatusMethodNotAllowed && !strings.Contains(body, `"error"`) {
+			t.Fatalf("Expected an error body for %s %s but got %s", c.method, c.path, body)
+		}
+	}
+}
+
+func TestRESTCallRPC(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	definition := &ValueRPC{
+		DefVersion:    RPCDefVersion,
+		ProcedureName: BuildString("add"),
+		ParamSize:     2,
+		Params: []RPCParam{
+			{Type: EntryTypeDouble, Name: BuildString("a"), DefaultVal: BuildDouble(0)},
+			{Type: EntryTypeDouble, Name: BuildString("b"), DefaultVal: BuildDouble(10)},
+		},
+		OutputSize: 1,
+		Outputs:    []RPCOutput{{Type: EntryTypeDouble, Name: BuildString("sum")}},
+	}
+	err := srv.RegisterRPC("/rpc/add", definition, func(params []EntryValue) ([]EntryValue, error) {
+		sum := params[0].(*ValueDouble).Value + params[1].(*ValueDouble).Value
+		return []EntryValue{BuildDouble(sum)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cl := connectClient(t, srv, "pit")
+	waitFor(t, "the procedure to reach the client", func() bool {
+		_, exists := cl.Table().Get("/rpc/add")
+		return exists
+	})
+	server := httptest.NewServer(NewRESTHandler(cl))
+	defer server.Close()
+
+	status, body := restRequest(t, server, http.MethodPost, "/rpc/rpc/add", `{"params": {"b": 2.5}}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d: %s", http.StatusOK, status, body)
+	}
+	if expected := `{"results":[{"name":"sum","type":"double","value":2.5}]}`; strings.TrimSpace(body) != expected {
+		t.Fatalf("Expected %s but got %s", expected, body)
+	}
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/rpc/rpc/add", `{"params": {"c": 1}}`, http.StatusBadRequest},
+		{"/rpc/rpc/add", `{"params": {"a": "one"}}`, http.StatusBadRequest},
+		{"/rpc/rpc/missing", `{}`, http.StatusNotFound},
+		{"/rpc/?key=%2Frpc%2Fadd", `{"params": {"a": 1}}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		if status, body := restRequest(t, server, http.MethodPost, c.path, c.body); status != c.status {
+			t.Fatalf("Expected status %d for %s but got %d: %s", c.status, c.body, status, body)
+		}
+	}
+}
+
+func TestRESTKeyParameter(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	server := httptest.NewServer(NewRESTHandler(srv))
+	defer server.Close()
+
+	// Neither name can be reached through the path.
+	for _, name := range []string{"speed", "/a//b"} {
+		path := "/entries/?key=" + url.QueryEscape(name)
+		if status, body := restRequest(t, server, http.MethodPut, path, `{"type": "double", "value": 1}`); status != http.StatusCreated {
+			t.Fatalf("Expected status %d for %s but got %d: %s", http.StatusCreated, name, status, body)
+		}
+		if _, exists := srv.Table().Get(name); !exists {
+			t.Fatalf("Expected %s to be created", name)
+		}
+		status, body := restRequest(t, server, http.MethodGet, path, "")
+		if entry, err := ParseEntryJSON([]byte(body)); status != http.StatusOK || err != nil || entry.Name.Value != name {
+			t.Fatalf("Expected to read %s but got %d: %s", name, status, body)
+		}
+		if status, body := restRequest(t, server, http.MethodDelete, path, ""); status != http.StatusNoContent {
+			t.Fatalf("Expected status %d for %s but got %d: %s", http.StatusNoContent, name, status, body)
+		}
+	}
+}
+
+func TestRESTErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{ErrEntryNotFound, http.StatusNotFound},
+		{ErrEntryTypeMismatch, http.StatusConflict},
+		{ErrArrayOutOfSpace, http.StatusBadRequest},
+		{ErrEntryDataInvalid, http.StatusBadRequest},
+		{ErrNotConnected, http.StatusServiceUnavailable},
+		{io.ErrUnexpectedEOF, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		writeRESTError(recorder, c.err)
+		if recorder.Code != c.status {
+			t.Fatalf("Expected status %d for %s but got %d", c.status, c.err, recorder.Code)
+		}
+	}
+}
+
+func TestRESTBodies(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	server := httptest.NewServer(NewRESTHandler(srv))
+	defer server.Close()
+
+	// A form or a text/plain body can come from any page without the
+	// browser asking first, so it is refused.
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		request, err := http.NewRequest(http.MethodPut, server.URL+"/entries/speed", strings.NewReader(`{"type": "double", "value": 1}`))
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		request.Header.Set("Content-Type", contentType)
+		response, err := server.Client().Do(request)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("Expected status %d for %q but got %d", http.StatusUnsupportedMediaType, contentType, response.StatusCode)
+		}
+	}
+	if _, exists := srv.Table().Get("/speed"); exists {
+		t.Fatal("Expected /speed not to be created")
+	}
+	huge := `{"type": "string", "value": "` + strings.Repeat("a", restMaxBody) + `"}`
+	if status, body := restRequest(t, server, http.MethodPut, "/entries/speed", huge); status != http.StatusBadRequest {
+		t.Fatalf("Expected status %d but got %d: %.100s", http.StatusBadRequest, status, body)
+	}
+}
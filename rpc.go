@@ -1,6 +1,9 @@
 package ntgo
 
-import "io"
+import (
+	"bytes"
+	"io"
+)
 
 const (
 	// RPCDefVersion should always be set to 1 according to the
@@ -28,9 +31,23 @@ type RPCOutput struct {
 	Name *ValueString
 }
 
+// DecodeRPC reads an RPC definition as entry values carry it: a ULEB128
+// length, then the definition itself.
 func DecodeRPC(r io.Reader) (*ValueRPC, error) {
+	length, lengthErr := DecodeULEB128(r)
+	if lengthErr != nil {
+		return nil, lengthErr
+	}
+	data, dataErr := readLength(r, length)
+	if dataErr != nil {
+		return nil, dataErr
+	}
+	return decodeRPCDefinition(bytes.NewReader(data))
+}
+
+func decodeRPCDefinition(r io.Reader) (*ValueRPC, error) {
 	versionRaw := make([]byte, 1)
-	_, versionErr := r.Read(versionRaw)
+	_, versionErr := io.ReadFull(r, versionRaw)
 	if versionErr != nil {
 		return nil, versionErr
 	}
@@ -39,7 +56,7 @@ func DecodeRPC(r io.Reader) (*ValueRPC, error) {
 		return nil, nameErr
 	}
 	paramSizeRaw := make([]byte, 1)
-	_, paramSizeErr := r.Read(paramSizeRaw)
+	_, paramSizeErr := io.ReadFull(r, paramSizeRaw)
 	if paramSizeErr != nil {
 		return nil, paramSizeErr
 	}
@@ -53,7 +70,7 @@ func DecodeRPC(r io.Reader) (*ValueRPC, error) {
 		params[i] = param
 	}
 	outputSizeRaw := make([]byte, 1)
-	_, outputSizeErr := r.Read(outputSizeRaw)
+	_, outputSizeErr := io.ReadFull(r, outputSizeRaw)
 	if outputSizeErr != nil {
 		return nil, outputSizeErr
 	}
@@ -88,10 +105,10 @@ func (rpc *ValueRPC) GetRaw() []byte {
 	raw = append(raw, byte(rpc.OutputSize))
 	outputsRaw := []byte{}
 	for i := uint8(0); i < rpc.OutputSize; i++ {
-		outputsRaw = append(paramsRaw, rpc.Outputs[i].GetRaw()...)
+		outputsRaw = append(outputsRaw, rpc.Outputs[i].GetRaw()...)
 	}
 	raw = append(raw, outputsRaw...)
-	return raw
+	return append(EncodeULEB128(uint32(len(raw))), raw...)
 }
 
 func DecodeRPCParam(r io.Reader) (RPCParam, error) {
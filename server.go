@@ -0,0 +1,591 @@
+package ntgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRPCNotRegistered  = errors.New("rpc: no handler registered for procedure")
+	ErrEntryIDsExhausted = errors.New("server: every entry ID is taken")
+)
+
+// closeFlushTimeout is how long the last messages to a client that is being
+// disconnected have to go out.
+const closeFlushTimeout = 100 * time.Millisecond
+
+// RPCHandler runs a procedure on the server. It receives one value per
+// parameter in the definition and returns one value per output.
+type RPCHandler func(params []EntryValue) ([]EntryValue, error)
+
+type server struct {
+	table    *Table
+	identity string
+	tls      *tls.Config
+	// allowRevision2 lets revision 2.0 clients connect.
+	allowRevision2 bool
+	recorder       *Recorder
+	updateRate     time.Duration
+	timeout        time.Duration
+	persistFile    string
+	persistPeriod  time.Duration
+	maxClients     int
+	maxEntries     int
+	log            *log.Logger
+	connections    *connections
+
+	listeners []Listener
+
+	mu       sync.Mutex
+	conns    map[*serverConn]bool
+	seen     map[string]bool
+	nextID   uint16
+	handlers map[[2]byte]RPCHandler
+
+	persistDirty bool
+	persistMu    sync.Mutex
+	persistStop  int
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type serverConn struct {
+	server   *server
+	conn     io.ReadWriteCloser
+	outbox   *outbox
+	identity string
+	connID   int
+	wire     wire
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (srv *server) Initialize(nt NetworkTables) error {
+	srv.table = nt.Table()
+	srv.identity = nt.identity()
+	srv.tls = nt.TLS
+	srv.allowRevision2 = nt.AllowRevision2
+	srv.recorder = nt.Recorder
+	srv.updateRate = nt.updateRate()
+	srv.timeout = nt.timeout()
+	srv.persistFile = nt.PersistFile
+	srv.persistPeriod = nt.persistPeriod()
+	srv.maxClients = nt.MaxClients
+	srv.maxEntries = nt.MaxEntries
+	srv.log = nt.logger()
+	srv.connections = nt.conns
+	srv.conns = map[*serverConn]bool{}
+	srv.seen = map[string]bool{}
+	srv.handlers = map[[2]byte]RPCHandler{}
+	srv.done = make(chan struct{})
+	srv.reassignIDs()
+	if srv.persistFile != "" {
+		if loadErr := srv.loadPersistent(); loadErr != nil {
+			return loadErr
+		}
+	}
+	addresses := nt.Listen
+	if len(addresses) == 0 && len(nt.Listeners) == 0 {
+		addresses = []string{net.JoinHostPort(nt.Address, nt.Port)}
+	}
+	for _, address := range addresses {
+		listener, listenErr := Listen(address)
+		if listenErr != nil {
+			for _, opened := range srv.listeners {
+				opened.Close()
+			}
+			return listenErr
+		}
+		srv.listeners = append(srv.listeners, listener)
+	}
+	srv.listeners = append(srv.listeners, nt.Listeners...)
+	for _, listener := range srv.listeners {
+		srv.log.Printf("ntgo: listening on %s", listener.Addr())
+		srv.wg.Add(1)
+		go srv.acceptLoop(listener)
+	}
+	if srv.persistFile != "" {
+		srv.persistStop = srv.table.AddListener("", srv.markDirty)
+		srv.wg.Add(1)
+		go srv.persistLoop()
+	}
+	return nil
+}
+
+// Addrs returns the addresses the server is listening on.
+func (srv *server) Addrs() []net.Addr {
+	addrs := []net.Addr{}
+	for _, listener := range srv.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+// reassignIDs gives every entry already in the table a server ID, so that a
+// table filled in before Initialize can be announced to clients.
+func (srv *server) reassignIDs() {
+	for _, entry := range srv.table.Entries("") {
+		if id, ok := srv.allocateID(); ok {
+			srv.table.assignID(entry.Name.Value, id)
+		}
+	}
+}
+
+// allocateID returns the next ID that no entry holds, or false if every ID
+// is taken. IDs wrap around once the last one has been handed out.
+func (srv *server) allocateID() ([2]byte, bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for range 0xFFFF {
+		id := [2]byte{byte(srv.nextID >> 8), byte(srv.nextID)}
+		srv.nextID++
+		if srv.nextID == 0xFFFF {
+			srv.nextID = 0
+		}
+		if _, taken := srv.table.GetByID(id); !taken {
+			return id, true
+		}
+	}
+	return EntryIDUnassigned, false
+}
+
+func (srv *server) loadPersistent() error {
+	entries, loadErr := LoadPersistentFile(srv.persistFile)
+	if loadErr != nil {
+		return loadErr
+	}
+	for _, entry := range entries {
+		if existing, ok := srv.table.Get(entry.Name.Value); ok {
+			entry.ID = existing.ID
+		} else if id, ok := srv.allocateID(); ok {
+			entry.ID = id
+		} else {
+			srv.log.Printf("ntgo: ignoring persistent entry %q, %s", entry.Name.Value, ErrEntryIDsExhausted)
+			continue
+		}
+		srv.table.Assign(entry, true)
+	}
+	srv.log.Printf("ntgo: loaded %d persistent entries from %s", len(entries), srv.persistFile)
+	return nil
+}
+
+func (srv *server) markDirty(event EntryEvent) {
+	if event.Entry.Flags&EntryFlagPersistent == 0 && event.Kind != EntryEventFlagsUpdated {
+		return
+	}
+	srv.persistMu.Lock()
+	srv.persistDirty = true
+	srv.persistMu.Unlock()
+}
+
+func (srv *server) persistLoop() {
+	defer srv.wg.Done()
+	ticker := time.NewTicker(srv.persistPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+			srv.savePersistent(false)
+		}
+	}
+}
+
+// savePersistent writes the persistent entries to disk if any of them
+// changed since the last save, or unconditionally when force is set.
+func (srv *server) savePersistent(force bool) error {
+	srv.persistMu.Lock()
+	defer srv.persistMu.Unlock()
+	if !srv.persistDirty && !force {
+		return nil
+	}
+	saveErr := SavePersistentFile(srv.persistFile, srv.table.Entries(""))
+	if saveErr != nil {
+		srv.log.Printf("ntgo: saving persistent entries: %s", saveErr)
+		return saveErr
+	}
+	srv.persistDirty = false
+	return nil
+}
+
+func (srv *server) acceptLoop(listener Listener) {
+	defer srv.wg.Done()
+	for {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			select {
+			case <-srv.done:
+				return
+			default:
+			}
+			srv.log.Printf("ntgo: accept on %s: %s", listener.Addr(), acceptErr)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		srv.mu.Lock()
+		full := srv.maxClients > 0 && len(srv.conns) >= srv.maxClients
+		srv.mu.Unlock()
+		if full {
+			srv.log.Printf("ntgo: rejecting %s, client limit of %d reached", remoteAddr(conn), srv.maxClients)
+			conn.Close()
+			continue
+		}
+		sc := &serverConn{
+			server: srv,
+			conn:   conn,
+			outbox: newOutbox(),
+			done:   make(chan struct{}),
+		}
+		srv.wg.Add(1)
+		go sc.serve()
+	}
+}
+
+func (sc *serverConn) serve() {
+	defer sc.server.wg.Done()
+	defer sc.close()
+	srv := sc.server
+	var peer string
+	if srv.tls != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), srv.timeout)
+		tlsConn, tlsErr := serverTLS(ctx, sc.conn, srv.tls)
+		cancel()
+		if tlsErr != nil {
+			srv.log.Printf("ntgo: TLS handshake with %s failed: %s", remoteAddr(sc.conn), tlsErr)
+			return
+		}
+		sc.conn = tlsConn
+		peer, _ = peerIdentity(tlsConn)
+	}
+	reader := bufio.NewReader(sc.conn)
+	setDeadline(sc.conn, time.Now().Add(srv.timeout))
+	hello, helloErr := readClientHello(reader)
+	if helloErr != nil {
+		return
+	}
+	sc.wire = wire{
+		revision: hello.ProtocVersion,
+		typeOf: func(id [2]byte) (EntryType, bool) {
+			entry, ok := srv.table.GetByID(id)
+			return entry.Type, ok
+		},
+		recorder: srv.recorder,
+		session:  srv.recorder.session(),
+	}
+	srv.recorder.Record(sc.wire.session, DirectionReceived, BuildMessage(hello))
+	if hello.ProtocVersion != ProtocolRevision3 && (hello.ProtocVersion != ProtocolRevision2 || !srv.allowRevision2) {
+		srv.log.Printf("ntgo: %s requested unsupported protocol revision %x", remoteAddr(sc.conn), hello.ProtocVersion)
+		sc.wire.send(sc.conn, BuildMessage(&MessageDataProtocVersionUnsupported{SupportedProtoc: ProtocolRevision3}))
+		return
+	}
+	setDeadline(sc.conn, time.Time{})
+	sc.identity = hello.Identity.Value
+	if peer != "" {
+		// A verified certificate says more about who is connecting than
+		// the name the client picked for itself.
+		sc.identity = peer
+	}
+	srv.log.Printf("ntgo: client %q connected from %s", sc.identity, remoteAddr(sc.conn))
+	srv.mu.Lock()
+	select {
+	case <-srv.done:
+		// Close has already taken its list of clients and would not
+		// disconnect this one.
+		srv.mu.Unlock()
+		return
+	default:
+	}
+	flags := FlagMessageClientNew
+	if srv.seen[sc.identity] {
+		flags = FlagMessageClientSeen
+	}
+	if sc.identity != "" {
+		// Revision 2.0 clients have no identity to tell them apart.
+		srv.seen[sc.identity] = true
+	}
+	sc.outbox.push(BuildMessage(&MessageDataServerHello{
+		Flags:    flags,
+		Identity: BuildString(srv.identity),
+	}))
+	for _, entry := range srv.table.Entries("") {
+		sc.outbox.push(BuildMessage(&MessageDataEntryAssignment{Entry: &entry}))
+	}
+	sc.outbox.push(BuildMessage(&MessageDataServerHelloComplete{}))
+	// The connection is listed before the client is registered, as Close
+	// may close it as soon as it is.
+	sc.connID = srv.connections.add(ConnectionInfo{
+		RemoteIdentity:   sc.identity,
+		RemoteAddr:       remoteAddr(sc.conn),
+		ProtocolRevision: hello.ProtocVersion,
+		Connected:        time.Now(),
+	})
+	srv.conns[sc] = true
+	srv.mu.Unlock()
+	go sc.writeLoop()
+	for {
+		message, decodeErr := sc.wire.decode(reader)
+		if decodeErr != nil {
+			srv.log.Printf("ntgo: client %q disconnected", sc.identity)
+			return
+		}
+		srv.connections.touch(sc.connID)
+		srv.handle(sc, message)
+	}
+}
+
+func (sc *serverConn) writeLoop() {
+	ticker := time.NewTicker(sc.server.updateRate)
+	defer ticker.Stop()
+	for {
+		if sc.flush(sc.server.timeout) != nil {
+			sc.close()
+			return
+		}
+		select {
+		case <-sc.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush sends the queued messages, giving up after timeout so that a client
+// that stopped reading cannot block the server.
+func (sc *serverConn) flush(timeout time.Duration) error {
+	messages := sc.outbox.drain()
+	if len(messages) == 0 {
+		return nil
+	}
+	setWriteDeadline(sc.conn, time.Now().Add(timeout))
+	return sc.wire.send(sc.conn, messages...)
+}
+
+func (sc *serverConn) close() {
+	sc.once.Do(func() {
+		sc.server.mu.Lock()
+		delete(sc.server.conns, sc)
+		sc.server.mu.Unlock()
+		close(sc.done)
+		sc.flush(closeFlushTimeout)
+		sc.conn.Close()
+		sc.server.connections.remove(sc.connID)
+	})
+}
+
+// broadcast queues message for every connected client except skip.
+func (srv *server) broadcast(message *Message, skip *serverConn) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for sc := range srv.conns {
+		if sc != skip {
+			sc.outbox.push(message)
+		}
+	}
+}
+
+// handle applies a message from a client and repeats it to the others.
+func (srv *server) handle(sc *serverConn, message *Message) {
+	switch data := message.Data.(type) {
+	case *MessageDataEntryAssignment:
+		entry := *data.Entry
+		if srv.maxEntries > 0 && srv.table.Len() >= srv.maxEntries {
+			srv.log.Printf("ntgo: ignoring %q from %q, entry limit of %d reached", entry.Name.Value, sc.identity, srv.maxEntries)
+			return
+		}
+		if entry.Type == EntryTypeRPCDef {
+			return
+		}
+		id, ok := srv.allocateID()
+		if !ok {
+			srv.log.Printf("ntgo: ignoring %q from %q, %s", entry.Name.Value, sc.identity, ErrEntryIDsExhausted)
+			return
+		}
+		entry.ID = id
+		if !srv.table.create(entry, false) {
+			// A duplicate request from a client that has not yet seen the
+			// assignment for this entry, or another client created it first.
+			return
+		}
+		srv.broadcast(BuildMessage(&MessageDataEntryAssignment{Entry: &entry}), nil)
+	case *MessageDataEntryUpdate:
+		local, ok := srv.table.GetByID(data.Entry.ID)
+		if !ok || local.Type != data.Entry.Type || !SequenceNewer(data.Entry.Sequence, local.Sequence) {
+			return
+		}
+		local.Sequence = data.Entry.Sequence
+		local.Value = data.Entry.Value
+		srv.table.Assign(local, false)
+		srv.broadcast(message, sc)
+	case *MessageDataEntryFlagsUpdate:
+		local, ok := srv.table.GetByID(data.Entry.ID)
+		if !ok {
+			return
+		}
+		srv.table.SetFlags(local.Name.Value, data.Entry.Flags, false)
+		srv.broadcast(message, sc)
+	case *MessageDataEntryDelete:
+		local, ok := srv.table.GetByID(data.Entry.ID)
+		if !ok {
+			return
+		}
+		srv.table.Delete(local.Name.Value, false)
+		srv.broadcast(message, sc)
+	case *MessageDataClearAll:
+		if data.PotentialMagic != DangerousMagic {
+			return
+		}
+		srv.table.Clear(false)
+		srv.broadcast(message, sc)
+	case *MessageDataRPCExecute:
+		go srv.executeRPC(sc, data)
+	}
+}
+
+func (srv *server) executeRPC(sc *serverConn, data *MessageDataRPCExecute) {
+	results, callErr := srv.CallRPC(context.Background(), data.EntryID, data.Params)
+	if callErr != nil {
+		srv.log.Printf("ntgo: rpc from %q failed: %s", sc.identity, callErr)
+		return
+	}
+	sc.outbox.push(BuildMessage(&MessageDataRPCResponse{
+		EntryID:      data.EntryID,
+		UniqueID:     data.UniqueID,
+		ResultLength: uint32(len(results)),
+		Results:      results,
+	}))
+}
+
+func (srv *server) CreateEntry(entry Entry) error {
+	id, ok := srv.allocateID()
+	if !ok {
+		return ErrEntryIDsExhausted
+	}
+	assigned, ok := srv.table.assignID(entry.Name.Value, id)
+	if !ok {
+		return ErrEntryNotFound
+	}
+	srv.broadcast(BuildMessage(&MessageDataEntryAssignment{Entry: &assigned}), nil)
+	return nil
+}
+
+func (srv *server) UpdateEntry(entry Entry) error {
+	srv.broadcast(BuildMessage(&MessageDataEntryUpdate{Entry: &entry}), nil)
+	return nil
+}
+
+func (srv *server) UpdateEntryFlags(entry Entry) error {
+	srv.broadcast(BuildMessage(&MessageDataEntryFlagsUpdate{Entry: &entry}), nil)
+	return nil
+}
+
+func (srv *server) DeleteEntry(entry Entry) error {
+	srv.broadcast(BuildMessage(&MessageDataEntryDelete{Entry: &entry}), nil)
+	return nil
+}
+
+func (srv *server) GetEntry(id [2]byte) error { return nil }
+
+// CallRPC runs the handler registered for the procedure with the given ID
+// directly, since the server is where procedures execute.
+func (srv *server) CallRPC(ctx context.Context, id [2]byte, params []byte) ([]byte, error) {
+	srv.mu.Lock()
+	handler, ok := srv.handlers[id]
+	srv.mu.Unlock()
+	entry, exists := srv.table.GetByID(id)
+	if !ok || !exists {
+		return nil, ErrRPCNotRegistered
+	}
+	definition, ok := entry.Value.(*ValueRPC)
+	if !ok {
+		return nil, ErrEntryCastInvalid
+	}
+	reader := bytes.NewReader(params)
+	values := make([]EntryValue, len(definition.Params))
+	for i, param := range definition.Params {
+		value, decodeErr := DecodeEntryValue(reader, param.Type)
+		if decodeErr != nil {
+			return nil, ErrRPCParamsInvalid
+		}
+		values[i] = value
+	}
+	outputs, handlerErr := handler(values)
+	if handlerErr != nil {
+		return nil, handlerErr
+	}
+	if len(outputs) != len(definition.Outputs) {
+		return nil, ErrRPCParamsInvalid
+	}
+	results := []byte{}
+	for i, output := range definition.Outputs {
+		if EntryValueType(outputs[i]) != output.Type {
+			return nil, ErrRPCParamsInvalid
+		}
+		results = append(results, outputs[i].GetRaw()...)
+	}
+	return results, nil
+}
+
+// Close stops accepting clients, disconnects every client and saves the
+// persistent entries one last time.
+func (srv *server) Close() error {
+	var closeErr error
+	srv.closeOnce.Do(func() {
+		close(srv.done)
+		for _, listener := range srv.listeners {
+			listener.Close()
+		}
+		srv.mu.Lock()
+		conns := []*serverConn{}
+		for sc := range srv.conns {
+			conns = append(conns, sc)
+		}
+		srv.mu.Unlock()
+		for _, sc := range conns {
+			sc.close()
+		}
+		srv.wg.Wait()
+		if srv.persistFile != "" {
+			srv.table.RemoveListener(srv.persistStop)
+			closeErr = srv.savePersistent(true)
+		}
+	})
+	return closeErr
+}
+
+// RegisterRPC publishes a procedure definition under name and runs handler
+// whenever a client executes it. It is only available in server mode.
+func (nt *NetworkTables) RegisterRPC(name string, definition *ValueRPC, handler RPCHandler) error {
+	srv, ok := nt.Operator.(*server)
+	if !ok {
+		return ErrUnknownMode
+	}
+	if setErr := nt.SetValue(name, EntryTypeRPCDef, definition); setErr != nil {
+		return setErr
+	}
+	entry, _ := nt.Table().Get(name)
+	srv.mu.Lock()
+	srv.handlers[entry.ID] = handler
+	srv.mu.Unlock()
+	return nil
+}
+
+// ListenAddrs returns the addresses a server is accepting clients on, which
+// is useful when listening on port 0.
+func (nt *NetworkTables) ListenAddrs() []net.Addr {
+	srv, ok := nt.Operator.(*server)
+	if !ok {
+		return nil
+	}
+	return srv.Addrs()
+}
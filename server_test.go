@@ -0,0 +1,245 @@
+package ntgo
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, nt *NetworkTables) *NetworkTables {
+	nt.Mode = ModeServer
+	nt.Listen = []string{"127.0.0.1:0"}
+	nt.UpdateRate = 5 * time.Millisecond
+	if err := nt.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { nt.Close() })
+	return nt
+}
+
+func connectClient(t *testing.T, srv *NetworkTables, identity string) *NetworkTables {
+	host, port, _ := net.SplitHostPort(srv.ListenAddrs()[0].String())
+	nt := &NetworkTables{
+		Address:    host,
+		Port:       port,
+		Mode:       ModeClient,
+		Identity:   identity,
+		UpdateRate: 5 * time.Millisecond,
+	}
+	if err := nt.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	t.Cleanup(func() { nt.Close() })
+	return nt
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestServerRelaysBetweenClients(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	srv.Double("/server/value").Set(1)
+	first := connectClient(t, srv, "first")
+	second := connectClient(t, srv, "second")
+	if first.Double("/server/value").GetOr(0) != 1 {
+		t.Fatal("Expected server entry to be announced in the handshake")
+	}
+
+	first.String("/first/mode").Set("auto")
+	waitFor(t, "created entry to reach the other client", func() bool {
+		return second.String("/first/mode").GetOr("") == "auto"
+	})
+	first.String("/first/mode").Set("teleop")
+	waitFor(t, "update to reach the other client", func() bool {
+		return second.String("/first/mode").GetOr("") == "teleop"
+	})
+	if srv.String("/first/mode").GetOr("") != "teleop" {
+		t.Fatal("Expected server to apply the update")
+	}
+
+	srv.Double("/server/value").Set(2)
+	waitFor(t, "server update to reach clients", func() bool {
+		return first.Double("/server/value").GetOr(0) == 2 && second.Double("/server/value").GetOr(0) == 2
+	})
+
+	second.SetFlags("/first/mode", EntryFlagPersistent)
+	waitFor(t, "flags to reach the other client", func() bool {
+		entry, _ := first.Table().Get("/first/mode")
+		return entry.Flags == EntryFlagPersistent
+	})
+	second.Delete("/first/mode")
+	waitFor(t, "delete to reach the other client", func() bool {
+		_, ok := first.Table().Get("/first/mode")
+		return !ok
+	})
+}
+
+func TestServerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networktables.ini")
+	srv := startServer(t, &NetworkTables{PersistFile: path})
+	srv.Double("/config/kP").Set(0.25)
+	srv.SetFlags("/config/kP", EntryFlagPersistent)
+	srv.Double("/status/voltage").Set(12.5)
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+
+	restarted := startServer(t, &NetworkTables{PersistFile: path})
+	if restarted.Double("/config/kP").GetOr(0) != 0.25 {
+		t.Fatal("Expected persistent entry to be restored")
+	}
+	if _, ok := restarted.Table().Get("/status/voltage"); ok {
+		t.Fatal("Expected temporary entry not to be restored")
+	}
+}
+
+func TestServerRPC(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	definition := &ValueRPC{
+		DefVersion:    RPCDefVersion,
+		ProcedureName: BuildString("add"),
+		ParamSize:     2,
+		Params: []RPCParam{
+			{Type: EntryTypeDouble, Name: BuildString("a"), DefaultVal: BuildDouble(0)},
+			{Type: EntryTypeDouble, Name: BuildString("b"), DefaultVal: BuildDouble(10)},
+		},
+		OutputSize: 1,
+		Outputs:    []RPCOutput{{Type: EntryTypeDouble, Name: BuildString("sum")}},
+	}
+	err := srv.RegisterRPC("/rpc/add", definition, func(params []EntryValue) ([]EntryValue, error) {
+		sum := params[0].(*ValueDouble).Value + params[1].(*ValueDouble).Value
+		return []EntryValue{BuildDouble(sum)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cl := connectClient(t, srv, "caller")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	results, err := cl.CallRPC(ctx, "/rpc/add", BuildDouble(5))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if results[0].(*ValueDouble).Value != 15 {
+		t.Fatalf("Expected 15 but got %s", results[0])
+	}
+	if _, err := cl.CallRPC(ctx, "/rpc/add", BuildString("5")); err != ErrRPCParamsInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrRPCParamsInvalid, err)
+	}
+}
+
+func TestServerCloseDuringHandshake(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	conn, err := net.Dial("tcp", srv.ListenAddrs()[0].String())
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+	// Give the server time to accept the connection before closing it.
+	time.Sleep(50 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		srv.Close()
+		close(closed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// The handshake finishing after Close has started must not leave a
+	// client behind that Close waits for.
+	hello := BuildMessage(&MessageDataClientHello{ProtocVersion: ProtocolRevision3, Identity: BuildString("late")})
+	conn.Write(hello.GetRaw())
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for Close")
+	}
+}
+
+func TestServerHandshakeTimeout(t *testing.T) {
+	srv := startServer(t, &NetworkTables{Timeout: 100 * time.Millisecond})
+	conn, err := net.Dial("tcp", srv.ListenAddrs()[0].String())
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+	// A client that never says hello is dropped after the configured
+	// timeout, not the default one.
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("Expected the server to close the connection")
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Fatalf("Timed out waiting for the server to close the connection")
+	}
+}
+
+func TestServerCloseStalledClient(t *testing.T) {
+	pipe := NewPipe()
+	srv := &NetworkTables{Mode: ModeServer, Listeners: []Listener{pipe}, UpdateRate: 5 * time.Millisecond}
+	if err := srv.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	conn, err := pipe.Dial(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer conn.Close()
+	// The client says hello and never reads what the server sends back.
+	sendMessages(conn, BuildMessage(&MessageDataClientHello{ProtocVersion: ProtocolRevision3, Identity: BuildString("stalled")}))
+	waitFor(t, "the client to connect", func() bool { return len(srv.Connections()) == 1 })
+	srv.SetValue("/pending", EntryTypeDouble, BuildDouble(1))
+	closed := make(chan struct{})
+	go func() {
+		srv.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for Close")
+	}
+}
+
+func TestServerIDsWrapAround(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	operator := srv.Operator.(*server)
+	srv.SetValue("/first", EntryTypeDouble, BuildDouble(1))
+	first, _ := srv.Table().Get("/first")
+	operator.mu.Lock()
+	operator.nextID = 0xFFFE
+	operator.mu.Unlock()
+	srv.SetValue("/last", EntryTypeDouble, BuildDouble(2))
+	srv.SetValue("/wrapped", EntryTypeDouble, BuildDouble(3))
+	last, _ := srv.Table().Get("/last")
+	wrapped, _ := srv.Table().Get("/wrapped")
+	// The ID of /first is still in use, so it is skipped.
+	if last.ID != [2]byte{0xFF, 0xFE} || wrapped.ID == first.ID || wrapped.ID == EntryIDUnassigned {
+		t.Fatalf("Expected IDs fffe and a free one but got %x and %x", last.ID, wrapped.ID)
+	}
+	if entry, exists := srv.Table().Get("/first"); !exists || entry.ID != first.ID {
+		t.Fatal("Expected /first to keep its ID")
+	}
+}
+
+func TestServerCreateOnce(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	entry := Entry{Name: BuildString("/race"), Type: EntryTypeDouble, Value: BuildDouble(1)}
+	if !srv.Table().create(entry, false) {
+		t.Fatal("Expected the first create to succeed")
+	}
+	entry.Value = BuildDouble(2)
+	if srv.Table().create(entry, false) {
+		t.Fatal("Expected the second create to fail")
+	}
+	if stored, _ := srv.Table().Get("/race"); stored.Value.(*ValueDouble).Value != 1 {
+		t.Fatalf("Expected the first value to stay but got %s", stored.Value)
+	}
+}
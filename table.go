@@ -0,0 +1,314 @@
+package ntgo
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EntryEventCreated EntryEventKind = iota
+	EntryEventUpdated
+	EntryEventFlagsUpdated
+	EntryEventDeleted
+)
+
+var (
+	// EntryIDUnassigned is the ID a client gives a new entry until the server
+	// assigns it a real one.
+	EntryIDUnassigned = [2]byte{0xFF, 0xFF}
+
+	ErrEntryNotFound     = errors.New("table: no such entry")
+	ErrEntryTypeMismatch = errors.New("table: entry already exists with a different type")
+)
+
+type EntryEventKind int
+
+// EntryEvent describes a single change to an entry in a Table. Entry is a
+// snapshot of the entry after the change, or before it for deletes.
+type EntryEvent struct {
+	Kind  EntryEventKind
+	Entry Entry
+	Local bool
+	Time  time.Time
+}
+
+type EntryListener func(EntryEvent)
+
+// Table is the local copy of every entry known to a NetworkTables instance.
+// It is safe for concurrent use. Listeners are called synchronously, outside
+// of the table lock, in the goroutine that made the change.
+type Table struct {
+	mu           sync.RWMutex
+	entries      map[string]*tableEntry
+	ids          map[[2]byte]string
+	listeners    map[int]tableListener
+	nextListener int
+}
+
+type tableEntry struct {
+	entry      Entry
+	lastChange time.Time
+}
+
+type tableListener struct {
+	prefix string
+	fn     EntryListener
+}
+
+func NewTable() *Table {
+	return &Table{
+		entries:   map[string]*tableEntry{},
+		ids:       map[[2]byte]string{},
+		listeners: map[int]tableListener{},
+	}
+}
+
+// Get returns a snapshot of the named entry.
+func (table *Table) Get(name string) (Entry, bool) {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	stored, ok := table.entries[name]
+	if !ok {
+		return Entry{}, false
+	}
+	return stored.entry, true
+}
+
+// GetByID returns a snapshot of the entry with the given ID.
+func (table *Table) GetByID(id [2]byte) (Entry, bool) {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	name, ok := table.ids[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return table.entries[name].entry, true
+}
+
+// Entries returns a snapshot of every entry whose name starts with prefix,
+// sorted by name.
+func (table *Table) Entries(prefix string) []Entry {
+	table.mu.RLock()
+	entries := []Entry{}
+	for name, stored := range table.entries {
+		if strings.HasPrefix(name, prefix) {
+			entries = append(entries, stored.entry)
+		}
+	}
+	table.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name.Value < entries[j].Name.Value
+	})
+	return entries
+}
+
+// LastChange returns when the named entry was last created or updated, or
+// the zero time if it does not exist.
+func (table *Table) LastChange(name string) time.Time {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	stored, ok := table.entries[name]
+	if !ok {
+		return time.Time{}
+	}
+	return stored.lastChange
+}
+
+// Set creates the named entry or updates its value, bumping its sequence
+// number. Updating an existing entry with a different type fails with
+// ErrEntryTypeMismatch.
+func (table *Table) Set(name string, entryType EntryType, value EntryValue, local bool) (Entry, EntryEventKind, error) {
+	now := time.Now()
+	table.mu.Lock()
+	stored, ok := table.entries[name]
+	kind := EntryEventUpdated
+	if !ok {
+		kind = EntryEventCreated
+		stored = &tableEntry{
+			entry: Entry{
+				Name:  BuildString(name),
+				Type:  entryType,
+				ID:    EntryIDUnassigned,
+				Flags: EntryFlagTemporary,
+			},
+		}
+		table.entries[name] = stored
+	} else if stored.entry.Type != entryType {
+		table.mu.Unlock()
+		return Entry{}, kind, ErrEntryTypeMismatch
+	} else {
+		stored.entry.Sequence = nextSequence(stored.entry.Sequence)
+	}
+	stored.entry.Value = value
+	stored.lastChange = now
+	entry := stored.entry
+	table.mu.Unlock()
+	table.notify(EntryEvent{Kind: kind, Entry: entry, Local: local, Time: now})
+	return entry, kind, nil
+}
+
+// Assign stores entry as-is, replacing any entry with the same name. It is
+// used for values whose ID and sequence number were decided elsewhere.
+func (table *Table) Assign(entry Entry, local bool) EntryEventKind {
+	now := time.Now()
+	name := entry.Name.Value
+	table.mu.Lock()
+	kind := EntryEventUpdated
+	if previous, ok := table.entries[name]; ok {
+		delete(table.ids, previous.entry.ID)
+	} else {
+		kind = EntryEventCreated
+	}
+	if existing, ok := table.ids[entry.ID]; ok && existing != name {
+		delete(table.entries, existing)
+	}
+	table.entries[name] = &tableEntry{entry: entry, lastChange: now}
+	if entry.ID != EntryIDUnassigned {
+		table.ids[entry.ID] = name
+	}
+	table.mu.Unlock()
+	table.notify(EntryEvent{Kind: kind, Entry: entry, Local: local, Time: now})
+	return kind
+}
+
+// create adds entry unless the table already holds an entry of that name.
+// The check and the insert happen under one lock, so that of two clients
+// creating the same name only one succeeds.
+func (table *Table) create(entry Entry, local bool) bool {
+	now := time.Now()
+	name := entry.Name.Value
+	table.mu.Lock()
+	if _, exists := table.entries[name]; exists {
+		table.mu.Unlock()
+		return false
+	}
+	table.entries[name] = &tableEntry{entry: entry, lastChange: now}
+	if entry.ID != EntryIDUnassigned {
+		table.ids[entry.ID] = name
+	}
+	table.mu.Unlock()
+	table.notify(EntryEvent{Kind: EntryEventCreated, Entry: entry, Local: local, Time: now})
+	return true
+}
+
+// assignID quietly gives the named entry its ID without notifying
+// listeners, since only the wire representation changes.
+func (table *Table) assignID(name string, id [2]byte) (Entry, bool) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	stored, ok := table.entries[name]
+	if !ok {
+		return Entry{}, false
+	}
+	delete(table.ids, stored.entry.ID)
+	stored.entry.ID = id
+	table.ids[id] = name
+	return stored.entry, true
+}
+
+// unassignIDs quietly forgets every ID, as a client must before it connects
+// to a server again: the server may hand out different IDs this time.
+func (table *Table) unassignIDs() {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	for _, stored := range table.entries {
+		stored.entry.ID = EntryIDUnassigned
+	}
+	clear(table.ids)
+}
+
+// Len returns the number of entries in the table.
+func (table *Table) Len() int {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	return len(table.entries)
+}
+
+// SetFlags replaces the flags of the named entry.
+func (table *Table) SetFlags(name string, flags EntryFlag, local bool) (Entry, error) {
+	table.mu.Lock()
+	stored, ok := table.entries[name]
+	if !ok {
+		table.mu.Unlock()
+		return Entry{}, ErrEntryNotFound
+	}
+	stored.entry.Flags = flags
+	entry := stored.entry
+	table.mu.Unlock()
+	table.notify(EntryEvent{Kind: EntryEventFlagsUpdated, Entry: entry, Local: local, Time: time.Now()})
+	return entry, nil
+}
+
+// Delete removes the named entry.
+func (table *Table) Delete(name string, local bool) (Entry, error) {
+	table.mu.Lock()
+	stored, ok := table.entries[name]
+	if !ok {
+		table.mu.Unlock()
+		return Entry{}, ErrEntryNotFound
+	}
+	delete(table.entries, name)
+	delete(table.ids, stored.entry.ID)
+	table.mu.Unlock()
+	table.notify(EntryEvent{Kind: EntryEventDeleted, Entry: stored.entry, Local: local, Time: time.Now()})
+	return stored.entry, nil
+}
+
+// Clear removes every entry, notifying listeners of each delete.
+func (table *Table) Clear(local bool) {
+	table.mu.Lock()
+	entries := table.entries
+	table.entries = map[string]*tableEntry{}
+	table.ids = map[[2]byte]string{}
+	table.mu.Unlock()
+	now := time.Now()
+	for _, stored := range entries {
+		table.notify(EntryEvent{Kind: EntryEventDeleted, Entry: stored.entry, Local: local, Time: now})
+	}
+}
+
+// AddListener calls fn for every change to an entry whose name starts with
+// prefix. The returned ID can be passed to RemoveListener.
+func (table *Table) AddListener(prefix string, fn EntryListener) int {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	table.nextListener++
+	table.listeners[table.nextListener] = tableListener{prefix: prefix, fn: fn}
+	return table.nextListener
+}
+
+func (table *Table) RemoveListener(id int) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	delete(table.listeners, id)
+}
+
+func (table *Table) notify(event EntryEvent) {
+	table.mu.RLock()
+	listeners := []EntryListener{}
+	for _, listener := range table.listeners {
+		if strings.HasPrefix(event.Entry.Name.Value, listener.prefix) {
+			listeners = append(listeners, listener.fn)
+		}
+	}
+	table.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+func nextSequence(sequence [2]byte) [2]byte {
+	next := (uint16(sequence[0])<<8 | uint16(sequence[1])) + 1
+	return [2]byte{byte(next >> 8), byte(next)}
+}
+
+// SequenceNewer reports whether sequence a is strictly greater than b using
+// RFC 1982 serial number arithmetic. Undefined comparisons report false.
+func SequenceNewer(a, b [2]byte) bool {
+	first := uint16(a[0])<<8 | uint16(a[1])
+	second := uint16(b[0])<<8 | uint16(b[1])
+	return first != second && uint16(first-second) < 1<<15
+}
@@ -0,0 +1,101 @@
+package ntgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrNoCandidates = errors.New("client: no server addresses to try")
+
+// TeamResolver turns an FRC team number into the addresses a client should
+// try. An address may carry its own port; otherwise NetworkTables.Port is
+// used.
+type TeamResolver func(team int) []string
+
+// TeamAddresses is the default TeamResolver. It returns the addresses a
+// robot can be reached at, in the order the FRC control system documents
+// them: the static radio network address, the mDNS and DHCP names of the
+// roboRIO, the USB address and finally the local machine for simulation.
+func TeamAddresses(team int) []string {
+	return []string{
+		fmt.Sprintf("10.%d.%d.2", team/100, team%100),
+		fmt.Sprintf("roboRIO-%d-FRC.local", team),
+		fmt.Sprintf("roboRIO-%d-FRC.lan", team),
+		"172.22.11.2",
+		"localhost",
+	}
+}
+
+// teamCandidates returns the addresses, with ports, a client of nt dials
+// for its team.
+func (nt *NetworkTables) teamCandidates() []string {
+	resolver := nt.Resolver
+	if resolver == nil {
+		resolver = TeamAddresses
+	}
+	addresses := []string{}
+	for _, address := range resolver(nt.Team) {
+		addresses = append(addresses, withPort(address, nt.Port))
+	}
+	return addresses
+}
+
+// withPort adds port to address unless it already has one or is a Unix
+// domain socket.
+func withPort(address, port string) string {
+	if strings.HasPrefix(address, UnixPrefix) {
+		return address
+	}
+	if _, _, splitErr := net.SplitHostPort(address); splitErr == nil {
+		return address
+	}
+	return net.JoinHostPort(address, port)
+}
+
+// dialFirst dials every address at once and returns the first connection
+// that succeeds, along with its address. The others are closed as they come
+// in. When every address fails, the error of the first one is returned.
+func dialFirst(dialer Dialer, addresses []string, timeout time.Duration) (io.ReadWriteCloser, string, error) {
+	if len(addresses) == 0 {
+		return nil, "", ErrNoCandidates
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	type dialResult struct {
+		index int
+		conn  io.ReadWriteCloser
+		err   error
+	}
+	results := make(chan dialResult, len(addresses))
+	for i, address := range addresses {
+		go func() {
+			conn, dialErr := dialer.Dial(ctx, address)
+			results <- dialResult{i, conn, dialErr}
+		}()
+	}
+	errs := make([]error, len(addresses))
+	var winner io.ReadWriteCloser
+	winnerAddress := ""
+	for range addresses {
+		result := <-results
+		if result.err != nil {
+			errs[result.index] = result.err
+			continue
+		}
+		if winner != nil {
+			result.conn.Close()
+			continue
+		}
+		winner, winnerAddress = result.conn, addresses[result.index]
+		cancel()
+	}
+	if winner == nil {
+		return nil, "", errs[0]
+	}
+	return winner, winnerAddress, nil
+}
@@ -0,0 +1,60 @@
+package ntgo
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTeamAddresses(t *testing.T) {
+	expected := []string{"10.12.34.2", "roboRIO-1234-FRC.local", "roboRIO-1234-FRC.lan", "172.22.11.2", "localhost"}
+	if result := TeamAddresses(1234); !slices.Equal(result, expected) {
+		t.Fatalf("Expected %v but got %v", expected, result)
+	}
+	if result := TeamAddresses(254)[0]; result != "10.2.54.2" {
+		t.Fatalf("Expected 10.2.54.2 but got %s", result)
+	}
+}
+
+func TestClientTeamResolver(t *testing.T) {
+	srv := startServer(t, &NetworkTables{})
+	srv.Double("/robot/battery").Set(12.5)
+	closed, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("Unexpected error! %s", listenErr)
+	}
+	closed.Close()
+	resolved := 0
+	cl := &NetworkTables{
+		Mode: ModeClient,
+		Team: 1234,
+		Port: DefaultPort,
+		Resolver: func(team int) []string {
+			resolved = team
+			return []string{closed.Addr().String(), srv.ListenAddrs()[0].String()}
+		},
+		Timeout: time.Second,
+	}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if resolved != 1234 {
+		t.Fatalf("Expected resolver to be asked for team 1234 but got %d", resolved)
+	}
+	if cl.Double("/robot/battery").GetOr(0) != 12.5 {
+		t.Fatal("Expected client to sync with the reachable candidate")
+	}
+}
+
+func TestClientTeamResolverNoCandidates(t *testing.T) {
+	cl := &NetworkTables{
+		Mode:     ModeClient,
+		Team:     1234,
+		Resolver: func(team int) []string { return nil },
+	}
+	if err := cl.Initialize(); err != ErrNoCandidates {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrNoCandidates, err)
+	}
+}
@@ -0,0 +1,62 @@
+package ntgo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+var ErrTLSTransportInvalid = errors.New("tls: transport does not provide a net.Conn")
+
+// clientTLS runs the TLS handshake for a client connection to address. The
+// host part of address is checked against the server certificate unless
+// config names a server itself.
+func clientTLS(ctx context.Context, conn io.ReadWriteCloser, config *tls.Config, address string) (*tls.Conn, error) {
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		return nil, ErrTLSTransportInvalid
+	}
+	if config.ServerName == "" && !strings.HasPrefix(address, UnixPrefix) {
+		host, _, splitErr := net.SplitHostPort(address)
+		if splitErr != nil {
+			host = address
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(netConn, config)
+	if handshakeErr := tlsConn.HandshakeContext(ctx); handshakeErr != nil {
+		return nil, handshakeErr
+	}
+	return tlsConn, nil
+}
+
+// serverTLS runs the TLS handshake for a connection a server accepted.
+func serverTLS(ctx context.Context, conn io.ReadWriteCloser, config *tls.Config) (*tls.Conn, error) {
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		return nil, ErrTLSTransportInvalid
+	}
+	tlsConn := tls.Server(netConn, config)
+	if handshakeErr := tlsConn.HandshakeContext(ctx); handshakeErr != nil {
+		return nil, handshakeErr
+	}
+	return tlsConn, nil
+}
+
+// peerIdentity returns the subject of the verified certificate the remote
+// end presented: its common name, or the whole subject when that is empty.
+func peerIdentity(conn *tls.Conn) (string, bool) {
+	state := conn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	subject := state.VerifiedChains[0][0].Subject
+	if subject.CommonName != "" {
+		return subject.CommonName, true
+	}
+	return subject.String(), true
+}
@@ -0,0 +1,157 @@
+package ntgo
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ntgo test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	cert, _ := x509.ParseCertificate(raw)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key}
+}
+
+// lockedBuffer collects log output written from several goroutines.
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+func startTLSServer(t *testing.T, ca *testCA, logs *lockedBuffer) *NetworkTables {
+	return startServer(t, &NetworkTables{
+		TLS: &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, "server", x509.ExtKeyUsageServerAuth)},
+			ClientCAs:    ca.pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		},
+		Logger: log.New(logs, "", 0),
+	})
+}
+
+func TestTLSMutual(t *testing.T) {
+	ca := newTestCA(t)
+	logs := &lockedBuffer{}
+	srv := startTLSServer(t, ca, logs)
+	srv.Double("/secure/value").Set(42)
+	cl := &NetworkTables{
+		Mode:     ModeClient,
+		Servers:  []string{srv.ListenAddrs()[0].String()},
+		Identity: "claimed-name",
+		TLS: &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, "dashboard", x509.ExtKeyUsageClientAuth)},
+			RootCAs:      ca.pool,
+		},
+	}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if cl.Double("/secure/value").GetOr(0) != 42 {
+		t.Fatal("Expected server entry to be announced over TLS")
+	}
+	if !strings.Contains(logs.String(), `client "dashboard" connected`) {
+		t.Fatalf("Expected the certificate subject as identity but got log %q", logs.String())
+	}
+}
+
+func TestTLSRejectsClientWithoutCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startTLSServer(t, ca, &lockedBuffer{})
+	cl := &NetworkTables{
+		Mode:    ModeClient,
+		Servers: []string{srv.ListenAddrs()[0].String()},
+		TLS:     &tls.Config{RootCAs: ca.pool},
+		Timeout: time.Second,
+	}
+	if err := cl.Initialize(); err == nil {
+		cl.Close()
+		t.Fatal("Expected a client without a certificate to be rejected")
+	}
+}
+
+func TestTLSRejectsUntrustedServer(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startTLSServer(t, ca, &lockedBuffer{})
+	cl := &NetworkTables{
+		Mode:    ModeClient,
+		Servers: []string{srv.ListenAddrs()[0].String()},
+		TLS: &tls.Config{
+			Certificates: []tls.Certificate{ca.issue(t, "dashboard", x509.ExtKeyUsageClientAuth)},
+			RootCAs:      newTestCA(t).pool,
+		},
+		Timeout: time.Second,
+	}
+	if err := cl.Initialize(); err == nil {
+		cl.Close()
+		t.Fatal("Expected a server signed by another CA to be rejected")
+	}
+}
@@ -0,0 +1,208 @@
+package ntgo
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// TopicValue is the set of Go types that map directly onto an EntryType.
+type TopicValue interface {
+	bool | float64 | string | []byte | []bool | []float64 | []string
+}
+
+// Topic is a typed handle to a single entry. The entry does not need to exist
+// when the handle is created.
+type Topic[T TopicValue] struct {
+	nt   *NetworkTables
+	name string
+}
+
+// NewTopic returns a handle to the named entry holding values of type T.
+func NewTopic[T TopicValue](nt *NetworkTables, name string) *Topic[T] {
+	return &Topic[T]{nt: nt, name: name}
+}
+
+func (nt *NetworkTables) Boolean(name string) *Topic[bool] {
+	return NewTopic[bool](nt, name)
+}
+
+func (nt *NetworkTables) Double(name string) *Topic[float64] {
+	return NewTopic[float64](nt, name)
+}
+
+func (nt *NetworkTables) String(name string) *Topic[string] {
+	return NewTopic[string](nt, name)
+}
+
+func (nt *NetworkTables) Raw(name string) *Topic[[]byte] {
+	return NewTopic[[]byte](nt, name)
+}
+
+func (nt *NetworkTables) BooleanArray(name string) *Topic[[]bool] {
+	return NewTopic[[]bool](nt, name)
+}
+
+func (nt *NetworkTables) DoubleArray(name string) *Topic[[]float64] {
+	return NewTopic[[]float64](nt, name)
+}
+
+func (nt *NetworkTables) StringArray(name string) *Topic[[]string] {
+	return NewTopic[[]string](nt, name)
+}
+
+func (topic *Topic[T]) Name() string {
+	return topic.name
+}
+
+// Type returns the EntryType values of type T are stored as.
+func (topic *Topic[T]) Type() EntryType {
+	return topicEntryType[T]()
+}
+
+// Get returns the current value of the entry. It fails with ErrEntryNotFound
+// if the entry does not exist and ErrEntryCastInvalid if it holds another type.
+func (topic *Topic[T]) Get() (T, error) {
+	var zero T
+	entry, ok := topic.nt.Table().Get(topic.name)
+	if !ok {
+		return zero, ErrEntryNotFound
+	}
+	return topicValueFrom[T](entry.Value)
+}
+
+// GetOr returns the current value of the entry, or def if it cannot be read.
+func (topic *Topic[T]) GetOr(def T) T {
+	value, err := topic.Get()
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Set creates or updates the entry with value.
+func (topic *Topic[T]) Set(value T) error {
+	entryValue, buildErr := topicEntryValue(value)
+	if buildErr != nil {
+		return buildErr
+	}
+	return topic.nt.SetValue(topic.name, topic.Type(), entryValue)
+}
+
+// Subscribe returns a channel that receives the value of the entry every time
+// it is created or updated, and a function that stops the subscription and
+// closes the channel. The channel only buffers the latest value, so a slow
+// reader skips intermediate values rather than blocking the table.
+func (topic *Topic[T]) Subscribe() (<-chan T, func()) {
+	values := make(chan T, 1)
+	var mu sync.Mutex
+	closed := false
+	table := topic.nt.Table()
+	id := table.AddListener(topic.name, func(event EntryEvent) {
+		if event.Entry.Name.Value != topic.name || event.Kind == EntryEventDeleted || event.Kind == EntryEventFlagsUpdated {
+			return
+		}
+		value, castErr := topicValueFrom[T](event.Entry.Value)
+		if castErr != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case <-values:
+		default:
+		}
+		values <- value
+	})
+	return values, func() {
+		table.RemoveListener(id)
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(values)
+		}
+	}
+}
+
+// LastChange returns when the entry was last created or updated, or the zero
+// time if it does not exist.
+func (topic *Topic[T]) LastChange() time.Time {
+	return topic.nt.Table().LastChange(topic.name)
+}
+
+func topicEntryType[T TopicValue]() EntryType {
+	var zero T
+	switch any(zero).(type) {
+	case bool:
+		return EntryTypeBoolean
+	case float64:
+		return EntryTypeDouble
+	case string:
+		return EntryTypeString
+	case []byte:
+		return EntryTypeRawData
+	case []bool:
+		return EntryTypeBooleanArr
+	case []float64:
+		return EntryTypeDoubleArr
+	case []string:
+		return EntryTypeStringArr
+	default:
+		return EntryTypeUndef
+	}
+}
+
+func topicEntryValue[T TopicValue](value T) (EntryValue, error) {
+	switch v := any(value).(type) {
+	case bool:
+		return BuildBoolean(v), nil
+	case float64:
+		return BuildDouble(v), nil
+	case string:
+		return BuildString(v), nil
+	case []byte:
+		// The table keeps the value, so it must not share the caller's
+		// slice.
+		return BuildRaw(bytes.Clone(v)), nil
+	case []bool:
+		return BuildBooleanArrayFrom(v)
+	case []float64:
+		return BuildDoubleArrayFrom(v)
+	case []string:
+		return BuildStringArrayFrom(v)
+	default:
+		return nil, ErrEntryNoSuchType
+	}
+}
+
+func topicValueFrom[T TopicValue](value EntryValue) (T, error) {
+	var result any
+	switch v := value.(type) {
+	case *ValueBoolean:
+		result = v.Value
+	case *ValueDouble:
+		result = v.Value
+	case *ValueString:
+		result = v.Value
+	case *ValueRaw:
+		// A copy, as the array accessors give, so that the caller cannot
+		// change the entry in the table.
+		result = bytes.Clone(v.Value)
+	case *ValueBooleanArray:
+		result = v.Values()
+	case *ValueDoubleArray:
+		result = v.Values()
+	case *ValueStringArray:
+		result = v.Values()
+	}
+	typed, ok := result.(T)
+	if !ok {
+		var zero T
+		return zero, ErrEntryCastInvalid
+	}
+	return typed, nil
+}
@@ -0,0 +1,103 @@
+package ntgo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopicDoubleSetGet(t *testing.T) {
+	nt := &NetworkTables{}
+	speed := nt.Double("/drive/speed")
+	if _, err := speed.Get(); err != ErrEntryNotFound {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrEntryNotFound, err)
+	}
+	if speed.GetOr(1.5) != 1.5 {
+		t.Fatal("Expected default value for missing entry")
+	}
+	if err := speed.Set(3.5); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	value, err := speed.Get()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if value != 3.5 {
+		t.Fatalf("Expected %v but got %v", 3.5, value)
+	}
+	entry, _ := nt.Table().Get("/drive/speed")
+	if entry.Type != EntryTypeDouble {
+		t.Fatalf("Expected type %v but got %v", EntryTypeDouble, entry.Type)
+	}
+	if speed.LastChange().IsZero() {
+		t.Fatal("Expected LastChange to be set")
+	}
+}
+
+func TestTopicStringArraySetGet(t *testing.T) {
+	nt := &NetworkTables{}
+	names := nt.StringArray("/auto/modes")
+	expected := []string{"left", "center", "right"}
+	if err := names.Set(expected); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	value, err := names.Get()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("Expected %v but got %v", expected, value)
+	}
+}
+
+func TestTopicRawCopies(t *testing.T) {
+	nt := &NetworkTables{}
+	blob := nt.Raw("/vision/blob")
+	data := []byte{1, 2, 3}
+	if err := blob.Set(data); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	data[0] = 9
+	value, err := blob.Get()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	value[1] = 9
+	if stored, _ := blob.Get(); !reflect.DeepEqual(stored, []byte{1, 2, 3}) {
+		t.Fatalf("Expected the entry to stay %v but got %v", []byte{1, 2, 3}, stored)
+	}
+}
+
+func TestTopicTypeMismatch(t *testing.T) {
+	nt := &NetworkTables{}
+	if err := nt.Double("/key").Set(1); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if _, err := nt.String("/key").Get(); err != ErrEntryCastInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrEntryCastInvalid, err)
+	}
+	if err := nt.String("/key").Set("one"); err != ErrEntryTypeMismatch {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrEntryTypeMismatch, err)
+	}
+}
+
+func TestTopicSubscribe(t *testing.T) {
+	nt := &NetworkTables{}
+	enabled := nt.Boolean("/robot/enabled")
+	values, cancel := enabled.Subscribe()
+	defer cancel()
+	nt.Boolean("/robot/enabledOther").Set(false)
+	enabled.Set(true)
+	select {
+	case value := <-values:
+		if !value {
+			t.Fatal("Expected true but got false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for subscription value")
+	}
+	cancel()
+	if _, ok := <-values; ok {
+		t.Fatal("Expected channel to be closed after cancel")
+	}
+}
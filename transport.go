@@ -0,0 +1,146 @@
+package ntgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnixPrefix marks an address as a Unix domain socket path rather than a
+// TCP host and port, as in "unix:/run/ntgo.sock".
+const UnixPrefix = "unix:"
+
+var ErrTransportClosed = errors.New("transport: closed")
+
+// Dialer opens connections from a client to a server.
+type Dialer interface {
+	Dial(ctx context.Context, address string) (io.ReadWriteCloser, error)
+}
+
+// Listener accepts connections from clients for a server.
+type Listener interface {
+	Accept() (io.ReadWriteCloser, error)
+	Close() error
+	Addr() net.Addr
+}
+
+// NetDialer dials TCP addresses, or Unix domain sockets for addresses that
+// start with UnixPrefix. It is the Dialer used when NetworkTables.Dialer is
+// nil.
+type NetDialer struct {
+	net.Dialer
+}
+
+func (dialer *NetDialer) Dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	network, address := splitNetwork(address)
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Listen accepts connections on a TCP address, or on a Unix domain socket
+// for addresses that start with UnixPrefix.
+func Listen(address string) (Listener, error) {
+	network, address := splitNetwork(address)
+	listener, listenErr := net.Listen(network, address)
+	if listenErr != nil {
+		return nil, listenErr
+	}
+	return netListener{listener}, nil
+}
+
+type netListener struct {
+	net.Listener
+}
+
+func (listener netListener) Accept() (io.ReadWriteCloser, error) {
+	return listener.Listener.Accept()
+}
+
+func splitNetwork(address string) (string, string) {
+	if path, ok := strings.CutPrefix(address, UnixPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// Pipe is an in-process transport. A server accepts on it and clients in the
+// same process dial it, without opening any socket. The address given to
+// Dial is ignored.
+type Pipe struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPipe() *Pipe {
+	return &Pipe{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (pipe *Pipe) Dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	clientEnd, serverEnd := net.Pipe()
+	select {
+	case pipe.conns <- serverEnd:
+		return clientEnd, nil
+	case <-pipe.done:
+		clientEnd.Close()
+		serverEnd.Close()
+		return nil, ErrTransportClosed
+	case <-ctx.Done():
+		clientEnd.Close()
+		serverEnd.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func (pipe *Pipe) Accept() (io.ReadWriteCloser, error) {
+	select {
+	case conn := <-pipe.conns:
+		return conn, nil
+	case <-pipe.done:
+		return nil, ErrTransportClosed
+	}
+}
+
+// Close stops the pipe from accepting. Connections already made stay open.
+func (pipe *Pipe) Close() error {
+	pipe.closeOnce.Do(func() { close(pipe.done) })
+	return nil
+}
+
+func (pipe *Pipe) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// setDeadline sets the deadline of conn, if its transport supports them.
+func setDeadline(conn io.ReadWriteCloser, deadline time.Time) {
+	if conn, ok := conn.(interface{ SetDeadline(time.Time) error }); ok {
+		conn.SetDeadline(deadline)
+	}
+}
+
+// setWriteDeadline sets the write deadline of conn, if its transport
+// supports them.
+func setWriteDeadline(conn io.ReadWriteCloser, deadline time.Time) {
+	if conn, ok := conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+}
+
+// remoteAddr describes the remote end of conn for logging.
+func remoteAddr(conn io.ReadWriteCloser) string {
+	if conn, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		return conn.RemoteAddr().String()
+	}
+	return "unknown"
+}
@@ -0,0 +1,62 @@
+package ntgo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPipeTransport(t *testing.T) {
+	pipe := NewPipe()
+	srv := &NetworkTables{Mode: ModeServer, Listeners: []Listener{pipe}, UpdateRate: 5 * time.Millisecond}
+	if err := srv.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer srv.Close()
+	srv.String("/robot/mode").Set("auto")
+	cl := &NetworkTables{Mode: ModeClient, Dialer: pipe, UpdateRate: 5 * time.Millisecond}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if cl.String("/robot/mode").GetOr("") != "auto" {
+		t.Fatal("Expected server entry to be announced over the pipe")
+	}
+	cl.Double("/robot/speed").Set(3)
+	waitFor(t, "client entry to reach the server", func() bool {
+		return srv.Double("/robot/speed").GetOr(0) == 3
+	})
+}
+
+func TestUnixTransport(t *testing.T) {
+	address := UnixPrefix + filepath.Join(t.TempDir(), "ntgo.sock")
+	srv := &NetworkTables{Mode: ModeServer, Listen: []string{address}, UpdateRate: 5 * time.Millisecond}
+	if err := srv.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer srv.Close()
+	srv.Boolean("/robot/enabled").Set(true)
+	cl := &NetworkTables{Mode: ModeClient, Servers: []string{address}, UpdateRate: 5 * time.Millisecond}
+	if err := cl.Initialize(); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	defer cl.Close()
+	if !cl.Boolean("/robot/enabled").GetOr(false) {
+		t.Fatal("Expected server entry to be announced over the socket")
+	}
+	if cl.Endpoint() != address {
+		t.Fatalf("Expected endpoint %s but got %s", address, cl.Endpoint())
+	}
+}
+
+func TestPipeClosed(t *testing.T) {
+	pipe := NewPipe()
+	pipe.Close()
+	if _, err := pipe.Dial(context.Background(), ""); err != ErrTransportClosed {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrTransportClosed, err)
+	}
+	if _, err := pipe.Accept(); err != ErrTransportClosed {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrTransportClosed, err)
+	}
+}
@@ -0,0 +1,645 @@
+package ntgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	DataLogValue DataLogKind = iota
+	DataLogStart
+	DataLogFinish
+	DataLogMetadata
+)
+
+// DataLog control record types, the first byte of a record for entry 0.
+const (
+	dataLogControlStart    = 0
+	dataLogControlFinish   = 1
+	dataLogControlMetadata = 2
+)
+
+var dataLogMagic = []byte("WPILOG")
+
+// dataLogMaxLength bounds the extra header and the records a DataLog may
+// hold, so that a corrupt length cannot make the reader allocate without
+// limit.
+const dataLogMaxLength = 16 << 20
+
+// dataLogVersion is the version of the DataLog format written, 1.0.
+const dataLogVersion = 0x0100
+
+var (
+	ErrDataLogInvalid     = errors.New("wpilog: not a DataLog or unsupported version")
+	ErrDataLogRecord      = errors.New("wpilog: malformed record")
+	ErrDataLogUnknownType = errors.New("wpilog: entry type has no DataLog equivalent")
+)
+
+// DataLogKind says what a DataLogRecord reports.
+type DataLogKind int
+
+// DataLogRecord is one record of a WPILib DataLog. Timestamp is measured
+// from the start of the log, in microseconds as DataLog stores it. Type is
+// the DataLog type of the entry, such as "int64" or "double[]"; Value holds
+// it as the nearest EntryType and is only set for DataLogValue.
+type DataLogRecord struct {
+	Kind      DataLogKind
+	Timestamp time.Duration
+	Name      string
+	Type      string
+	Metadata  string
+	Value     EntryValue
+}
+
+// DataLogType returns the DataLog type string of entryType. The names are
+// the same as EntryType.String, except that RPC definitions have none.
+func DataLogType(entryType EntryType) (string, error) {
+	if entryType == EntryTypeRPCDef {
+		return "", ErrDataLogUnknownType
+	}
+	name, ok := entryTypeNames[entryType]
+	if !ok {
+		return "", ErrDataLogUnknownType
+	}
+	return name, nil
+}
+
+// DataLogEntryType returns the EntryType a DataLog type is read as. Numeric
+// types other than double are read as doubles, json as a string, and any
+// type without an equivalent, such as a struct, as raw data.
+func DataLogEntryType(dataLogType string) EntryType {
+	switch dataLogType {
+	case "boolean":
+		return EntryTypeBoolean
+	case "double", "float", "int64":
+		return EntryTypeDouble
+	case "string", "json":
+		return EntryTypeString
+	case "boolean[]":
+		return EntryTypeBooleanArr
+	case "double[]", "float[]", "int64[]":
+		return EntryTypeDoubleArr
+	case "string[]":
+		return EntryTypeStringArr
+	}
+	return EntryTypeRawData
+}
+
+// dataLogMetadata returns the metadata an entry with flags is started with:
+// persistent entries carry the NetworkTables 4.0 persistent property.
+func dataLogMetadata(flags EntryFlag) string {
+	if flags&EntryFlagPersistent != 0 {
+		return `{"persistent":true}`
+	}
+	return ""
+}
+
+// dataLogFlags reads the flags back out of metadata.
+func dataLogFlags(metadata string) EntryFlag {
+	properties := struct {
+		Persistent bool `json:"persistent"`
+	}{}
+	if json.Unmarshal([]byte(metadata), &properties) == nil && properties.Persistent {
+		return EntryFlagPersistent
+	}
+	return EntryFlagTemporary
+}
+
+// DataLogWriter writes entry values as a WPILib DataLog, the format of
+// .wpilog files. Entries are started the first time a value is appended to
+// them. It is safe for concurrent use.
+type DataLogWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	start  time.Time
+	active map[string]*dataLogEntry
+	nextID uint32
+}
+
+type dataLogEntry struct {
+	id       uint32
+	typeName string
+	metadata string
+}
+
+// NewDataLogWriter writes the DataLog header to w. extraHeader is free
+// text stored in the header.
+func NewDataLogWriter(w io.Writer, extraHeader string) (*DataLogWriter, error) {
+	header := append([]byte{}, dataLogMagic...)
+	header = binary.LittleEndian.AppendUint16(header, dataLogVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(extraHeader)))
+	header = append(header, extraHeader...)
+	if _, writeErr := w.Write(header); writeErr != nil {
+		return nil, writeErr
+	}
+	return &DataLogWriter{w: w, start: time.Now(), active: map[string]*dataLogEntry{}}, nil
+}
+
+// Append writes a value of the named entry at timestamp, starting the entry
+// first if needed. An entry whose type changes is finished and started
+// again under a new ID, as DataLog entries have a single type.
+func (dl *DataLogWriter) Append(name string, value EntryValue, flags EntryFlag, timestamp time.Duration) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.append(name, value, flags, timestamp)
+}
+
+func (dl *DataLogWriter) append(name string, value EntryValue, flags EntryFlag, timestamp time.Duration) error {
+	typeName, typeErr := DataLogType(EntryValueType(value))
+	if typeErr != nil {
+		return typeErr
+	}
+	entry, active := dl.active[name]
+	if active && entry.typeName != typeName {
+		if finishErr := dl.finish(name, timestamp); finishErr != nil {
+			return finishErr
+		}
+		active = false
+	}
+	if !active {
+		dl.nextID++
+		entry = &dataLogEntry{id: dl.nextID, typeName: typeName, metadata: dataLogMetadata(flags)}
+		dl.active[name] = entry
+		control := []byte{dataLogControlStart}
+		control = binary.LittleEndian.AppendUint32(control, entry.id)
+		control = appendDataLogString(control, name)
+		control = appendDataLogString(control, typeName)
+		control = appendDataLogString(control, entry.metadata)
+		if writeErr := dl.write(0, timestamp, control); writeErr != nil {
+			return writeErr
+		}
+	} else if flagsErr := dl.setFlags(name, flags, timestamp); flagsErr != nil {
+		return flagsErr
+	}
+	return dl.write(entry.id, timestamp, encodeDataLogValue(value))
+}
+
+// SetFlags records new flags for the named entry in its metadata, if it has
+// been started.
+func (dl *DataLogWriter) SetFlags(name string, flags EntryFlag, timestamp time.Duration) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.setFlags(name, flags, timestamp)
+}
+
+func (dl *DataLogWriter) setFlags(name string, flags EntryFlag, timestamp time.Duration) error {
+	entry, active := dl.active[name]
+	metadata := dataLogMetadata(flags)
+	if !active || entry.metadata == metadata {
+		return nil
+	}
+	entry.metadata = metadata
+	control := []byte{dataLogControlMetadata}
+	control = binary.LittleEndian.AppendUint32(control, entry.id)
+	control = appendDataLogString(control, metadata)
+	return dl.write(0, timestamp, control)
+}
+
+// Finish ends the named entry, as when it is deleted.
+func (dl *DataLogWriter) Finish(name string, timestamp time.Duration) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.finish(name, timestamp)
+}
+
+func (dl *DataLogWriter) finish(name string, timestamp time.Duration) error {
+	entry, active := dl.active[name]
+	if !active {
+		return nil
+	}
+	delete(dl.active, name)
+	control := binary.LittleEndian.AppendUint32([]byte{dataLogControlFinish}, entry.id)
+	return dl.write(0, timestamp, control)
+}
+
+// Log writes a table event, timestamped from when the writer was created.
+// It can be passed straight to Table.AddListener:
+//
+//	table.AddListener("/SmartDashboard", func(event ntgo.EntryEvent) { dl.Log(event) })
+func (dl *DataLogWriter) Log(event EntryEvent) error {
+	timestamp := event.Time.Sub(dl.start)
+	switch event.Kind {
+	case EntryEventDeleted:
+		return dl.Finish(event.Entry.Name.Value, timestamp)
+	case EntryEventFlagsUpdated:
+		return dl.SetFlags(event.Entry.Name.Value, event.Entry.Flags, timestamp)
+	}
+	return dl.Append(event.Entry.Name.Value, event.Entry.Value, event.Entry.Flags, timestamp)
+}
+
+// write writes one record. Each header field takes as few bytes as it
+// needs; the first byte says how many.
+func (dl *DataLogWriter) write(id uint32, timestamp time.Duration, payload []byte) error {
+	micros := uint64(max(timestamp.Microseconds(), 0))
+	idLength, sizeLength, timeLength := byteLength(uint64(id)), byteLength(uint64(len(payload))), byteLength(micros)
+	record := []byte{byte((idLength - 1) | (sizeLength-1)<<2 | (timeLength-1)<<4)}
+	record = appendLittleEndian(record, uint64(id), idLength)
+	record = appendLittleEndian(record, uint64(len(payload)), sizeLength)
+	record = appendLittleEndian(record, micros, timeLength)
+	_, writeErr := dl.w.Write(append(record, payload...))
+	return writeErr
+}
+
+// byteLength returns how many bytes value takes, at least one.
+func byteLength(value uint64) int {
+	length := 1
+	for value > 0xFF {
+		value >>= 8
+		length++
+	}
+	return length
+}
+
+func appendLittleEndian(raw []byte, value uint64, length int) []byte {
+	for i := 0; i < length; i++ {
+		raw = append(raw, byte(value>>(8*i)))
+	}
+	return raw
+}
+
+func appendDataLogString(raw []byte, value string) []byte {
+	raw = binary.LittleEndian.AppendUint32(raw, uint32(len(value)))
+	return append(raw, value...)
+}
+
+func encodeDataLogValue(value EntryValue) []byte {
+	switch value := value.(type) {
+	case *ValueBoolean:
+		if value.Value {
+			return []byte{1}
+		}
+		return []byte{0}
+	case *ValueDouble:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(value.Value))
+	case *ValueString:
+		return []byte(value.Value)
+	case *ValueRaw:
+		return value.Value
+	case *ValueBooleanArray:
+		raw := []byte{}
+		for _, element := range value.Values() {
+			if element {
+				raw = append(raw, 1)
+			} else {
+				raw = append(raw, 0)
+			}
+		}
+		return raw
+	case *ValueDoubleArray:
+		raw := []byte{}
+		for _, element := range value.Values() {
+			raw = binary.LittleEndian.AppendUint64(raw, math.Float64bits(element))
+		}
+		return raw
+	case *ValueStringArray:
+		raw := binary.LittleEndian.AppendUint32(nil, uint32(value.Len()))
+		for _, element := range value.Values() {
+			raw = appendDataLogString(raw, element)
+		}
+		return raw
+	}
+	return nil
+}
+
+// DataLogReader reads the records of a WPILib DataLog in order.
+type DataLogReader struct {
+	// ExtraHeader is the free text stored in the header.
+	ExtraHeader string
+
+	r       *bufio.Reader
+	entries map[uint32]*DataLogRecord
+}
+
+// NewDataLogReader reads the header of a DataLog.
+func NewDataLogReader(r io.Reader) (*DataLogReader, error) {
+	reader := &DataLogReader{r: bufio.NewReader(r), entries: map[uint32]*DataLogRecord{}}
+	header := make([]byte, len(dataLogMagic)+6)
+	if _, readErr := io.ReadFull(reader.r, header); readErr != nil || !bytes.Equal(header[:len(dataLogMagic)], dataLogMagic) {
+		return nil, ErrDataLogInvalid
+	}
+	// Minor versions stay compatible.
+	if binary.LittleEndian.Uint16(header[6:8])>>8 != dataLogVersion>>8 {
+		return nil, ErrDataLogInvalid
+	}
+	extraLength := binary.LittleEndian.Uint32(header[8:12])
+	if extraLength > dataLogMaxLength {
+		return nil, ErrDataLogInvalid
+	}
+	extra := make([]byte, extraLength)
+	if _, readErr := io.ReadFull(reader.r, extra); readErr != nil {
+		return nil, ErrDataLogInvalid
+	}
+	reader.ExtraHeader = string(extra)
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the log. Records for
+// entries that were never started are skipped. An array too long for
+// NetworkTables returns ErrArrayOutOfSpace, after which reading can go on.
+func (reader *DataLogReader) Next() (DataLogRecord, error) {
+	for {
+		id, timestamp, payload, readErr := reader.readRecord()
+		if readErr != nil {
+			return DataLogRecord{}, readErr
+		}
+		if id != 0 {
+			entry, ok := reader.entries[id]
+			if !ok {
+				continue
+			}
+			value, valueErr := decodeDataLogValue(entry.Type, payload)
+			if valueErr != nil {
+				return DataLogRecord{}, valueErr
+			}
+			record := *entry
+			record.Kind, record.Timestamp, record.Value = DataLogValue, timestamp, value
+			return record, nil
+		}
+		record, control, controlErr := reader.control(payload)
+		if controlErr != nil {
+			return DataLogRecord{}, controlErr
+		}
+		if control {
+			record.Timestamp = timestamp
+			return record, nil
+		}
+	}
+}
+
+// control handles a control record and returns what it reports, if it
+// concerns a known entry.
+func (reader *DataLogReader) control(payload []byte) (DataLogRecord, bool, error) {
+	if len(payload) < 5 {
+		return DataLogRecord{}, false, ErrDataLogRecord
+	}
+	id := binary.LittleEndian.Uint32(payload[1:5])
+	rest := payload[5:]
+	switch payload[0] {
+	case dataLogControlStart:
+		name, rest, nameErr := readDataLogString(rest)
+		typeName, rest, typeErr := readDataLogString(rest)
+		metadata, _, metadataErr := readDataLogString(rest)
+		if nameErr != nil || typeErr != nil || metadataErr != nil {
+			return DataLogRecord{}, false, ErrDataLogRecord
+		}
+		entry := &DataLogRecord{Name: name, Type: typeName, Metadata: metadata}
+		reader.entries[id] = entry
+		record := *entry
+		record.Kind = DataLogStart
+		return record, true, nil
+	case dataLogControlFinish:
+		entry, ok := reader.entries[id]
+		if !ok {
+			return DataLogRecord{}, false, nil
+		}
+		delete(reader.entries, id)
+		record := *entry
+		record.Kind = DataLogFinish
+		return record, true, nil
+	case dataLogControlMetadata:
+		entry, ok := reader.entries[id]
+		if !ok {
+			return DataLogRecord{}, false, nil
+		}
+		metadata, _, metadataErr := readDataLogString(rest)
+		if metadataErr != nil {
+			return DataLogRecord{}, false, ErrDataLogRecord
+		}
+		entry.Metadata = metadata
+		record := *entry
+		record.Kind = DataLogMetadata
+		return record, true, nil
+	}
+	return DataLogRecord{}, false, ErrDataLogRecord
+}
+
+func (reader *DataLogReader) readRecord() (uint32, time.Duration, []byte, error) {
+	lengths, lengthsErr := reader.r.ReadByte()
+	if lengthsErr != nil {
+		return 0, 0, nil, io.EOF
+	}
+	idLength, sizeLength, timeLength := int(lengths&0x3)+1, int(lengths>>2&0x3)+1, int(lengths>>4&0x7)+1
+	header := make([]byte, idLength+sizeLength+timeLength)
+	if _, readErr := io.ReadFull(reader.r, header); readErr != nil {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	id := readLittleEndian(header[:idLength])
+	size := readLittleEndian(header[idLength : idLength+sizeLength])
+	micros := readLittleEndian(header[idLength+sizeLength:])
+	if size > dataLogMaxLength {
+		return 0, 0, nil, ErrDataLogRecord
+	}
+	payload := make([]byte, size)
+	if _, readErr := io.ReadFull(reader.r, payload); readErr != nil {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	return uint32(id), time.Duration(micros) * time.Microsecond, payload, nil
+}
+
+func readLittleEndian(raw []byte) uint64 {
+	var value uint64
+	for i, b := range raw {
+		value |= uint64(b) << (8 * i)
+	}
+	return value
+}
+
+func readDataLogString(raw []byte) (string, []byte, error) {
+	if len(raw) < 4 {
+		return "", nil, ErrDataLogRecord
+	}
+	length := binary.LittleEndian.Uint32(raw)
+	if uint64(len(raw)-4) < uint64(length) {
+		return "", nil, ErrDataLogRecord
+	}
+	return string(raw[4 : 4+length]), raw[4+length:], nil
+}
+
+func decodeDataLogValue(typeName string, payload []byte) (EntryValue, error) {
+	switch typeName {
+	case "boolean":
+		if len(payload) != 1 {
+			return nil, ErrDataLogRecord
+		}
+		return BuildBoolean(payload[0] != 0), nil
+	case "double", "float", "int64":
+		values, decodeErr := decodeDataLogNumbers(typeName, payload)
+		if decodeErr != nil || len(values) != 1 {
+			return nil, ErrDataLogRecord
+		}
+		return BuildDouble(values[0]), nil
+	case "string", "json":
+		return BuildString(string(payload)), nil
+	case "boolean[]":
+		values := make([]bool, len(payload))
+		for i, b := range payload {
+			values[i] = b != 0
+		}
+		return BuildBooleanArrayFrom(values)
+	case "double[]", "float[]", "int64[]":
+		values, decodeErr := decodeDataLogNumbers(typeName[:len(typeName)-2], payload)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		return BuildDoubleArrayFrom(values)
+	case "string[]":
+		if len(payload) < 4 {
+			return nil, ErrDataLogRecord
+		}
+		count := binary.LittleEndian.Uint32(payload)
+		rest := payload[4:]
+		values := []string{}
+		for range count {
+			var value string
+			var stringErr error
+			if value, rest, stringErr = readDataLogString(rest); stringErr != nil {
+				return nil, stringErr
+			}
+			values = append(values, value)
+		}
+		return BuildStringArrayFrom(values)
+	}
+	return BuildRaw(payload), nil
+}
+
+// decodeDataLogNumbers reads back-to-back numbers of a numeric DataLog type.
+func decodeDataLogNumbers(typeName string, payload []byte) ([]float64, error) {
+	width := 8
+	if typeName == "float" {
+		width = 4
+	}
+	if len(payload)%width != 0 {
+		return nil, ErrDataLogRecord
+	}
+	values := make([]float64, 0, len(payload)/width)
+	for i := 0; i < len(payload); i += width {
+		switch typeName {
+		case "float":
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(payload[i:]))))
+		case "int64":
+			values = append(values, float64(int64(binary.LittleEndian.Uint64(payload[i:]))))
+		default:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(payload[i:])))
+		}
+	}
+	return values, nil
+}
+
+// ExportDataLog converts a recording into a DataLog: every value an entry
+// took becomes a record at the time it was recorded, and deleting an entry
+// finishes it.
+func ExportDataLog(w io.Writer, recording io.Reader) error {
+	events, readErr := readReplayEvents(recording)
+	if readErr != nil {
+		return readErr
+	}
+	dl, headerErr := NewDataLogWriter(w, "ntgo")
+	if headerErr != nil {
+		return headerErr
+	}
+	last := map[string]Entry{}
+	for _, event := range events {
+		name := event.entry.Name.Value
+		if event.deleted {
+			delete(last, name)
+			if finishErr := dl.Finish(name, event.time); finishErr != nil {
+				return finishErr
+			}
+			continue
+		}
+		if event.entry.Type == EntryTypeRPCDef {
+			continue
+		}
+		// A recording holds both sides of a connection, so the same value
+		// often appears twice in a row.
+		previous, seen := last[name]
+		last[name] = event.entry
+		if seen && previous.Type == event.entry.Type && bytes.Equal(previous.Value.GetRaw(), event.entry.Value.GetRaw()) {
+			if flagsErr := dl.SetFlags(name, event.entry.Flags, event.time); flagsErr != nil {
+				return flagsErr
+			}
+			continue
+		}
+		if appendErr := dl.Append(name, event.entry.Value, event.entry.Flags, event.time); appendErr != nil {
+			return appendErr
+		}
+	}
+	return nil
+}
+
+// ImportDataLog converts a DataLog into a recording that a Player can
+// replay. Each entry becomes an Entry Assignment followed by Entry Updates,
+// with the persistent flag taken from its metadata. Arrays of more than 255
+// elements cannot be sent over NetworkTables and are left out.
+func ImportDataLog(w io.Writer, datalog io.Reader) error {
+	reader, readerErr := NewDataLogReader(datalog)
+	if readerErr != nil {
+		return readerErr
+	}
+	if _, writeErr := w.Write(appendRecordingHeader(nil, time.Now())); writeErr != nil {
+		return writeErr
+	}
+	entries := map[string]*Entry{}
+	nextID := uint16(0)
+	for {
+		record, nextErr := reader.Next()
+		if nextErr == io.EOF {
+			return nil
+		}
+		if nextErr == ErrArrayOutOfSpace {
+			// The array is longer than NetworkTables allows; the record has
+			// been read, so the rest of the log is still good.
+			continue
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		var message *Message
+		entry, exists := entries[record.Name]
+		switch record.Kind {
+		case DataLogValue:
+			valueType := EntryValueType(record.Value)
+			if exists && entry.Type == valueType {
+				entry.Value, entry.Sequence = record.Value, nextSequence(entry.Sequence)
+				message = BuildMessage(&MessageDataEntryUpdate{Entry: entry})
+				break
+			}
+			if !exists {
+				if nextID == 0xFFFF {
+					// Every ID is taken; the rest cannot be told apart.
+					continue
+				}
+				entry = &Entry{Name: BuildString(record.Name), ID: [2]byte{byte(nextID >> 8), byte(nextID)}}
+				entries[record.Name] = entry
+				nextID++
+			}
+			entry.Type, entry.Value, entry.Flags = valueType, record.Value, dataLogFlags(record.Metadata)
+			message = BuildMessage(&MessageDataEntryAssignment{Entry: entry})
+		case DataLogMetadata:
+			if !exists {
+				continue
+			}
+			entry.Flags = dataLogFlags(record.Metadata)
+			message = BuildMessage(&MessageDataEntryFlagsUpdate{Entry: entry})
+		case DataLogFinish:
+			if !exists {
+				continue
+			}
+			delete(entries, record.Name)
+			message = BuildMessage(&MessageDataEntryDelete{Entry: entry})
+		default:
+			continue
+		}
+		raw := appendRecord(nil, Record{Time: record.Timestamp, Direction: DirectionReceived, Session: 1, Message: message})
+		if _, writeErr := w.Write(raw); writeErr != nil {
+			return writeErr
+		}
+	}
+}
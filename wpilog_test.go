@@ -0,0 +1,324 @@
+package ntgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDataLogType(t *testing.T) {
+	cases := []struct {
+		entryType EntryType
+		name      string
+	}{
+		{EntryTypeBoolean, "boolean"},
+		{EntryTypeDouble, "double"},
+		{EntryTypeString, "string"},
+		{EntryTypeRawData, "raw"},
+		{EntryTypeBooleanArr, "boolean[]"},
+		{EntryTypeDoubleArr, "double[]"},
+		{EntryTypeStringArr, "string[]"},
+	}
+	for _, c := range cases {
+		name, err := DataLogType(c.entryType)
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if name != c.name {
+			t.Fatalf("Expected %s but got %s", c.name, name)
+		}
+		if DataLogEntryType(name) != c.entryType {
+			t.Fatalf("Expected %s to read back as %s but got %s", name, c.entryType, DataLogEntryType(name))
+		}
+	}
+	if _, err := DataLogType(EntryTypeRPCDef); err != ErrDataLogUnknownType {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrDataLogUnknownType, err)
+	}
+	if DataLogEntryType("int64[]") != EntryTypeDoubleArr || DataLogEntryType("struct:Pose2d") != EntryTypeRawData {
+		t.Fatal("Expected int64[] to read as double[] and struct types as raw")
+	}
+}
+
+func TestDataLogRoundTrip(t *testing.T) {
+	doubles, _ := BuildDoubleArrayFrom([]float64{1.5, -2, 300})
+	booleans, _ := BuildBooleanArrayFrom([]bool{true, false, true})
+	strings, _ := BuildStringArrayFrom([]string{"left", "", "right"})
+	values := []EntryValue{
+		BuildBoolean(true),
+		BuildDouble(3.25),
+		BuildString("hello"),
+		BuildRaw([]byte{0x00, 0xFF, 0x10}),
+		booleans,
+		doubles,
+		strings,
+	}
+	var buffer bytes.Buffer
+	dl, err := NewDataLogWriter(&buffer, "robot")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	for i, value := range values {
+		// A timestamp past 16 bits exercises the wider record headers.
+		if err := dl.Append("/entry", value, EntryFlagTemporary, time.Duration(i)*time.Second); err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+	}
+	if err := dl.Append("/saved", BuildDouble(1), EntryFlagPersistent, 0); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := dl.SetFlags("/saved", EntryFlagTemporary, time.Second); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := dl.Finish("/saved", 2*time.Second); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+
+	reader, err := NewDataLogReader(&buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if reader.ExtraHeader != "robot" {
+		t.Fatalf("Expected extra header robot but got %s", reader.ExtraHeader)
+	}
+	records := []DataLogRecord{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		records = append(records, record)
+	}
+	read := []EntryValue{}
+	for _, record := range records {
+		if record.Name == "/entry" && record.Kind == DataLogValue {
+			read = append(read, record.Value)
+		}
+	}
+	if len(read) != len(values) {
+		t.Fatalf("Expected %d values but got %d", len(values), len(read))
+	}
+	for i, value := range values {
+		if EntryValueType(read[i]) != EntryValueType(value) || !bytes.Equal(read[i].GetRaw(), value.GetRaw()) {
+			t.Fatalf("Expected %v but got %v", value, read[i])
+		}
+	}
+	saved := []DataLogRecord{}
+	for _, record := range records {
+		if record.Name == "/saved" {
+			saved = append(saved, record)
+		}
+	}
+	if len(saved) != 4 {
+		t.Fatalf("Expected 4 records of /saved but got %d", len(saved))
+	}
+	if saved[0].Kind != DataLogStart || saved[0].Type != "double" || dataLogFlags(saved[0].Metadata) != EntryFlagPersistent {
+		t.Fatalf("Expected a persistent double start but got %+v", saved[0])
+	}
+	if saved[2].Kind != DataLogMetadata || dataLogFlags(saved[2].Metadata) != EntryFlagTemporary || saved[2].Timestamp != time.Second {
+		t.Fatalf("Expected a metadata change at 1s but got %+v", saved[2])
+	}
+	if saved[3].Kind != DataLogFinish || saved[3].Timestamp != 2*time.Second {
+		t.Fatalf("Expected a finish at 2s but got %+v", saved[3])
+	}
+}
+
+func TestDataLogTypeChange(t *testing.T) {
+	var buffer bytes.Buffer
+	dl, err := NewDataLogWriter(&buffer, "")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	dl.Append("/mode", BuildDouble(1), EntryFlagTemporary, 0)
+	dl.Append("/mode", BuildString("auto"), EntryFlagTemporary, time.Millisecond)
+	reader, err := NewDataLogReader(&buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	kinds := []DataLogKind{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		kinds = append(kinds, record.Kind)
+	}
+	expected := []DataLogKind{DataLogStart, DataLogValue, DataLogFinish, DataLogStart, DataLogValue}
+	if len(kinds) != len(expected) {
+		t.Fatalf("Expected records %v but got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Fatalf("Expected records %v but got %v", expected, kinds)
+		}
+	}
+}
+
+func TestDataLogInvalid(t *testing.T) {
+	if _, err := NewDataLogReader(bytes.NewReader([]byte("NTLOG\x01"))); err != ErrDataLogInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrDataLogInvalid, err)
+	}
+	// Version 2.0.
+	header := []byte("WPILOG\x00\x02\x00\x00\x00\x00")
+	if _, err := NewDataLogReader(bytes.NewReader(header)); err != ErrDataLogInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrDataLogInvalid, err)
+	}
+	// An extra header of 4 GB.
+	header = []byte("WPILOG\x00\x01\xff\xff\xff\xff")
+	if _, err := NewDataLogReader(bytes.NewReader(header)); err != ErrDataLogInvalid {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrDataLogInvalid, err)
+	}
+	// A record of 4 GB: one byte of ID, four of size and one of time.
+	header = []byte("WPILOG\x00\x01\x00\x00\x00\x00\x0c\x01\xff\xff\xff\xff\x00")
+	reader, err := NewDataLogReader(bytes.NewReader(header))
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if _, err := reader.Next(); err != ErrDataLogRecord {
+		t.Fatalf("Expected error \"%s\" but received \"%s\"", ErrDataLogRecord, err)
+	}
+}
+
+func TestExportImportDataLog(t *testing.T) {
+	var datalog bytes.Buffer
+	if err := ExportDataLog(&datalog, testRecording(t)); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	var recording bytes.Buffer
+	if err := ImportDataLog(&recording, bytes.NewReader(datalog.Bytes())); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+
+	reader, err := NewDataLogReader(&datalog)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	start, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if start.Kind != DataLogStart || start.Name != "/speed" || start.Type != "double" {
+		t.Fatalf("Expected /speed to start as a double but got %+v", start)
+	}
+
+	// The imported recording replays the same states as the original.
+	nt := &NetworkTables{}
+	player, err := NewPlayer(&recording, nt)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if player.Duration() != 300*time.Millisecond {
+		t.Fatalf("Expected duration 300ms but got %s", player.Duration())
+	}
+	cases := []struct {
+		position time.Duration
+		exists   bool
+		value    float64
+		flags    EntryFlag
+	}{
+		{0, true, 1, EntryFlagTemporary},
+		{150 * time.Millisecond, true, 2, EntryFlagTemporary},
+		{250 * time.Millisecond, true, 2, EntryFlagPersistent},
+		{time.Second, false, 0, 0},
+	}
+	for _, c := range cases {
+		player.Seek(c.position)
+		entry, exists := nt.Table().Get("/speed")
+		if exists != c.exists {
+			t.Fatalf("Expected entry to exist %t at %s", c.exists, c.position)
+		}
+		if exists && (entry.Value.(*ValueDouble).Value != c.value || entry.Flags != c.flags) {
+			t.Fatalf("Expected %v (%s) at %s but got %v (%s)", c.value, c.flags, c.position, entry.Value, entry.Flags)
+		}
+	}
+}
+
+func TestImportDataLogLongArray(t *testing.T) {
+	var datalog bytes.Buffer
+	dl, err := NewDataLogWriter(&datalog, "")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	// NetworkTables arrays hold at most 255 elements, so this one cannot be
+	// built as an EntryValue and is written by hand.
+	control := binary.LittleEndian.AppendUint32([]byte{dataLogControlStart}, 100)
+	control = appendDataLogString(control, "/long")
+	control = appendDataLogString(control, "double[]")
+	control = appendDataLogString(control, "")
+	if err := dl.write(0, 0, control); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := dl.write(100, 0, make([]byte, 300*8)); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	if err := dl.Append("/speed", BuildDouble(4), EntryFlagTemporary, time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+
+	var recording bytes.Buffer
+	if err := ImportDataLog(&recording, &datalog); err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	nt := &NetworkTables{}
+	player, err := NewPlayer(&recording, nt)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	player.Seek(time.Second)
+	if _, exists := nt.Table().Get("/long"); exists {
+		t.Fatal("Expected the long array to be left out")
+	}
+	if entry, exists := nt.Table().Get("/speed"); !exists || entry.Value.(*ValueDouble).Value != 4 {
+		t.Fatal("Expected /speed to be imported after the long array")
+	}
+}
+
+func TestDataLogWriterConcurrent(t *testing.T) {
+	var buffer bytes.Buffer
+	dl, err := NewDataLogWriter(&buffer, "")
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("/value%d", i)
+			for j := range 50 {
+				dl.Append(name, BuildDouble(float64(j)), EntryFlagTemporary, time.Duration(j)*time.Millisecond)
+			}
+			dl.Finish(name, time.Second)
+		}()
+	}
+	wg.Wait()
+	reader, err := NewDataLogReader(&buffer)
+	if err != nil {
+		t.Fatalf("Unexpected error! %s", err)
+	}
+	values := 0
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error! %s", err)
+		}
+		if record.Kind == DataLogValue {
+			values++
+		}
+	}
+	if values != 200 {
+		t.Fatalf("Expected 200 values but got %d", values)
+	}
+}